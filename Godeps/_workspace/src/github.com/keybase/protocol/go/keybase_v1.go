@@ -141,6 +141,7 @@ type Cryptocurrency struct {
 	RowId   int    `codec:"rowId"`
 	Pkhash  []byte `codec:"pkhash"`
 	Address string `codec:"address"`
+	Type    string `codec:"type"`
 }
 
 type Identity struct {
@@ -822,6 +823,74 @@ func (c ProveUiClient) DisplayRecheckWarning(__arg DisplayRecheckWarningArg) (er
 	return
 }
 
+type GetSecretKeyArg struct {
+	Kid []byte `codec:"kid"`
+}
+
+type PutSecretKeyArg struct {
+	Bundle []byte `codec:"bundle"`
+}
+
+type LockArg struct {
+}
+
+type SecretCacheInterface interface {
+	GetSecretKey([]byte) ([]byte, error)
+	PutSecretKey([]byte) error
+	Lock() error
+}
+
+func SecretCacheProtocol(i SecretCacheInterface) rpc2.Protocol {
+	return rpc2.Protocol{
+		Name: "keybase.1.secretCache",
+		Methods: map[string]rpc2.ServeHook{
+			"getSecretKey": func(nxt rpc2.DecodeNext) (ret interface{}, err error) {
+				args := make([]GetSecretKeyArg, 1)
+				if err = nxt(&args); err == nil {
+					ret, err = i.GetSecretKey(args[0].Kid)
+				}
+				return
+			},
+			"putSecretKey": func(nxt rpc2.DecodeNext) (ret interface{}, err error) {
+				args := make([]PutSecretKeyArg, 1)
+				if err = nxt(&args); err == nil {
+					err = i.PutSecretKey(args[0].Bundle)
+				}
+				return
+			},
+			"lock": func(nxt rpc2.DecodeNext) (ret interface{}, err error) {
+				args := make([]LockArg, 1)
+				if err = nxt(&args); err == nil {
+					err = i.Lock()
+				}
+				return
+			},
+		},
+	}
+
+}
+
+type SecretCacheClient struct {
+	Cli GenericClient
+}
+
+func (c SecretCacheClient) GetSecretKey(kid []byte) (res []byte, err error) {
+	__arg := GetSecretKeyArg{Kid: kid}
+	err = c.Cli.Call("keybase.1.secretCache.getSecretKey", []interface{}{__arg}, &res)
+	return
+}
+
+func (c SecretCacheClient) PutSecretKey(bundle []byte) (err error) {
+	__arg := PutSecretKeyArg{Bundle: bundle}
+	err = c.Cli.Call("keybase.1.secretCache.putSecretKey", []interface{}{__arg}, nil)
+	return
+}
+
+func (c SecretCacheClient) Lock() (err error) {
+	err = c.Cli.Call("keybase.1.secretCache.lock", []interface{}{LockArg{}}, nil)
+	return
+}
+
 type SecretEntryArg struct {
 	Desc   string `codec:"desc"`
 	Prompt string `codec:"prompt"`
@@ -986,6 +1055,77 @@ func (c SignupClient) InviteRequest(__arg InviteRequestArg) (err error) {
 	return
 }
 
+type TrackArg struct {
+	TheirName string `codec:"theirName"`
+}
+
+type TrackWithOptionsArg struct {
+	TheirName string `codec:"theirName"`
+	LocalOnly bool   `codec:"localOnly"`
+	ExpireIn  int    `codec:"expireIn"`
+}
+
+type UntrackArg struct {
+	TheirName string `codec:"theirName"`
+}
+
+type TrackInterface interface {
+	Track(string) error
+	TrackWithOptions(TrackWithOptionsArg) error
+	Untrack(string) error
+}
+
+func TrackProtocol(i TrackInterface) rpc2.Protocol {
+	return rpc2.Protocol{
+		Name: "keybase.1.track",
+		Methods: map[string]rpc2.ServeHook{
+			"track": func(nxt rpc2.DecodeNext) (ret interface{}, err error) {
+				args := make([]TrackArg, 1)
+				if err = nxt(&args); err == nil {
+					err = i.Track(args[0].TheirName)
+				}
+				return
+			},
+			"trackWithOptions": func(nxt rpc2.DecodeNext) (ret interface{}, err error) {
+				args := make([]TrackWithOptionsArg, 1)
+				if err = nxt(&args); err == nil {
+					err = i.TrackWithOptions(args[0])
+				}
+				return
+			},
+			"untrack": func(nxt rpc2.DecodeNext) (ret interface{}, err error) {
+				args := make([]UntrackArg, 1)
+				if err = nxt(&args); err == nil {
+					err = i.Untrack(args[0].TheirName)
+				}
+				return
+			},
+		},
+	}
+
+}
+
+type TrackClient struct {
+	Cli GenericClient
+}
+
+func (c TrackClient) Track(theirName string) (err error) {
+	__arg := TrackArg{TheirName: theirName}
+	err = c.Cli.Call("keybase.1.track.track", []interface{}{__arg}, nil)
+	return
+}
+
+func (c TrackClient) TrackWithOptions(__arg TrackWithOptionsArg) (err error) {
+	err = c.Cli.Call("keybase.1.track.trackWithOptions", []interface{}{__arg}, nil)
+	return
+}
+
+func (c TrackClient) Untrack(theirName string) (err error) {
+	__arg := UntrackArg{TheirName: theirName}
+	err = c.Cli.Call("keybase.1.track.untrack", []interface{}{__arg}, nil)
+	return
+}
+
 type PromptYesNoArg struct {
 	Text Text  `codec:"text"`
 	Def  *bool `codec:"def,omitempty"`
@@ -1019,3 +1159,49 @@ func (c UiClient) PromptYesNo(__arg PromptYesNoArg) (res bool, err error) {
 	err = c.Cli.Call("keybase.1.ui.promptYesNo", []interface{}{__arg}, &res)
 	return
 }
+
+type User struct {
+	Uid      UID    `codec:"uid"`
+	Username string `codec:"username"`
+}
+
+type VerifyArg struct {
+	Message   []byte `codec:"message"`
+	Signature []byte `codec:"signature"`
+	AtSeqno   int    `codec:"atSeqno"`
+	AtTime    int64  `codec:"atTime"`
+}
+
+type VerifyRes struct {
+	Signer User   `codec:"signer"`
+	Body   []byte `codec:"body"`
+}
+
+type VerifyInterface interface {
+	Verify(VerifyArg) (VerifyRes, error)
+}
+
+func VerifyProtocol(i VerifyInterface) rpc2.Protocol {
+	return rpc2.Protocol{
+		Name: "keybase.1.verify",
+		Methods: map[string]rpc2.ServeHook{
+			"verify": func(nxt rpc2.DecodeNext) (ret interface{}, err error) {
+				args := make([]VerifyArg, 1)
+				if err = nxt(&args); err == nil {
+					ret, err = i.Verify(args[0])
+				}
+				return
+			},
+		},
+	}
+
+}
+
+type VerifyClient struct {
+	Cli GenericClient
+}
+
+func (c VerifyClient) Verify(__arg VerifyArg) (res VerifyRes, err error) {
+	err = c.Cli.Call("keybase.1.verify.verify", []interface{}{__arg}, &res)
+	return
+}
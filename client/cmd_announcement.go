@@ -0,0 +1,144 @@
+package main
+
+import (
+	"fmt"
+	"github.com/codegangsta/cli"
+	"github.com/keybase/go/libcmdline"
+	"github.com/keybase/go/libkb"
+	"os"
+	"strings"
+)
+
+func NewCmdAnnouncement(cl *libcmdline.CommandLine) cli.Command {
+	return cli.Command{
+		Name:        "announcement",
+		Usage:       "keybase announcement [subcommands...]",
+		Description: "Post signed announcements to your sigchain, or check a user's",
+		Subcommands: []cli.Command{
+			NewCmdAnnouncementList(cl),
+			NewCmdAnnouncementPost(cl),
+		},
+	}
+}
+
+//=============================================================================
+
+type CmdAnnouncementPost struct {
+	arg libkb.AnnounceArg
+}
+
+func (v *CmdAnnouncementPost) ParseArgv(ctx *cli.Context) error {
+	if len(ctx.Args()) == 0 {
+		return fmt.Errorf("post takes the text of the announcement")
+	}
+	v.arg.Message = strings.Join(ctx.Args(), " ")
+	return nil
+}
+
+func (v *CmdAnnouncementPost) RunClient() error { return v.Run() }
+
+func (v *CmdAnnouncementPost) Run() error {
+	v.arg.SecretUI = G_UI.GetSecretUI()
+	return libkb.NewAnnounceEngine(&v.arg).Run()
+}
+
+func NewCmdAnnouncementPost(cl *libcmdline.CommandLine) cli.Command {
+	return cli.Command{
+		Name:        "post",
+		Usage:       "keybase announcement post <text>",
+		Description: "Sign a short announcement into your sigchain",
+		Action: func(c *cli.Context) {
+			cl.ChooseCommand(&CmdAnnouncementPost{}, "post", c)
+		},
+	}
+}
+
+func (v *CmdAnnouncementPost) GetUsage() libkb.Usage {
+	return libkb.Usage{
+		Config:    true,
+		API:       true,
+		Terminal:  true,
+		KbKeyring: true,
+	}
+}
+
+//=============================================================================
+
+type CmdAnnouncementList struct {
+	username string
+	revoked  bool
+}
+
+func (v *CmdAnnouncementList) ParseArgv(ctx *cli.Context) (err error) {
+	nargs := len(ctx.Args())
+	if nargs == 1 {
+		v.username = ctx.Args()[0]
+	} else if nargs > 1 {
+		err = fmt.Errorf("list takes at most 1 arg, a username")
+	}
+	v.revoked = ctx.Bool("revoked")
+	return
+}
+
+func (v *CmdAnnouncementList) RunClient() error { return v.Run() }
+
+func (v *CmdAnnouncementList) Run() (err error) {
+	arg := libkb.LoadUserArg{AllKeys: true}
+	if len(v.username) != 0 {
+		arg.Name = v.username
+	} else {
+		arg.Self = true
+	}
+
+	var u *libkb.User
+	if u, err = libkb.LoadUser(arg); err != nil {
+		return
+	}
+
+	nbad := 0
+	for _, c := range libkb.CheckAnnouncements(u) {
+		if c.Link.IsRevoked() && !v.revoked {
+			continue
+		}
+		var status string
+		if c.Err != nil {
+			status = BADX + " " + ColorString("red", c.Err.Error())
+			nbad++
+		} else {
+			status = CHECK + " " + ColorString("green", "signed by key "+c.Key.GetKid().ToShortIdString())
+		}
+		if c.Link.IsRevoked() {
+			status += " " + ColorString("magenta", "[revoked]")
+		}
+		fmt.Fprintf(os.Stdout, "%d\t%s\t%s\n\t%s\n", int(c.Link.GetSeqno()),
+			libkb.FormatTime(c.Link.GetCTime()), status, c.Link.GetMessage())
+	}
+	if nbad > 0 {
+		err = fmt.Errorf("%d announcement(s) failed to verify", nbad)
+	}
+	return
+}
+
+func NewCmdAnnouncementList(cl *libcmdline.CommandLine) cli.Command {
+	return cli.Command{
+		Name:        "list",
+		Usage:       "keybase announcement list [-r] [<username>]",
+		Description: "List and verify a user's announcements (yours by default)",
+		Flags: []cli.Flag{
+			cli.BoolFlag{
+				Name:  "r, revoked",
+				Usage: "show revoked announcements too",
+			},
+		},
+		Action: func(c *cli.Context) {
+			cl.ChooseCommand(&CmdAnnouncementList{}, "list", c)
+		},
+	}
+}
+
+func (v *CmdAnnouncementList) GetUsage() libkb.Usage {
+	return libkb.Usage{
+		Config: true,
+		API:    true,
+	}
+}
@@ -0,0 +1,60 @@
+package main
+
+import (
+	"fmt"
+	"github.com/codegangsta/cli"
+	"github.com/keybase/go/libcmdline"
+	"github.com/keybase/go/libkb"
+	"strings"
+)
+
+type CmdBTC struct {
+	arg libkb.BTCArg
+}
+
+func (v *CmdBTC) ParseArgv(ctx *cli.Context) error {
+	if len(ctx.Args()) != 1 {
+		return fmt.Errorf("btc takes one arg -- the address to sign")
+	}
+	v.arg.Address = ctx.Args()[0]
+	v.arg.Force = ctx.Bool("force")
+	v.arg.Type = ctx.String("type")
+	return nil
+}
+
+func (v *CmdBTC) RunClient() error { return v.Run() }
+
+func (v *CmdBTC) Run() error {
+	v.arg.SecretUI = G_UI.GetSecretUI()
+	return libkb.NewBTCEngine(&v.arg).Run()
+}
+
+func NewCmdBTC(cl *libcmdline.CommandLine) cli.Command {
+	return cli.Command{
+		Name:        "btc",
+		Usage:       "keybase btc [-f] [-t <type>] <address>",
+		Description: "sign a bitcoin (or other cryptocurrency) address into your sigchain",
+		Flags: []cli.Flag{
+			cli.BoolFlag{
+				Name:  "f, force",
+				Usage: "replace your current address, if you have one",
+			},
+			cli.StringFlag{
+				Name:  "t, type",
+				Usage: "currency type; one of {" + strings.Join(libkb.CryptocurrencyTypes(), ", ") + "}; bitcoin by default",
+			},
+		},
+		Action: func(c *cli.Context) {
+			cl.ChooseCommand(&CmdBTC{}, "btc", c)
+		},
+	}
+}
+
+func (v *CmdBTC) GetUsage() libkb.Usage {
+	return libkb.Usage{
+		Config:    true,
+		API:       true,
+		Terminal:  true,
+		KbKeyring: true,
+	}
+}
@@ -0,0 +1,72 @@
+package main
+
+import (
+	"fmt"
+	"github.com/codegangsta/cli"
+	"github.com/keybase/go/libcmdline"
+	"github.com/keybase/go/libkb"
+)
+
+func NewCmdDecrypt(cl *libcmdline.CommandLine) cli.Command {
+	return cli.Command{
+		Name:        "decrypt",
+		Usage:       "keybase decrypt [-m <message>] [-o <outfile>] [<infile>]",
+		Description: "decrypt a message, and identify its signer if it was signed",
+		Action: func(c *cli.Context) {
+			cl.ChooseCommand(&CmdDecrypt{}, "decrypt", c)
+		},
+		Flags: []cli.Flag{
+			cli.StringFlag{
+				Name:  "m, message",
+				Usage: "provide the message to decrypt on the command line",
+			},
+			cli.StringFlag{
+				Name:  "o, outfile",
+				Usage: "specify an outfile (stdout by default)",
+			},
+		},
+	}
+}
+
+type CmdDecrypt struct {
+	UnixFilter
+}
+
+func (c *CmdDecrypt) ParseArgv(ctx *cli.Context) error {
+	nargs := len(ctx.Args())
+	var infile string
+	if nargs == 1 {
+		infile = ctx.Args()[0]
+	} else if nargs > 1 {
+		return fmt.Errorf("decrypt takes at most 1 arg, an infile")
+	}
+	return c.FilterInit(ctx.String("message"), infile, ctx.String("outfile"))
+}
+
+func (c *CmdDecrypt) RunClient() (err error) { return c.Run() }
+
+func (c *CmdDecrypt) Run() (err error) {
+	if err = c.FilterOpen(); err != nil {
+		return
+	}
+	defer func() {
+		c.Close(err)
+	}()
+
+	arg := libkb.DecryptArg{
+		Source: c.source,
+		Sink:   c.sink,
+	}
+	_, err = libkb.NewDecryptEngine(&arg).Run()
+	return
+}
+
+func (c *CmdDecrypt) GetUsage() libkb.Usage {
+	return libkb.Usage{
+		Config:     true,
+		API:        true,
+		Terminal:   true,
+		KbKeyring:  true,
+		GpgKeyring: true,
+	}
+}
@@ -0,0 +1,282 @@
+package main
+
+import (
+	"fmt"
+	"github.com/codegangsta/cli"
+	"github.com/keybase/go/libcmdline"
+	"github.com/keybase/go/libkb"
+	"os"
+	"strings"
+)
+
+func NewCmdDevice(cl *libcmdline.CommandLine) cli.Command {
+	return cli.Command{
+		Name:        "device",
+		Usage:       "keybase device [subcommands...]",
+		Description: "Manage the devices that hold your keys",
+		Subcommands: []cli.Command{
+			NewCmdDeviceAdd(cl),
+			NewCmdDeviceList(cl),
+			NewCmdDevicePaperKey(cl),
+			NewCmdDeviceProvision(cl),
+		},
+	}
+}
+
+func promptForPaperKey() (*libkb.PaperKey, error) {
+	phrase, err := G_UI.Prompt("Your paper key", true, libkb.CheckPaperKey)
+	if err != nil {
+		return nil, err
+	}
+	return libkb.ParsePaperKey(phrase)
+}
+
+//=============================================================================
+
+type CmdDeviceList struct {
+	username string
+}
+
+func (v *CmdDeviceList) ParseArgv(ctx *cli.Context) (err error) {
+	nargs := len(ctx.Args())
+	if nargs == 1 {
+		v.username = ctx.Args()[0]
+	} else if nargs > 1 {
+		err = fmt.Errorf("list takes at most 1 arg, a username")
+	}
+	return
+}
+
+func (v *CmdDeviceList) RunClient() error { return v.Run() }
+
+func (v *CmdDeviceList) Run() (err error) {
+	var u *libkb.User
+	if len(v.username) == 0 {
+		u, err = libkb.LoadMe(libkb.LoadUserArg{})
+	} else {
+		u, err = libkb.LoadUser(libkb.LoadUserArg{Name: v.username})
+	}
+	if err != nil {
+		return
+	}
+
+	var devices []*libkb.DeviceKey
+	if devices, err = u.GetDevices(); err != nil {
+		return
+	}
+	if len(devices) == 0 {
+		G.Log.Info("%s has no devices in their sigchain", u.GetName())
+		return
+	}
+
+	var mine string
+	if did := G.Env.GetDeviceId(); did != nil && len(v.username) == 0 {
+		mine = did.String()
+	}
+	for _, dk := range devices {
+		v.display(dk, dk.Device.Id.String() == mine)
+	}
+	return
+}
+
+func (v *CmdDeviceList) display(dk *libkb.DeviceKey, mine bool) {
+	w := os.Stdout
+	var status string
+	if dk.Active() {
+		status = ColorString("green", "active")
+	} else if !dk.Known {
+		status = ColorString("yellow", "unknown")
+	} else {
+		status = ColorString("red", "revoked")
+	}
+	name := dk.Device.Name
+	if mine {
+		name += " (this device)"
+	}
+	fmt.Fprintf(w, "%s\t%s\t%s\n", name, dk.Device.Type, status)
+	fmt.Fprintf(w, "\tid:        %s\n", dk.Device.Id)
+	fmt.Fprintf(w, "\tkey:       %s\n", dk.Kid)
+	fmt.Fprintf(w, "\tadded:     #%d, %s\n", int(dk.Link.GetSeqno()),
+		libkb.FormatTime(dk.Link.GetCTime()))
+}
+
+func NewCmdDeviceList(cl *libcmdline.CommandLine) cli.Command {
+	return cli.Command{
+		Name:        "list",
+		Usage:       "keybase device list [<username>]",
+		Description: "List a user's devices and the status of their keys (yours by default)",
+		Action: func(c *cli.Context) {
+			cl.ChooseCommand(&CmdDeviceList{}, "list", c)
+		},
+	}
+}
+
+func (v *CmdDeviceList) GetUsage() libkb.Usage {
+	return libkb.Usage{
+		Config: true,
+		API:    true,
+	}
+}
+
+//=============================================================================
+
+type CmdDeviceAdd struct {
+	phrase string
+	paper  bool
+}
+
+func (v *CmdDeviceAdd) ParseArgv(ctx *cli.Context) error {
+	v.phrase = strings.Join(ctx.Args(), " ")
+	v.paper = ctx.Bool("paper")
+	return nil
+}
+
+func (v *CmdDeviceAdd) RunClient() error { return v.Run() }
+
+func (v *CmdDeviceAdd) Run() (err error) {
+	if len(v.phrase) == 0 {
+		v.phrase, err = G_UI.Prompt("Secret phrase from your new device", false,
+			libkb.CheckKexPhrase)
+		if err != nil {
+			return
+		}
+	}
+	arg := libkb.DeviceAddArg{
+		Phrase:   v.phrase,
+		SecretUI: G_UI.GetSecretUI(),
+	}
+	if v.paper {
+		if arg.PaperKey, err = promptForPaperKey(); err != nil {
+			return
+		}
+	}
+	return libkb.NewDeviceAddEngine(&arg).Run()
+}
+
+func NewCmdDeviceAdd(cl *libcmdline.CommandLine) cli.Command {
+	return cli.Command{
+		Name:        "add",
+		Usage:       "keybase device add [-p] [<secret phrase>]",
+		Description: "Sign a new device's keys into your sigchain, using the phrase it shows",
+		Flags: []cli.Flag{
+			cli.BoolFlag{
+				Name:  "p, paper",
+				Usage: "sign with your paper key, rather than this device's key",
+			},
+		},
+		Action: func(c *cli.Context) {
+			cl.ChooseCommand(&CmdDeviceAdd{}, "add", c)
+		},
+	}
+}
+
+func (v *CmdDeviceAdd) GetUsage() libkb.Usage {
+	return libkb.Usage{
+		Config:    true,
+		API:       true,
+		Terminal:  true,
+		KbKeyring: true,
+	}
+}
+
+//=============================================================================
+
+type CmdDeviceProvision struct {
+	arg   libkb.DeviceProvisionArg
+	paper bool
+}
+
+func (v *CmdDeviceProvision) ParseArgv(ctx *cli.Context) error {
+	if len(ctx.Args()) != 1 {
+		return fmt.Errorf("provision takes 1 arg, your username")
+	}
+	v.arg.Username = ctx.Args()[0]
+	v.arg.DeviceName = ctx.String("name")
+	v.paper = ctx.Bool("paper")
+	return nil
+}
+
+func (v *CmdDeviceProvision) RunClient() error { return v.Run() }
+
+func (v *CmdDeviceProvision) Run() (err error) {
+	if kid := G.Env.GetPerDeviceKID(); kid != nil {
+		return fmt.Errorf("This device already has a key (%s)", kid)
+	}
+	if v.paper {
+		if v.arg.PaperKey, err = promptForPaperKey(); err != nil {
+			return
+		}
+		v.arg.LoginUI = G_UI.GetLoginUI()
+		v.arg.SecretUI = G_UI.GetSecretUI()
+	}
+	return libkb.NewDeviceProvisionEngine(&v.arg).Run()
+}
+
+func NewCmdDeviceProvision(cl *libcmdline.CommandLine) cli.Command {
+	return cli.Command{
+		Name:        "provision",
+		Usage:       "keybase device provision [-n <name>] [-p] <username>",
+		Description: "Make keys for this device, and have one of your other devices sign them in",
+		Flags: []cli.Flag{
+			cli.StringFlag{
+				Name:  "n, name",
+				Usage: "a name for this device (the hostname by default)",
+			},
+			cli.BoolFlag{
+				Name:  "p, paper",
+				Usage: "sign them in with your paper key instead",
+			},
+		},
+		Action: func(c *cli.Context) {
+			cl.ChooseCommand(&CmdDeviceProvision{}, "provision", c)
+		},
+	}
+}
+
+func (v *CmdDeviceProvision) GetUsage() libkb.Usage {
+	return libkb.Usage{
+		Config:    true,
+		API:       true,
+		Terminal:  true,
+		KbKeyring: true,
+	}
+}
+
+//=============================================================================
+
+type CmdDevicePaperKey struct{}
+
+func (v *CmdDevicePaperKey) ParseArgv(ctx *cli.Context) error {
+	if len(ctx.Args()) != 0 {
+		return fmt.Errorf("paperkey takes no args")
+	}
+	return nil
+}
+
+func (v *CmdDevicePaperKey) RunClient() error { return v.Run() }
+
+func (v *CmdDevicePaperKey) Run() error {
+	return libkb.NewPaperKeyGenEngine(&libkb.PaperKeyGenArg{
+		SecretUI: G_UI.GetSecretUI(),
+	}).Run()
+}
+
+func NewCmdDevicePaperKey(cl *libcmdline.CommandLine) cli.Command {
+	return cli.Command{
+		Name:        "paperkey",
+		Usage:       "keybase device paperkey",
+		Description: "Make a paper backup key, to sign in new devices if all of yours are lost",
+		Action: func(c *cli.Context) {
+			cl.ChooseCommand(&CmdDevicePaperKey{}, "paperkey", c)
+		},
+	}
+}
+
+func (v *CmdDevicePaperKey) GetUsage() libkb.Usage {
+	return libkb.Usage{
+		Config:    true,
+		API:       true,
+		Terminal:  true,
+		KbKeyring: true,
+	}
+}
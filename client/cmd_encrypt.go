@@ -0,0 +1,118 @@
+package main
+
+import (
+	"fmt"
+	"github.com/codegangsta/cli"
+	"github.com/keybase/go/libcmdline"
+	"github.com/keybase/go/libkb"
+)
+
+func NewCmdEncrypt(cl *libcmdline.CommandLine) cli.Command {
+	return cli.Command{
+		Name:        "encrypt",
+		Usage:       "keybase encrypt [-b] [-s] [--nacl [--stream]] [-i <infile>] [-o <outfile>] <them>...",
+		Description: "encrypt a message for one or more keybase users",
+		Action: func(c *cli.Context) {
+			cl.ChooseCommand(&CmdEncrypt{}, "encrypt", c)
+		},
+		Flags: []cli.Flag{
+			cli.BoolFlag{
+				Name:  "b, binary",
+				Usage: "output binary message (armored by default)",
+			},
+			cli.BoolFlag{
+				Name:  "s, sign",
+				Usage: "also sign the message with your key",
+			},
+			cli.BoolFlag{
+				Name:  "nacl",
+				Usage: "encrypt for recipients' device keys with NaCl, rather than PGP",
+			},
+			cli.BoolFlag{
+				Name:  "stream",
+				Usage: "with --nacl, write a chunked stream, for large inputs",
+			},
+			cli.BoolFlag{
+				Name:  "no-self",
+				Usage: "don't encrypt for yourself too",
+			},
+			cli.StringFlag{
+				Name:  "m, message",
+				Usage: "provide the message to encrypt on the command line",
+			},
+			cli.StringFlag{
+				Name:  "i, infile",
+				Usage: "specify an infile (stdin by default)",
+			},
+			cli.StringFlag{
+				Name:  "o, outfile",
+				Usage: "specify an outfile (stdout by default)",
+			},
+		},
+	}
+}
+
+type CmdEncrypt struct {
+	UnixFilter
+	recipients []string
+	binary     bool
+	sign       bool
+	noSelf     bool
+	nacl       bool
+	stream     bool
+}
+
+func (c *CmdEncrypt) ParseArgv(ctx *cli.Context) error {
+	c.recipients = ctx.Args()
+	if len(c.recipients) == 0 {
+		return fmt.Errorf("encrypt needs at least one recipient")
+	}
+	c.binary = ctx.Bool("binary")
+	c.sign = ctx.Bool("sign")
+	c.noSelf = ctx.Bool("no-self")
+	c.nacl = ctx.Bool("nacl")
+	c.stream = ctx.Bool("stream")
+	if c.nacl && c.sign {
+		return fmt.Errorf("--nacl can't be used with --sign")
+	}
+	if c.stream && !c.nacl {
+		return fmt.Errorf("--stream only works with --nacl")
+	}
+	if c.nacl && c.binary && !c.stream {
+		return fmt.Errorf("--nacl --binary needs --stream")
+	}
+	return c.FilterInit(ctx.String("message"), ctx.String("infile"), ctx.String("outfile"))
+}
+
+func (c *CmdEncrypt) RunClient() (err error) { return c.Run() }
+
+func (c *CmdEncrypt) Run() (err error) {
+	if err = c.FilterOpen(); err != nil {
+		return
+	}
+	defer func() {
+		c.Close(err)
+	}()
+
+	arg := libkb.EncryptArg{
+		Recipients: c.recipients,
+		Source:     c.source,
+		Sink:       c.sink,
+		Binary:     c.binary,
+		NoSelf:     c.noSelf,
+		Sign:       c.sign,
+		Nacl:       c.nacl,
+		Stream:     c.stream,
+	}
+	err = libkb.NewEncryptEngine(&arg).Run()
+	return
+}
+
+func (c *CmdEncrypt) GetUsage() libkb.Usage {
+	return libkb.Usage{
+		Config:    true,
+		API:       true,
+		Terminal:  true,
+		KbKeyring: true,
+	}
+}
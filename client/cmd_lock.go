@@ -0,0 +1,43 @@
+package main
+
+import (
+	"github.com/codegangsta/cli"
+	"github.com/keybase/go/libcmdline"
+	"github.com/keybase/go/libkb"
+	"github.com/keybase/protocol/go"
+)
+
+type CmdLock struct{}
+
+func (v *CmdLock) RunClient() (err error) {
+	var cli keybase_1.SecretCacheClient
+	if cli, err = GetSecretCacheClient(); err == nil {
+		err = cli.Lock()
+	}
+	return
+}
+
+// Run is a no-op in standalone mode, since only the daemon holds onto
+// unlocked keys.
+func (v *CmdLock) Run() (err error) {
+	G.SecretCache.Clear()
+	return
+}
+
+func NewCmdLock(cl *libcmdline.CommandLine) cli.Command {
+	return cli.Command{
+		Name:  "lock",
+		Usage: "Make the daemon forget your unlocked secret keys",
+		Action: func(c *cli.Context) {
+			cl.ChooseCommand(&CmdLock{}, "lock", c)
+		},
+	}
+}
+
+func (v *CmdLock) GetUsage() libkb.Usage {
+	return libkb.Usage{
+		Config: true,
+	}
+}
+
+func (c *CmdLock) ParseArgv(*cli.Context) error { return nil }
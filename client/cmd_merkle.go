@@ -0,0 +1,252 @@
+package main
+
+import (
+	"fmt"
+	"github.com/codegangsta/cli"
+	"github.com/keybase/go-jsonw"
+	"github.com/keybase/go/libcmdline"
+	"github.com/keybase/go/libkb"
+	"io/ioutil"
+	"os"
+	"time"
+)
+
+func NewCmdMerkle(cl *libcmdline.CommandLine) cli.Command {
+	return cli.Command{
+		Name:        "merkle",
+		Usage:       "keybase merkle [subcommands...]",
+		Description: "Check the server's Merkle tree, and prove users' places in it",
+		Subcommands: []cli.Command{
+			NewCmdMerkleCheck(cl),
+			NewCmdMerkleLog(cl),
+			NewCmdMerkleProve(cl),
+			NewCmdMerkleVerify(cl),
+		},
+	}
+}
+
+func displayMerkleLogEntry(e *libkb.MerkleRootLogEntry) {
+	r := e.Root
+	fmt.Fprintf(os.Stdout, "%d\t%s\t%s", int(r.GetSeqno()), libkb.FormatTime(r.GetCTime()),
+		r.GetRootHash())
+	if !e.AddedAt.IsZero() {
+		fmt.Fprintf(os.Stdout, "\t(seen %s)", libkb.FormatTime(e.AddedAt))
+	}
+	fmt.Fprintf(os.Stdout, "\n")
+}
+
+//=============================================================================
+
+type CmdMerkleLog struct{}
+
+func (v *CmdMerkleLog) ParseArgv(ctx *cli.Context) error {
+	if len(ctx.Args()) != 0 {
+		return fmt.Errorf("log takes no args")
+	}
+	return nil
+}
+
+func (v *CmdMerkleLog) RunClient() error { return v.Run() }
+
+func (v *CmdMerkleLog) Run() (err error) {
+	var log []*libkb.MerkleRootLogEntry
+	if log, err = G.MerkleClient.Log(); err != nil {
+		return
+	}
+	if len(log) == 0 {
+		G.Log.Info("No Merkle roots logged yet")
+	}
+	for _, e := range log {
+		displayMerkleLogEntry(e)
+	}
+	return
+}
+
+func NewCmdMerkleLog(cl *libcmdline.CommandLine) cli.Command {
+	return cli.Command{
+		Name:        "log",
+		Usage:       "keybase merkle log",
+		Description: "List the Merkle roots we've verified, newest first",
+		Action: func(c *cli.Context) {
+			cl.ChooseCommand(&CmdMerkleLog{}, "log", c)
+		},
+	}
+}
+
+func (v *CmdMerkleLog) GetUsage() libkb.Usage {
+	return libkb.Usage{
+		Config: true,
+	}
+}
+
+//=============================================================================
+
+type CmdMerkleCheck struct{}
+
+func (v *CmdMerkleCheck) ParseArgv(ctx *cli.Context) error {
+	if len(ctx.Args()) != 0 {
+		return fmt.Errorf("check takes no args")
+	}
+	return nil
+}
+
+func (v *CmdMerkleCheck) RunClient() error { return v.Run() }
+
+func (v *CmdMerkleCheck) Run() (err error) {
+	var log []*libkb.MerkleRootLogEntry
+	if log, err = G.MerkleClient.CheckLog(); err != nil {
+		return
+	}
+	G.Log.Info("%s Local log of %d Merkle root(s) checks out", CHECK, len(log))
+
+	var root *libkb.MerkleRoot
+	if root, err = G.MerkleClient.FetchRoot(); err != nil {
+		return
+	}
+	G.Log.Info("%s Server's current root %d is consistent with our log", CHECK,
+		int(root.GetSeqno()))
+	return
+}
+
+func NewCmdMerkleCheck(cl *libcmdline.CommandLine) cli.Command {
+	return cli.Command{
+		Name:        "check",
+		Usage:       "keybase merkle check",
+		Description: "Recheck our Merkle root log, and the server's current root against it",
+		Action: func(c *cli.Context) {
+			cl.ChooseCommand(&CmdMerkleCheck{}, "check", c)
+		},
+	}
+}
+
+func (v *CmdMerkleCheck) GetUsage() libkb.Usage {
+	return libkb.Usage{
+		Config: true,
+		API:    true,
+	}
+}
+
+//=============================================================================
+
+type CmdMerkleProve struct {
+	username string
+	outfile  string
+}
+
+func (v *CmdMerkleProve) ParseArgv(ctx *cli.Context) error {
+	if len(ctx.Args()) != 1 {
+		return fmt.Errorf("prove takes 1 arg, a username")
+	}
+	v.username = ctx.Args()[0]
+	v.outfile = ctx.String("outfile")
+	return nil
+}
+
+func (v *CmdMerkleProve) RunClient() error { return v.Run() }
+
+func (v *CmdMerkleProve) Run() (err error) {
+	var jw *jsonw.Wrapper
+	if jw, err = libkb.ExportMerkleProof(v.username); err != nil {
+		return
+	}
+	out := jw.MarshalPretty() + "\n"
+	if len(v.outfile) == 0 || v.outfile == "-" {
+		_, err = os.Stdout.Write([]byte(out))
+	} else {
+		err = ioutil.WriteFile(v.outfile, []byte(out), os.FileMode(0644))
+	}
+	return
+}
+
+func NewCmdMerkleProve(cl *libcmdline.CommandLine) cli.Command {
+	return cli.Command{
+		Name:        "prove",
+		Usage:       "keybase merkle prove [-o <outfile>] <username>",
+		Description: "Write a user's path in the Merkle tree, with the signed root, as JSON",
+		Flags: []cli.Flag{
+			cli.StringFlag{
+				Name:  "o, outfile",
+				Usage: "specify an outfile (stdout by default)",
+			},
+		},
+		Action: func(c *cli.Context) {
+			cl.ChooseCommand(&CmdMerkleProve{}, "prove", c)
+		},
+	}
+}
+
+func (v *CmdMerkleProve) GetUsage() libkb.Usage {
+	return libkb.Usage{
+		Config: true,
+		API:    true,
+	}
+}
+
+//=============================================================================
+
+type CmdMerkleVerify struct {
+	infile string
+}
+
+func (v *CmdMerkleVerify) ParseArgv(ctx *cli.Context) error {
+	nargs := len(ctx.Args())
+	if nargs == 1 {
+		v.infile = ctx.Args()[0]
+	} else if nargs > 1 {
+		return fmt.Errorf("verify takes at most 1 arg, an infile")
+	}
+	return nil
+}
+
+func (v *CmdMerkleVerify) RunClient() error { return v.Run() }
+
+func (v *CmdMerkleVerify) Run() (err error) {
+	var data []byte
+	if len(v.infile) == 0 || v.infile == "-" {
+		data, err = ioutil.ReadAll(os.Stdin)
+	} else {
+		data, err = ioutil.ReadFile(v.infile)
+	}
+	if err != nil {
+		return
+	}
+
+	var jw *jsonw.Wrapper
+	if jw, err = jsonw.Unmarshal(data); err != nil {
+		return
+	}
+	var sum *libkb.MerkleProofSummary
+	if sum, err = libkb.VerifyMerkleProof(jw); err != nil {
+		return
+	}
+	fmt.Printf("%s Merkle proof for uid %s verified\n", CHECK, sum.Uid)
+	if len(sum.UnverifiedName) > 0 {
+		fmt.Printf("\tusername:  %s (as given to 'merkle prove'; not proven)\n", sum.UnverifiedName)
+	}
+	fmt.Printf("\troot:      #%d, %s\n", int(sum.RootSeqno), libkb.FormatTime(time.Unix(sum.RootCTime, 0)))
+	fmt.Printf("\troot hash: %s\n", sum.RootHash)
+	fmt.Printf("\tsigned by: %s\n", sum.RootSigner.ToQuads())
+	if sum.TailSeqno < 0 {
+		fmt.Printf("\tchain:     no public sigchain\n")
+	} else {
+		fmt.Printf("\tchain:     tail at seqno %d, link %s\n", int(sum.TailSeqno), sum.TailLinkId)
+	}
+	return
+}
+
+func NewCmdMerkleVerify(cl *libcmdline.CommandLine) cli.Command {
+	return cli.Command{
+		Name:        "verify",
+		Usage:       "keybase merkle verify [<infile>]",
+		Description: "Verify a proof from 'merkle prove', against our trusted Merkle keys",
+		Action: func(c *cli.Context) {
+			cl.ChooseCommand(&CmdMerkleVerify{}, "verify", c)
+		},
+	}
+}
+
+func (v *CmdMerkleVerify) GetUsage() libkb.Usage {
+	return libkb.Usage{
+		Config: true,
+	}
+}
@@ -0,0 +1,59 @@
+package main
+
+import (
+	"fmt"
+	"github.com/codegangsta/cli"
+	"github.com/keybase/go/libcmdline"
+	"github.com/keybase/go/libkb"
+)
+
+func NewCmdPassphrase(cl *libcmdline.CommandLine) cli.Command {
+	return cli.Command{
+		Name:        "passphrase",
+		Usage:       "keybase passphrase [subcommands...]",
+		Description: "Manage your Keybase passphrase",
+		Subcommands: []cli.Command{
+			NewCmdPassphraseChange(cl),
+		},
+	}
+}
+
+//=============================================================================
+
+type CmdPassphraseChange struct{}
+
+func (v *CmdPassphraseChange) ParseArgv(ctx *cli.Context) error {
+	if len(ctx.Args()) != 0 {
+		return fmt.Errorf("change takes no args")
+	}
+	return nil
+}
+
+func (v *CmdPassphraseChange) RunClient() error { return v.Run() }
+
+func (v *CmdPassphraseChange) Run() error {
+	return libkb.NewPassphraseChangeEngine(&libkb.PassphraseChangeArg{
+		LoginUI:  G_UI.GetLoginUI(),
+		SecretUI: G_UI.GetSecretUI(),
+	}).Run()
+}
+
+func NewCmdPassphraseChange(cl *libcmdline.CommandLine) cli.Command {
+	return cli.Command{
+		Name:        "change",
+		Usage:       "keybase passphrase change",
+		Description: "Change your passphrase, and re-encrypt your secret keys with it",
+		Action: func(c *cli.Context) {
+			cl.ChooseCommand(&CmdPassphraseChange{}, "change", c)
+		},
+	}
+}
+
+func (v *CmdPassphraseChange) GetUsage() libkb.Usage {
+	return libkb.Usage{
+		Config:    true,
+		API:       true,
+		Terminal:  true,
+		KbKeyring: true,
+	}
+}
@@ -0,0 +1,18 @@
+package main
+
+import (
+	"github.com/codegangsta/cli"
+	"github.com/keybase/go/libcmdline"
+)
+
+func NewCmdPgp(cl *libcmdline.CommandLine) cli.Command {
+	return cli.Command{
+		Name:        "pgp",
+		Usage:       "keybase pgp [subcommands...]",
+		Description: "Move PGP keys into and out of your Keybase keyring",
+		Subcommands: []cli.Command{
+			NewCmdPgpExport(cl),
+			NewCmdPgpImport(cl),
+		},
+	}
+}
@@ -0,0 +1,101 @@
+package main
+
+import (
+	"fmt"
+	"github.com/codegangsta/cli"
+	"github.com/keybase/go/libcmdline"
+	"github.com/keybase/go/libkb"
+)
+
+type CmdPgpExport struct {
+	arg     libkb.PgpExportArg
+	outfile string
+}
+
+func (v *CmdPgpExport) ParseArgv(ctx *cli.Context) (err error) {
+	nargs := len(ctx.Args())
+	if nargs == 1 {
+		v.arg.Username = ctx.Args()[0]
+	} else if nargs > 1 {
+		return fmt.Errorf("pgp export takes at most 1 argument, a username")
+	}
+	v.arg.Secret = ctx.Bool("secret")
+	v.arg.Encrypt = ctx.Bool("encrypt")
+	v.arg.Gpg = ctx.Bool("gpg")
+	v.outfile = ctx.String("outfile")
+
+	if v.arg.Secret && len(v.arg.Username) > 0 {
+		err = fmt.Errorf("can only export your own secret key")
+	} else if v.arg.Encrypt && !v.arg.Secret {
+		err = fmt.Errorf("--encrypt only applies to secret keys")
+	} else if v.arg.Gpg && len(v.outfile) > 0 {
+		err = fmt.Errorf("can't specify an outfile when exporting to GnuPG")
+	}
+	return
+}
+
+func (v *CmdPgpExport) RunClient() error { return v.Run() }
+
+func (v *CmdPgpExport) Run() (err error) {
+	var sink Sink
+	if v.arg.Gpg {
+	} else if len(v.outfile) == 0 || v.outfile == "-" {
+		sink = &StdoutSink{}
+	} else {
+		sink = NewFileSink(v.outfile)
+	}
+
+	if sink != nil {
+		if err = sink.Open(); err != nil {
+			return
+		}
+		defer func() {
+			sink.Close()
+			sink.HitError(err)
+		}()
+		v.arg.Sink = sink
+	}
+
+	v.arg.SecretUI = G_UI.GetSecretUI()
+	err = libkb.NewPgpExportEngine(&v.arg).Run()
+	return
+}
+
+func NewCmdPgpExport(cl *libcmdline.CommandLine) cli.Command {
+	return cli.Command{
+		Name:        "export",
+		Usage:       "keybase pgp export [-s [-e]] [--gpg] [-o <outfile>] [<username>]",
+		Description: "Export a PGP key from your Keybase keyring, or a user's public key",
+		Flags: []cli.Flag{
+			cli.BoolFlag{
+				Name:  "s, secret",
+				Usage: "export your secret key too",
+			},
+			cli.BoolFlag{
+				Name:  "e, encrypt",
+				Usage: "protect the exported secret key with a new passphrase",
+			},
+			cli.BoolFlag{
+				Name:  "gpg",
+				Usage: "import the key into your GnuPG keyring rather than writing it out",
+			},
+			cli.StringFlag{
+				Name:  "o, outfile",
+				Usage: "specify an outfile (stdout by default)",
+			},
+		},
+		Action: func(c *cli.Context) {
+			cl.ChooseCommand(&CmdPgpExport{}, "export", c)
+		},
+	}
+}
+
+func (v *CmdPgpExport) GetUsage() libkb.Usage {
+	return libkb.Usage{
+		Config:     true,
+		KbKeyring:  true,
+		GpgKeyring: v.arg.Gpg,
+		API:        true,
+		Terminal:   true,
+	}
+}
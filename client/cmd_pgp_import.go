@@ -0,0 +1,103 @@
+package main
+
+import (
+	"fmt"
+	"github.com/codegangsta/cli"
+	"github.com/keybase/go/libcmdline"
+	"github.com/keybase/go/libkb"
+	"io/ioutil"
+	"os"
+)
+
+type CmdPgpImport struct {
+	state  MyKeyState
+	infile string
+}
+
+func (v *CmdPgpImport) ParseArgv(ctx *cli.Context) (err error) {
+	nargs := len(ctx.Args())
+	if err = v.state.ParseArgv(ctx); err != nil {
+		return
+	}
+	v.infile = ctx.String("infile")
+	if nargs == 1 && len(v.infile) == 0 {
+		v.infile = ctx.Args()[0]
+	} else if nargs != 0 {
+		err = fmt.Errorf("pgp import takes at most 1 argument, an infile")
+	}
+
+	// The secret key is always locked with the Keybase passphrase; it's
+	// only pushed to the server if asked for.
+	v.state.arg.KbPassphrase = true
+	v.state.interactive = false
+	return
+}
+
+func (v *CmdPgpImport) RunClient() error { return v.Run() }
+
+func (v *CmdPgpImport) Run() (err error) {
+	v.state.arg.SecretUI = G_UI.GetSecretUI()
+	gen := libkb.NewKeyGen(&v.state.arg)
+
+	if err = gen.LoginAndCheckKey(); err != nil {
+		return
+	}
+	if err = v.readKey(); err != nil {
+		return
+	}
+	_, err = gen.Run()
+	return
+}
+
+func (v *CmdPgpImport) readKey() (err error) {
+	var data []byte
+	if len(v.infile) == 0 || v.infile == "-" {
+		data, err = ioutil.ReadAll(os.Stdin)
+	} else {
+		data, err = ioutil.ReadFile(v.infile)
+	}
+	if err != nil {
+		return
+	}
+
+	var key *libkb.PgpKeyBundle
+	if key, err = libkb.ReadOneSecretKey(data); err != nil {
+		return
+	}
+	if err = key.Unlock("Import of key into keybase keyring", G_UI.GetSecretUI()); err != nil {
+		return
+	}
+	G.Log.Info("Importing key %s", key.GetFingerprint().ToQuads())
+	v.state.arg.Pregen = key
+	return
+}
+
+func NewCmdPgpImport(cl *libcmdline.CommandLine) cli.Command {
+	return cli.Command{
+		Name:        "import",
+		Usage:       "keybase pgp import [--push-secret] [<infile>]",
+		Description: "Import a PGP secret key from a file, and push it to the server as your key",
+		Flags: append([]cli.Flag{
+			cli.StringFlag{
+				Name:  "i, infile",
+				Usage: "specify an infile (stdin by default)",
+			},
+			cli.BoolFlag{
+				Name:  "push-secret",
+				Usage: "Also push secret key to server (protected by your Keybase passphrase)",
+			},
+		}, mykeyFlags()...),
+		Action: func(c *cli.Context) {
+			cl.ChooseCommand(&CmdPgpImport{}, "import", c)
+		},
+	}
+}
+
+func (v *CmdPgpImport) GetUsage() libkb.Usage {
+	return libkb.Usage{
+		Config:    true,
+		KbKeyring: true,
+		API:       true,
+		Terminal:  true,
+	}
+}
@@ -0,0 +1,125 @@
+package main
+
+import (
+	"fmt"
+	"github.com/codegangsta/cli"
+	"github.com/keybase/go/libcmdline"
+	"github.com/keybase/go/libkb"
+)
+
+func NewCmdRevoke(cl *libcmdline.CommandLine) cli.Command {
+	return cli.Command{
+		Name:        "revoke",
+		Usage:       "keybase revoke [subcommands...]",
+		Description: "Revoke keys or signatures, say if a device is lost",
+		Subcommands: []cli.Command{
+			NewCmdRevokeKey(cl),
+			NewCmdRevokeSig(cl),
+		},
+	}
+}
+
+type CmdRevoke struct {
+	arg   libkb.RevokeArg
+	paper bool
+}
+
+func (v *CmdRevoke) RunClient() error { return v.Run() }
+
+func (v *CmdRevoke) Run() (err error) {
+	if v.paper {
+		if v.arg.PaperKey, err = promptForPaperKey(); err != nil {
+			return
+		}
+	}
+	v.arg.SecretUI = G_UI.GetSecretUI()
+	return libkb.NewRevokeEngine(&v.arg).Run()
+}
+
+var revokeFlags = []cli.Flag{
+	cli.BoolFlag{
+		Name:  "p, paper",
+		Usage: "sign the revocation with your paper key",
+	},
+}
+
+func (v *CmdRevoke) GetUsage() libkb.Usage {
+	return libkb.Usage{
+		Config:    true,
+		API:       true,
+		Terminal:  true,
+		KbKeyring: true,
+	}
+}
+
+//=============================================================================
+
+type CmdRevokeKey struct {
+	CmdRevoke
+}
+
+func (v *CmdRevokeKey) ParseArgv(ctx *cli.Context) (err error) {
+	if len(ctx.Args()) == 0 {
+		return fmt.Errorf("revoke key takes one or more KIDs")
+	}
+	v.paper = ctx.Bool("paper")
+	for _, s := range ctx.Args() {
+		var kid libkb.KID
+		if kid, err = libkb.ImportKID(s); err != nil {
+			return fmt.Errorf("Bad KID '%s': %s", s, err.Error())
+		}
+		v.arg.Kids = append(v.arg.Kids, kid)
+	}
+	return
+}
+
+func NewCmdRevokeKey(cl *libcmdline.CommandLine) cli.Command {
+	return cli.Command{
+		Name:        "key",
+		Usage:       "keybase revoke key [-p] <kid>...",
+		Description: "Revoke one or more of your keys",
+		Flags:       revokeFlags,
+		Action: func(c *cli.Context) {
+			cl.ChooseCommand(&CmdRevokeKey{}, "key", c)
+		},
+	}
+}
+
+//=============================================================================
+
+type CmdRevokeSig struct {
+	CmdRevoke
+}
+
+func (v *CmdRevokeSig) ParseArgv(ctx *cli.Context) (err error) {
+	if len(ctx.Args()) == 0 {
+		return fmt.Errorf("revoke sig takes one or more signature IDs")
+	}
+	v.paper = ctx.Bool("paper")
+	for _, s := range ctx.Args() {
+		var id *libkb.SigId
+		// Accept signature IDs with or without the trailing suffix byte.
+		if len(s) == 2*libkb.SIG_ID_LEN {
+			id, err = libkb.SigIdFromHex(s, false)
+		} else {
+			id, err = libkb.SigIdFromHex(s, true)
+		}
+		if err != nil {
+			return fmt.Errorf("Bad signature ID '%s': %s", s, err.Error())
+		}
+		v.arg.Sigs = append(v.arg.Sigs, *id)
+	}
+	return
+}
+
+func NewCmdRevokeSig(cl *libcmdline.CommandLine) cli.Command {
+	return cli.Command{
+		Name:        "sig",
+		Usage:       "keybase revoke sig [-p] <sigid>...",
+		Description: "Revoke one or more of your signatures, such as a key delegation",
+		Flags:       revokeFlags,
+		Action: func(c *cli.Context) {
+			cl.ChooseCommand(&CmdRevokeSig{}, "sig", c)
+		},
+	}
+}
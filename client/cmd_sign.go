@@ -11,7 +11,7 @@ import (
 func NewCmdSign(cl *libcmdline.CommandLine) cli.Command {
 	return cli.Command{
 		Name:        "sign",
-		Usage:       "keybase sign [-a] [-o <outfile>] [<infile>]",
+		Usage:       "keybase sign [-b] [-d|-t] [--nacl] [-o <outfile>] [<infile>]",
 		Description: "sign a clear document",
 		Action: func(c *cli.Context) {
 			cl.ChooseCommand(&CmdSign{}, "sign", c)
@@ -21,6 +21,18 @@ func NewCmdSign(cl *libcmdline.CommandLine) cli.Command {
 				Name:  "b, binary",
 				Usage: "output binary message (armored by default",
 			},
+			cli.BoolFlag{
+				Name:  "d, detached",
+				Usage: "output a detached signature",
+			},
+			cli.BoolFlag{
+				Name:  "t, clearsign",
+				Usage: "output a clearsigned message",
+			},
+			cli.BoolFlag{
+				Name:  "nacl",
+				Usage: "sign with this device's NaCl key rather than a PGP key",
+			},
 			cli.StringFlag{
 				Name:  "m, message",
 				Usage: "provide the message to sign on the command line",
@@ -35,8 +47,11 @@ func NewCmdSign(cl *libcmdline.CommandLine) cli.Command {
 
 type CmdSign struct {
 	UnixFilter
-	binary bool
-	msg    string
+	binary    bool
+	detached  bool
+	clearsign bool
+	nacl      bool
+	msg       string
 }
 
 func (s *CmdSign) ParseArgv(ctx *cli.Context) error {
@@ -44,6 +59,9 @@ func (s *CmdSign) ParseArgv(ctx *cli.Context) error {
 	var err error
 
 	s.binary = ctx.Bool("binary")
+	s.detached = ctx.Bool("detached")
+	s.clearsign = ctx.Bool("clearsign")
+	s.nacl = ctx.Bool("nacl")
 	msg := ctx.String("message")
 	outfile := ctx.String("outfile")
 	var infile string
@@ -54,7 +72,16 @@ func (s *CmdSign) ParseArgv(ctx *cli.Context) error {
 		err = fmt.Errorf("sign takes at most 1 arg, an infile")
 	}
 
-	if err == nil {
+	if err != nil {
+	} else if s.detached && s.clearsign {
+		err = fmt.Errorf("can't make a signature both detached and clearsigned")
+	} else if s.clearsign && s.binary {
+		err = fmt.Errorf("clearsigned messages are always armored")
+	} else if s.nacl && s.clearsign {
+		err = fmt.Errorf("NaCl signatures can't be clearsigned")
+	} else if s.nacl && s.detached && s.binary {
+		err = fmt.Errorf("detached NaCl signatures are always armored")
+	} else {
 		err = s.FilterInit(msg, infile, outfile)
 	}
 
@@ -81,6 +108,17 @@ func (s *CmdSign) Run() (err error) {
 		s.Close(err)
 	}()
 
+	if s.nacl {
+		arg := libkb.NaclSignArg{
+			Source:   s.source,
+			Sink:     s.sink,
+			Detached: s.detached,
+			Binary:   s.binary,
+		}
+		err = libkb.NewNaclSignEngine(&arg).Run()
+		return
+	}
+
 	key, err = G.Keyrings.GetSecretKey("command-line signature", nil)
 	if err != nil {
 		return
@@ -92,7 +130,13 @@ func (s *CmdSign) Run() (err error) {
 		return
 	}
 
-	dumpTo, err = libkb.AttachedSignWrapper(s.sink, *pgp, !s.binary)
+	if s.detached {
+		dumpTo, err = libkb.DetachedSignWrapper(s.sink, *pgp, !s.binary)
+	} else if s.clearsign {
+		dumpTo, err = libkb.ClearSignWrapper(s.sink, *pgp)
+	} else {
+		dumpTo, err = libkb.AttachedSignWrapper(s.sink, *pgp, !s.binary)
+	}
 	if err != nil {
 		return
 	}
@@ -0,0 +1,113 @@
+package main
+
+import (
+	"fmt"
+	"github.com/codegangsta/cli"
+	"github.com/keybase/go/libcmdline"
+	"github.com/keybase/go/libkb"
+	"os"
+)
+
+type CmdSigsAudit struct {
+	username string
+}
+
+func (v *CmdSigsAudit) ParseArgv(ctx *cli.Context) (err error) {
+	nargs := len(ctx.Args())
+	if nargs == 1 {
+		v.username = ctx.Args()[0]
+	} else if nargs > 1 {
+		err = fmt.Errorf("audit takes at most 1 arg, a username")
+	}
+	return
+}
+
+func (v *CmdSigsAudit) RunClient() error { return v.Run() }
+
+func (v *CmdSigsAudit) Run() (err error) {
+	if len(v.username) == 0 {
+		if v.username = G.Env.GetUsername(); len(v.username) == 0 {
+			return fmt.Errorf("No username given, and you're not logged in")
+		}
+	}
+
+	var audits []*libkb.LinkAudit
+	if _, audits, err = libkb.AuditSigChain(v.username); err != nil {
+		return
+	}
+
+	nbad := 0
+	for _, a := range audits {
+		if a.Err != nil {
+			nbad++
+		}
+		v.display(a)
+	}
+	if nbad > 0 {
+		err = fmt.Errorf("%d of %d link(s) failed the audit", nbad, len(audits))
+	}
+	return
+}
+
+func (v *CmdSigsAudit) display(a *libkb.LinkAudit) {
+	w := os.Stdout
+	status := CHECK
+	if a.Err != nil {
+		status = BADX
+	}
+	if a.Link == nil {
+		fmt.Fprintf(w, "%s #%d\t(unparseable)\n", status, int(a.Seqno))
+		fmt.Fprintf(w, "\t%s\n", ColorString("red", a.Err.Error()))
+		return
+	}
+
+	fmt.Fprintf(w, "%s #%d\t%s\t%s\n", status, int(a.Seqno), a.Type,
+		libkb.FormatTime(a.Link.GetCTime()))
+
+	prev := ColorString("green", "ok")
+	if !a.PrevOk {
+		prev = ColorString("red", "mismatch")
+	}
+	fmt.Fprintf(w, "\tprev:      %s\n", prev)
+
+	var key string
+	if !a.Replayed {
+		key = ColorString("yellow", "not replayed; predates the current eldest key")
+	} else if a.ActiveSibkey {
+		key = ColorString("green", "active sibkey")
+	} else {
+		key = ColorString("red", "not an active sibkey")
+	}
+	fmt.Fprintf(w, "\tsigned by: %s (%s)\n", a.SigningKey.String(), key)
+
+	if a.MerkleSeqno > 0 {
+		fmt.Fprintf(w, "\tmerkle:    root #%d\n", a.MerkleSeqno)
+	} else {
+		fmt.Fprintf(w, "\tmerkle:    none\n")
+	}
+	if a.RevokedBy != nil {
+		fmt.Fprintf(w, "\trevoked:   %s\n", ColorString("magenta",
+			fmt.Sprintf("by #%d", int(a.RevokedBy.Seqno))))
+	}
+	if a.Err != nil {
+		fmt.Fprintf(w, "\terror:     %s\n", ColorString("red", a.Err.Error()))
+	}
+}
+
+func NewCmdSigsAudit(cl *libcmdline.CommandLine) cli.Command {
+	return cli.Command{
+		Name:        "audit",
+		Usage:       "keybase sigs audit [<username>]",
+		Description: "Replay a user's sigchain and explain every link (yours by default)",
+		Action: func(c *cli.Context) {
+			cl.ChooseCommand(&CmdSigsAudit{}, "audit", c)
+		},
+	}
+}
+
+func (v *CmdSigsAudit) GetUsage() libkb.Usage {
+	return libkb.Usage{
+		Config: true,
+		API:    true,
+	}
+}
@@ -0,0 +1,128 @@
+package main
+
+import (
+	"fmt"
+	"github.com/codegangsta/cli"
+	"github.com/keybase/go-jsonw"
+	"github.com/keybase/go/libcmdline"
+	"github.com/keybase/go/libkb"
+	"io/ioutil"
+	"os"
+	"time"
+)
+
+type CmdSigsExport struct {
+	username string
+	outfile  string
+}
+
+func (v *CmdSigsExport) ParseArgv(ctx *cli.Context) error {
+	if len(ctx.Args()) != 1 {
+		return fmt.Errorf("export takes 1 arg, a username")
+	}
+	v.username = ctx.Args()[0]
+	v.outfile = ctx.String("outfile")
+	return nil
+}
+
+func (v *CmdSigsExport) RunClient() error { return v.Run() }
+
+func (v *CmdSigsExport) Run() (err error) {
+	var jw *jsonw.Wrapper
+	if jw, err = libkb.ExportSigBundle(v.username); err != nil {
+		return
+	}
+	out := jw.MarshalPretty() + "\n"
+	if len(v.outfile) == 0 || v.outfile == "-" {
+		_, err = os.Stdout.Write([]byte(out))
+	} else {
+		err = ioutil.WriteFile(v.outfile, []byte(out), os.FileMode(0644))
+	}
+	return
+}
+
+func NewCmdSigsExport(cl *libcmdline.CommandLine) cli.Command {
+	return cli.Command{
+		Name:        "export",
+		Usage:       "keybase sigs export [-o <outfile>] <username>",
+		Description: "Export a user's sigchain as a bundle that can be verified offline",
+		Flags: []cli.Flag{
+			cli.StringFlag{
+				Name:  "o, outfile",
+				Usage: "specify an outfile (stdout by default)",
+			},
+		},
+		Action: func(c *cli.Context) {
+			cl.ChooseCommand(&CmdSigsExport{}, "export", c)
+		},
+	}
+}
+
+func (v *CmdSigsExport) GetUsage() libkb.Usage {
+	return libkb.Usage{
+		Config: true,
+		API:    true,
+	}
+}
+
+//=============================================================================
+
+type CmdSigsVerifyBundle struct {
+	infile string
+}
+
+func (v *CmdSigsVerifyBundle) ParseArgv(ctx *cli.Context) error {
+	nargs := len(ctx.Args())
+	if nargs == 1 {
+		v.infile = ctx.Args()[0]
+	} else if nargs > 1 {
+		return fmt.Errorf("verify-bundle takes at most 1 arg, an infile")
+	}
+	return nil
+}
+
+func (v *CmdSigsVerifyBundle) RunClient() error { return v.Run() }
+
+func (v *CmdSigsVerifyBundle) Run() (err error) {
+	var data []byte
+	if len(v.infile) == 0 || v.infile == "-" {
+		data, err = ioutil.ReadAll(os.Stdin)
+	} else {
+		data, err = ioutil.ReadFile(v.infile)
+	}
+	if err != nil {
+		return
+	}
+
+	var jw *jsonw.Wrapper
+	if jw, err = jsonw.Unmarshal(data); err != nil {
+		return
+	}
+	var sum *libkb.SigBundleSummary
+	if sum, err = libkb.VerifySigBundle(jw); err != nil {
+		return
+	}
+	fmt.Printf("%s Bundle for %s (%s) verified\n", CHECK, sum.Username, sum.Uid)
+	fmt.Printf("\t%d links, tail at seqno %d\n", sum.Links, int(sum.Tail))
+	fmt.Printf("\tMerkle root %d, signed %s\n", int(sum.MerkleSeqno),
+		libkb.FormatTime(time.Unix(sum.MerkleCTime, 0)))
+	fmt.Printf("\t%d sig hints\n", sum.Hints)
+	return
+}
+
+func NewCmdSigsVerifyBundle(cl *libcmdline.CommandLine) cli.Command {
+	return cli.Command{
+		Name:        "verify-bundle",
+		Usage:       "keybase sigs verify-bundle [<infile>]",
+		Description: "Verify a bundle from 'sigs export', without the network",
+		Action: func(c *cli.Context) {
+			cl.ChooseCommand(&CmdSigsVerifyBundle{}, "verify-bundle", c)
+		},
+	}
+}
+
+func (v *CmdSigsVerifyBundle) GetUsage() libkb.Usage {
+	return libkb.Usage{
+		Config: true,
+	}
+}
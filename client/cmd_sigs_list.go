@@ -38,6 +38,7 @@ func (s *CmdSigsList) ParseTypes(ctx *cli.Context) error {
 		"proof":          true,
 		"cryptocurrency": true,
 		"self":           true,
+		"announcement":   true,
 	}
 
 	ret := make(map[string]bool)
@@ -291,7 +292,7 @@ func NewCmdSigsList(cl *libcmdline.CommandLine) cli.Command {
 			cli.StringFlag{
 				Name: "t, type",
 				Usage: "type of sig to output; choose from {track" +
-					", proof, cryptocurrency, self}; all by default",
+					", proof, cryptocurrency, self, announcement}; all by default",
 			},
 			cli.BoolFlag{
 				Name:  "a, all-keys",
@@ -315,7 +316,10 @@ func NewCmdSigs(cl *libcmdline.CommandLine) cli.Command {
 		Usage:       "keybase sigs [subcommands...]",
 		Description: "List, revoke signatures",
 		Subcommands: []cli.Command{
+			NewCmdSigsAudit(cl),
+			NewCmdSigsExport(cl),
 			NewCmdSigsList(cl),
+			NewCmdSigsVerifyBundle(cl),
 		},
 	}
 }
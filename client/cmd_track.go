@@ -5,13 +5,17 @@ import (
 	"github.com/codegangsta/cli"
 	"github.com/keybase/go/libcmdline"
 	"github.com/keybase/go/libkb"
+	"github.com/keybase/protocol/go"
 	"github.com/maxtaco/go-framed-msgpack-rpc/rpc2"
+	"time"
 )
 
 type CmdTrack struct {
 	user      string
 	assertion string
 	track     bool
+	localOnly bool
+	expireIn  time.Duration
 }
 
 func (v *CmdTrack) ParseArgv(ctx *cli.Context) error {
@@ -23,6 +27,15 @@ func (v *CmdTrack) ParseArgv(ctx *cli.Context) error {
 	} else {
 		err = fmt.Errorf("track takes one arg -- the user to track")
 	}
+	v.localOnly = ctx.Bool("local")
+	if e := ctx.String("expire"); err == nil && len(e) > 0 {
+		if v.expireIn, err = time.ParseDuration(e); err != nil {
+		} else if v.expireIn <= 0 {
+			err = fmt.Errorf("expiration must be positive")
+		} else {
+			v.localOnly = true
+		}
+	}
 	return err
 }
 
@@ -34,31 +47,52 @@ func (v *CmdTrack) RunClient() error {
 
 	protocols := []rpc2.Protocol{
 		NewLogUIProtocol(),
-		NewIdentifyTrackUIProtocol(v.user),
+		NewIdentifyTrackUIProtocol(v.user, v.localOnly),
 		NewSecretUIProtocol(),
 	}
 	if err = RegisterProtocols(protocols); err != nil {
 		return err
 	}
 
-	return cli.Track(v.user)
+	if !v.localOnly {
+		return cli.Track(v.user)
+	}
+	return cli.TrackWithOptions(keybase_1.TrackWithOptionsArg{
+		TheirName: v.user,
+		LocalOnly: v.localOnly,
+		ExpireIn:  int(v.expireIn.Seconds()),
+	})
 }
 
 func (v *CmdTrack) Run() error {
-	eng := libkb.NewTrackEngine(v.user, nil, nil)
+	var ui libkb.IdentifyUI
+	if v.localOnly {
+		ui = G_UI.GetIdentifyLocalTrackUI(v.user)
+	}
+	eng := libkb.NewTrackEngine(v.user, ui, nil)
+	eng.LocalOnly = v.localOnly
+	eng.ExpireIn = v.expireIn
 	return eng.Run()
 }
 
 func NewCmdTrack(cl *libcmdline.CommandLine) cli.Command {
 	return cli.Command{
 		Name:        "track",
-		Usage:       "keybase track <username>",
+		Usage:       "keybase track [--local] [--expire <duration>] <username>",
 		Description: "verify a user's authenticity and optionally track them",
 		Flags: []cli.Flag{
 			cli.StringFlag{
 				Name:  "assert, a",
 				Usage: "a boolean expression on this identity",
 			},
+			cli.BoolFlag{
+				Name:  "local",
+				Usage: "only track locally, and don't post a tracking statement",
+			},
+			cli.StringFlag{
+				Name:  "expire",
+				Usage: "track locally until the given duration (e.g. 72h) is up, then identify afresh",
+			},
 		},
 		Action: func(c *cli.Context) {
 			cl.ChooseCommand(&CmdTrack{}, "track", c)
@@ -0,0 +1,63 @@
+package main
+
+import (
+	"fmt"
+	"github.com/codegangsta/cli"
+	"github.com/keybase/go/libcmdline"
+	"github.com/keybase/go/libkb"
+	"github.com/maxtaco/go-framed-msgpack-rpc/rpc2"
+)
+
+type CmdUntrack struct {
+	user string
+}
+
+func (v *CmdUntrack) ParseArgv(ctx *cli.Context) error {
+	if len(ctx.Args()) != 1 {
+		return fmt.Errorf("untrack takes one arg -- the user to untrack")
+	}
+	v.user = ctx.Args()[0]
+	return nil
+}
+
+func (v *CmdUntrack) RunClient() error {
+	cli, err := GetTrackClient()
+	if err != nil {
+		return err
+	}
+
+	protocols := []rpc2.Protocol{
+		NewLogUIProtocol(),
+		NewSecretUIProtocol(),
+	}
+	if err = RegisterProtocols(protocols); err != nil {
+		return err
+	}
+
+	return cli.Untrack(v.user)
+}
+
+func (v *CmdUntrack) Run() error {
+	eng := libkb.NewUntrackEngine(v.user, nil, nil)
+	return eng.Run()
+}
+
+func NewCmdUntrack(cl *libcmdline.CommandLine) cli.Command {
+	return cli.Command{
+		Name:        "untrack",
+		Usage:       "keybase untrack <username>",
+		Description: "stop tracking a user",
+		Action: func(c *cli.Context) {
+			cl.ChooseCommand(&CmdUntrack{}, "untrack", c)
+		},
+	}
+}
+
+func (v *CmdUntrack) GetUsage() libkb.Usage {
+	return libkb.Usage{
+		Config:    true,
+		API:       true,
+		Terminal:  true,
+		KbKeyring: true,
+	}
+}
@@ -0,0 +1,174 @@
+package main
+
+import (
+	"fmt"
+	"github.com/codegangsta/cli"
+	"github.com/keybase/go/libcmdline"
+	"github.com/keybase/go/libkb"
+	"github.com/keybase/protocol/go"
+	"github.com/maxtaco/go-framed-msgpack-rpc/rpc2"
+	"io/ioutil"
+	"os"
+	"strconv"
+	"time"
+)
+
+func NewCmdVerify(cl *libcmdline.CommandLine) cli.Command {
+	return cli.Command{
+		Name:        "verify",
+		Usage:       "keybase verify [-d <detached>] [-o <outfile>] [--at <seqno|date>] [<infile>]",
+		Description: "verify a signed document and identify its signer",
+		Action: func(c *cli.Context) {
+			cl.ChooseCommand(&CmdVerify{}, "verify", c)
+		},
+		Flags: []cli.Flag{
+			cli.StringFlag{
+				Name:  "d, detached",
+				Usage: "specify a detached signature file for <infile>",
+			},
+			cli.StringFlag{
+				Name:  "m, message",
+				Usage: "provide the signed message on the command line",
+			},
+			cli.StringFlag{
+				Name:  "o, outfile",
+				Usage: "write the signed payload to outfile (stdout by default)",
+			},
+			cli.StringFlag{
+				Name:  "at",
+				Usage: "check the key was valid, and a PGP signature made, by this signer's seqno, or date (YYYY-MM-DD or RFC 3339)",
+			},
+		},
+	}
+}
+
+type CmdVerify struct {
+	UnixFilter
+	detached string
+	at       *libkb.KeyStateQuery
+}
+
+// parseKeyStateQuery reads a --at argument, which is either a seqno in
+// the signer's sigchain or a date.
+func parseKeyStateQuery(s string) (*libkb.KeyStateQuery, error) {
+	if n, err := strconv.Atoi(s); err == nil {
+		if n <= 0 {
+			return nil, fmt.Errorf("seqno for --at must be positive")
+		}
+		return &libkb.KeyStateQuery{Seqno: libkb.Seqno(n)}, nil
+	}
+	for _, layout := range []string{time.RFC3339, "2006-01-02"} {
+		if tm, err := time.Parse(layout, s); err == nil {
+			return &libkb.KeyStateQuery{Time: tm}, nil
+		}
+	}
+	return nil, fmt.Errorf("bad --at value '%s'; expected a seqno or a date", s)
+}
+
+func (v *CmdVerify) ParseArgv(ctx *cli.Context) error {
+	nargs := len(ctx.Args())
+	var err error
+
+	v.detached = ctx.String("detached")
+	if at := ctx.String("at"); len(at) > 0 {
+		if v.at, err = parseKeyStateQuery(at); err != nil {
+			return err
+		}
+	}
+	msg := ctx.String("message")
+	outfile := ctx.String("outfile")
+	var infile string
+
+	if nargs == 1 {
+		infile = ctx.Args()[0]
+	} else if nargs > 1 {
+		err = fmt.Errorf("verify takes at most 1 arg, an infile")
+	}
+
+	if err == nil && len(v.detached) > 0 && len(outfile) > 0 {
+		err = fmt.Errorf("can't specify an outfile when verifying a detached signature")
+	}
+
+	if err == nil {
+		err = v.FilterInit(msg, infile, outfile)
+	}
+
+	return err
+}
+
+func (v *CmdVerify) RunClient() (err error) {
+	var cli keybase_1.VerifyClient
+	var arg keybase_1.VerifyArg
+	var res keybase_1.VerifyRes
+
+	protocols := []rpc2.Protocol{
+		NewLogUIProtocol(),
+		NewIdentifyUIProtocol(""),
+	}
+
+	if err = v.FilterOpen(); err != nil {
+		return
+	}
+	defer func() {
+		v.Close(err)
+	}()
+
+	if arg.Message, err = ioutil.ReadAll(v.source); err != nil {
+		return
+	}
+	if len(v.detached) > 0 {
+		if arg.Signature, err = ioutil.ReadFile(v.detached); err != nil {
+			return
+		}
+	}
+	if v.at != nil {
+		arg.AtSeqno = int(v.at.Seqno)
+		if !v.at.Time.IsZero() {
+			arg.AtTime = v.at.Time.Unix()
+		}
+	}
+
+	if cli, err = GetVerifyClient(); err != nil {
+	} else if err = RegisterProtocols(protocols); err != nil {
+	} else if res, err = cli.Verify(arg); err != nil {
+	} else if len(res.Body) > 0 {
+		_, err = v.sink.Write(res.Body)
+	}
+	return
+}
+
+func (v *CmdVerify) Run() (err error) {
+	var sig *os.File
+
+	if err = v.FilterOpen(); err != nil {
+		return
+	}
+	defer func() {
+		if sig != nil {
+			sig.Close()
+		}
+		v.Close(err)
+	}()
+
+	arg := libkb.VerifyArg{Message: v.source, At: v.at}
+	if len(v.detached) > 0 {
+		if sig, err = os.Open(v.detached); err != nil {
+			return
+		}
+		arg.Signature = sig
+	} else {
+		arg.Out = v.sink
+	}
+
+	_, err = libkb.NewVerifyEngine(&arg).Run()
+	return
+}
+
+func (v *CmdVerify) GetUsage() libkb.Usage {
+	return libkb.Usage{
+		Config:    true,
+		API:       true,
+		Terminal:  true,
+		KbKeyring: true,
+	}
+}
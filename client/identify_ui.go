@@ -18,8 +18,12 @@ func NewIdentifySelfUIProtocol() rpc2.Protocol {
 	return keybase_1.IdentifyUiProtocol(&IdentifyUIServer{G_UI.GetIdentifySelfUI()})
 }
 
-func NewIdentifyTrackUIProtocol(username string) rpc2.Protocol {
-	return keybase_1.IdentifyUiProtocol(&IdentifyUIServer{G_UI.GetIdentifyTrackUI(username, true)})
+func NewIdentifyTrackUIProtocol(username string, localOnly bool) rpc2.Protocol {
+	ui := G_UI.GetIdentifyTrackUI(username, true)
+	if localOnly {
+		ui = G_UI.GetIdentifyLocalTrackUI(username)
+	}
+	return keybase_1.IdentifyUiProtocol(&IdentifyUIServer{ui})
 }
 
 func (i *IdentifyUIServer) FinishAndPrompt(arg keybase_1.FinishAndPromptArg) (res keybase_1.FinishAndPromptRes, err error) {
@@ -16,20 +16,32 @@ func parseArgs() (libkb.CommandLine, libcmdline.Command, error) {
 
 	cl := libcmdline.NewCommandLine(true)
 	cmds := []cli.Command{
+		NewCmdAnnouncement(cl),
+		NewCmdBTC(cl),
 		NewCmdConfig(cl),
 		NewCmdDb(cl),
+		NewCmdDecrypt(cl),
+		NewCmdDevice(cl),
+		NewCmdEncrypt(cl),
 		NewCmdId(cl),
 		NewCmdListTracking(cl),
+		NewCmdLock(cl),
 		NewCmdLogin(cl),
 		NewCmdLogout(cl),
+		NewCmdMerkle(cl),
 		NewCmdMykey(cl),
+		NewCmdPassphrase(cl),
+		NewCmdPgp(cl),
 		NewCmdPing(cl),
 		NewCmdProve(cl),
 		NewCmdResolve(cl),
+		NewCmdRevoke(cl),
 		NewCmdSigs(cl),
 		NewCmdSign(cl),
 		NewCmdSignup(cl),
 		NewCmdTrack(cl),
+		NewCmdUntrack(cl),
+		NewCmdVerify(cl),
 		NewCmdVersion(cl),
 	}
 	cl.AddCommands(cmds)
@@ -44,5 +56,6 @@ func parseArgs() (libkb.CommandLine, libcmdline.Command, error) {
 
 func main() {
 	G_UI = &UI{}
+	G.SecretAgent = SecretAgent{}
 	libcmdline.Main(parseArgs, G_UI, true)
 }
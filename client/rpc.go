@@ -44,6 +44,14 @@ func GetLoginClient() (cli keybase_1.LoginClient, err error) {
 	return
 }
 
+func GetSecretCacheClient() (cli keybase_1.SecretCacheClient, err error) {
+	var rcli *rpc2.Client
+	if rcli, _, err = GetRpcClient(); err == nil {
+		cli = keybase_1.SecretCacheClient{rcli}
+	}
+	return
+}
+
 func RegisterProtocols(prots []rpc2.Protocol) (err error) {
 	var srv *rpc2.Server
 	if srv, _, err = GetRpcServer(); err != nil {
@@ -88,3 +96,11 @@ func GetTrackClient() (cli keybase_1.TrackClient, err error) {
 	}
 	return
 }
+
+func GetVerifyClient() (cli keybase_1.VerifyClient, err error) {
+	var rcli *rpc2.Client
+	if rcli, _, err = GetRpcClient(); err == nil {
+		cli = keybase_1.VerifyClient{rcli}
+	}
+	return
+}
@@ -0,0 +1,59 @@
+package main
+
+import (
+	"fmt"
+	"github.com/keybase/go/libkb"
+	"github.com/keybase/protocol/go"
+)
+
+// SecretAgent asks the daemon for keys it's already unlocked, and hands
+// it the ones we unlock, so that a burst of commands prompts just once.
+type SecretAgent struct{}
+
+func (a SecretAgent) GetSecretKey(kid libkb.KID) (key libkb.GenericKey, err error) {
+	var cli keybase_1.SecretCacheClient
+	var bundle []byte
+	if cli, err = GetSecretCacheClient(); err != nil {
+		return
+	}
+	if bundle, err = cli.GetSecretKey(kid); err != nil || len(bundle) == 0 {
+		return
+	}
+	var packet *libkb.KeybasePacket
+	var p3skb *libkb.P3SKB
+	if packet, err = libkb.DecodePacket(bundle); err != nil {
+		return
+	}
+	if p3skb, err = packet.ToP3SKB(); err != nil {
+		return
+	}
+	// Check the secret key against the public one, since a NaCl key
+	// takes its KID from the public half alone.
+	if err = p3skb.CheckUnlocked(); err != nil {
+		return
+	}
+	if key, err = p3skb.UnlockSecretKey(nil); err == nil && !key.GetKid().Eq(kid) {
+		key, err = nil, fmt.Errorf("daemon sent back the wrong key")
+	}
+	return
+}
+
+func (a SecretAgent) PutSecretKey(key libkb.GenericKey) (err error) {
+	var cli keybase_1.SecretCacheClient
+	var p3skb *libkb.P3SKB
+	var packet *libkb.KeybasePacket
+	var bundle []byte
+	if cli, err = GetSecretCacheClient(); err != nil {
+		return
+	}
+	if p3skb, err = key.ToP3SKB(nil); err != nil {
+		return
+	}
+	if packet, err = p3skb.ToPacket(); err != nil {
+		return
+	}
+	if bundle, err = packet.Encode(); err != nil {
+		return
+	}
+	return cli.PutSecretKey(bundle)
+}
@@ -92,7 +92,8 @@ func (ui IdentifySelfUI) FinishAndPrompt(o *keybase_1.IdentifyOutcome) (ret keyb
 
 type IdentifyTrackUI struct {
 	BaseIdentifyUI
-	strict bool
+	strict    bool
+	localOnly bool // never offer to post the tracking statement
 }
 
 func (ui IdentifyTrackUI) ReportDeleted(del []keybase_1.TrackDiff) {
@@ -175,7 +176,9 @@ func (ui IdentifyTrackUI) FinishAndPrompt(o *keybase_1.IdentifyOutcome) (ret key
 		ret.TrackLocal = false
 	}
 
-	if err == nil && (!isEqual || !isRemote) {
+	if ui.localOnly {
+		ret.TrackRemote = false
+	} else if err == nil && (!isEqual || !isRemote) {
 		def = true
 		prompt = "publicly write tracking statement to server?"
 		ret.TrackRemote, err = ui.parent.PromptYesNo(prompt, &def)
@@ -360,7 +363,11 @@ func (ui BaseIdentifyUI) FinishWebProofCheck(p keybase_1.RemoteProof, l keybase_
 }
 
 func (ui BaseIdentifyUI) DisplayCryptocurrency(l keybase_1.Cryptocurrency) {
-	msg := (BTC + " bitcoin " + ColorString("green", l.Address))
+	typ := l.Type
+	if len(typ) == 0 {
+		typ = "bitcoin"
+	}
+	msg := (BTC + " " + typ + " " + ColorString("green", l.Address))
 	ui.ReportHook(msg)
 }
 
@@ -381,12 +388,12 @@ func (ui BaseIdentifyUI) DisplayKey(f keybase_1.FOKID, diff *keybase_1.TrackDiff
 
 func (ui BaseIdentifyUI) ReportLastTrack(tl *keybase_1.TrackSummary) {
 	if t := libkb.ImportTrackSummary(tl); t != nil {
-		locally := ""
+		kind := "publicly, in your sigchain"
 		if !t.IsRemote() {
-			locally = "locally "
+			kind = "locally, on this machine only"
 		}
-		msg := ColorString("bold", fmt.Sprintf("You last %stracked %s on %s",
-			locally, ui.username, libkb.FormatTime(t.GetCTime())))
+		msg := ColorString("bold", fmt.Sprintf("You last tracked %s on %s (%s)",
+			ui.username, libkb.FormatTime(t.GetCTime()), kind))
 		ui.ReportHook(msg)
 	}
 }
@@ -400,7 +407,11 @@ func (ui *UI) GetIdentifySelfUI() libkb.IdentifyUI {
 }
 
 func (ui *UI) GetIdentifyTrackUI(username string, strict bool) libkb.IdentifyUI {
-	return IdentifyTrackUI{BaseIdentifyUI{parent: ui, username: username}, strict}
+	return IdentifyTrackUI{BaseIdentifyUI{parent: ui, username: username}, strict, false}
+}
+
+func (ui *UI) GetIdentifyLocalTrackUI(username string) libkb.IdentifyUI {
+	return IdentifyTrackUI{BaseIdentifyUI{parent: ui, username: username}, true, true}
 }
 
 func (ui *UI) GetIdentifyUI(username string) libkb.IdentifyUI {
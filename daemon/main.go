@@ -25,8 +25,10 @@ func RegisterProtocols(srv *rpc2.Server, xp *rpc2.Transport) {
 	srv.Register(keybase_1.LoginProtocol(NewLoginHandler(xp)))
 	srv.Register(keybase_1.MykeyProtocol(NewMykeyHandler(xp)))
 	srv.Register(keybase_1.ProveProtocol(NewProveHandler(xp)))
+	srv.Register(keybase_1.SecretCacheProtocol(NewSecretCacheHandler(xp)))
 	srv.Register(keybase_1.SessionProtocol(NewSessionHandler(xp)))
 	srv.Register(keybase_1.TrackProtocol(NewTrackHandler(xp)))
+	srv.Register(keybase_1.VerifyProtocol(NewVerifyHandler(xp)))
 }
 
 func (d *Daemon) Handle(c net.Conn) {
@@ -45,6 +47,9 @@ func (d *Daemon) Run() (err error) {
 	if err = d.setupRun(); err != nil {
 		return
 	}
+	if err = G.ConfigureSecretCache(); err != nil {
+		return
+	}
 	if err = d.ConfigRpcServer(); err != nil {
 		return
 	}
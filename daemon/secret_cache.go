@@ -0,0 +1,56 @@
+package main
+
+import (
+	"github.com/keybase/go/libkb"
+	"github.com/maxtaco/go-framed-msgpack-rpc/rpc2"
+)
+
+// SecretCacheHandler is the RPC handler for the secretCache interface.
+// It shares the daemon's unlocked keys with clients on the socket, so
+// that commands that run in the client don't each prompt for them.
+type SecretCacheHandler struct {
+	BaseHandler
+}
+
+// NewSecretCacheHandler creates a SecretCacheHandler for the xp transport.
+func NewSecretCacheHandler(xp *rpc2.Transport) *SecretCacheHandler {
+	return &SecretCacheHandler{BaseHandler{xp: xp}}
+}
+
+// GetSecretKey sends back the unlocked key with the given KID, as an
+// unencrypted P3SKB packet, or nothing if we aren't holding it.
+func (h *SecretCacheHandler) GetSecretKey(kid []byte) (ret []byte, err error) {
+	p3skb := G.SecretCache.GetP3SKB(libkb.KID(kid))
+	if p3skb == nil {
+		return
+	}
+	var packet *libkb.KeybasePacket
+	if packet, err = p3skb.ToPacket(); err != nil {
+		return
+	}
+	return packet.Encode()
+}
+
+// PutSecretKey holds onto a key the client has just unlocked, so long as
+// its secret key matches the KID it claims.
+func (h *SecretCacheHandler) PutSecretKey(bundle []byte) (err error) {
+	var packet *libkb.KeybasePacket
+	var p3skb *libkb.P3SKB
+	if packet, err = libkb.DecodePacket(bundle); err != nil {
+		return
+	}
+	if p3skb, err = packet.ToP3SKB(); err != nil {
+		return
+	}
+	if err = p3skb.CheckUnlocked(); err != nil {
+		return
+	}
+	return G.SecretCache.PutP3SKB(p3skb)
+}
+
+// Lock drops, and zeroes, all of the unlocked secret keys the daemon is
+// holding, without logging out.
+func (h *SecretCacheHandler) Lock() error {
+	G.SecretCache.Clear()
+	return nil
+}
@@ -2,7 +2,9 @@ package main
 
 import (
 	"github.com/keybase/go/libkb"
+	"github.com/keybase/protocol/go"
 	"github.com/maxtaco/go-framed-msgpack-rpc/rpc2"
+	"time"
 )
 
 // TrackHandler is the RPC handler for the track interface.
@@ -21,3 +23,20 @@ func (h *TrackHandler) Track(theirName string) error {
 	eng := libkb.NewTrackEngine(theirName, NewRemoteIdentifyUI(sessionID, theirName, h.getRpcClient()), h.getSecretUI(sessionID))
 	return eng.Run()
 }
+
+// TrackWithOptions runs a TrackEngine that can keep the tracking
+// statement local, and have it expire.
+func (h *TrackHandler) TrackWithOptions(arg keybase_1.TrackWithOptionsArg) error {
+	sessionID := nextSessionId()
+	eng := libkb.NewTrackEngine(arg.TheirName, NewRemoteIdentifyUI(sessionID, arg.TheirName, h.getRpcClient()), h.getSecretUI(sessionID))
+	eng.LocalOnly = arg.LocalOnly
+	eng.ExpireIn = time.Duration(arg.ExpireIn) * time.Second
+	return eng.Run()
+}
+
+// Untrack creates an UntrackEngine and runs it.
+func (h *TrackHandler) Untrack(theirName string) error {
+	sessionID := nextSessionId()
+	eng := libkb.NewUntrackEngine(theirName, h.getSecretUI(sessionID), h.getLogUI(sessionID))
+	return eng.Run()
+}
@@ -0,0 +1,48 @@
+package main
+
+import (
+	"bytes"
+	"github.com/keybase/go/libkb"
+	"github.com/keybase/protocol/go"
+	"github.com/maxtaco/go-framed-msgpack-rpc/rpc2"
+	"time"
+)
+
+// VerifyHandler is the RPC handler for the verify interface.
+type VerifyHandler struct {
+	BaseHandler
+}
+
+// NewVerifyHandler creates a VerifyHandler for the xp transport.
+func NewVerifyHandler(xp *rpc2.Transport) *VerifyHandler {
+	return &VerifyHandler{BaseHandler{xp: xp}}
+}
+
+// Verify checks the signature on the given message, identifies the
+// signer, and sends back who it was along with the signed payload.
+func (h *VerifyHandler) Verify(arg keybase_1.VerifyArg) (res keybase_1.VerifyRes, err error) {
+	sessionId := nextSessionId()
+	var out bytes.Buffer
+	varg := libkb.VerifyArg{
+		Message:    bytes.NewReader(arg.Message),
+		Out:        &out,
+		IdentifyUI: NewRemoteIdentifyUI(sessionId, "", h.getRpcClient()),
+		LogUI:      h.getLogUI(sessionId),
+	}
+	if arg.Signature != nil {
+		varg.Signature = bytes.NewReader(arg.Signature)
+	}
+	if arg.AtSeqno > 0 || arg.AtTime > 0 {
+		varg.At = &libkb.KeyStateQuery{Seqno: libkb.Seqno(arg.AtSeqno)}
+		if arg.AtTime > 0 {
+			varg.At.Time = time.Unix(arg.AtTime, 0)
+		}
+	}
+	var vres *libkb.VerifyRes
+	if vres, err = libkb.NewVerifyEngine(&varg).Run(); err != nil {
+		return
+	}
+	res.Signer = *vres.Signer.Export()
+	res.Body = out.Bytes()
+	return
+}
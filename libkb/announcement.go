@@ -0,0 +1,102 @@
+package libkb
+
+import (
+	"fmt"
+	"github.com/keybase/go-jsonw"
+	"strings"
+)
+
+type AnnounceArg struct {
+	Message string
+
+	SecretUI SecretUI
+	LogUI    LogUI
+}
+
+// AnnounceEngine signs a short message into our sigchain, for notices
+// like "I have rotated my key" that others can check came from us.
+type AnnounceEngine struct {
+	arg *AnnounceArg
+}
+
+func NewAnnounceEngine(arg *AnnounceArg) *AnnounceEngine {
+	return &AnnounceEngine{arg: arg}
+}
+
+func (e *AnnounceEngine) Run() (err error) {
+	G.Log.Debug("+ AnnounceEngine.Run")
+	defer func() {
+		G.Log.Debug("- AnnounceEngine.Run -> %s", ErrToOk(err))
+	}()
+
+	if e.arg.LogUI == nil {
+		e.arg.LogUI = G.UI.GetLogUI()
+	}
+	if e.arg.SecretUI == nil {
+		e.arg.SecretUI = G.UI.GetSecretUI()
+	}
+
+	msg := strings.TrimSpace(e.arg.Message)
+	if len(msg) == 0 {
+		return fmt.Errorf("Empty announcement")
+	} else if len(msg) > ANNOUNCEMENT_MAX_LEN {
+		return fmt.Errorf("Announcement is too long (%d > %d bytes)", len(msg), ANNOUNCEMENT_MAX_LEN)
+	}
+
+	if err = G.Session.Load(); err != nil {
+		return
+	}
+	var me *User
+	if me, err = LoadMe(LoadUserArg{}); err != nil {
+		return
+	}
+
+	var key GenericKey
+	if key, err = G.Keyrings.GetSecretKey("announcement signature", e.arg.SecretUI); err != nil {
+		return
+	} else if key == nil {
+		return NoSecretKeyError{}
+	}
+
+	var jw *jsonw.Wrapper
+	if jw, err = me.AnnouncementSig(key, msg); err != nil {
+		return
+	}
+	var sig string
+	var id *SigId
+	var lid LinkId
+	if sig, id, lid, err = SignJson(jw, key); err != nil {
+		return
+	}
+	if err = PostSig(PostSigArg{Sig: sig, Id: *id, Type: "announcement", SigningKey: key}); err != nil {
+		return
+	}
+	me.sigChain.Bump(MerkleTriple{linkId: lid, sigId: id})
+
+	e.arg.LogUI.Info("Posted announcement %s", id.ToDisplayString(false))
+	return
+}
+
+//=============================================================================
+
+// AnnouncementCheck is the outcome of checking one announcement's
+// signature: the key that made it, or why it didn't verify.
+type AnnouncementCheck struct {
+	Link *AnnouncementChainLink
+	Key  GenericKey
+	Err  error
+}
+
+// CheckAnnouncements verifies the signature on each of the user's
+// announcements, oldest first.
+func CheckAnnouncements(u *User) (ret []AnnouncementCheck) {
+	if u.IdTable == nil || u.GetKeyFamily() == nil {
+		return
+	}
+	kf := u.GetKeyFamily()
+	for _, a := range u.IdTable.Announcements() {
+		key, err := a.Verify(*kf)
+		ret = append(ret, AnnouncementCheck{Link: a, Key: key, Err: err})
+	}
+	return
+}
@@ -0,0 +1,29 @@
+package libkb
+
+import (
+	"github.com/keybase/go-jsonw"
+	"testing"
+)
+
+func TestAnnouncementChainLink(t *testing.T) {
+	body := jsonw.NewDictionary()
+	body.SetKey("type", jsonw.NewString("announcement"))
+	announcement := jsonw.NewDictionary()
+	announcement.SetKey("message", jsonw.NewString("I have rotated my key"))
+	body.SetKey("announcement", announcement)
+	payload := jsonw.NewDictionary()
+	payload.SetKey("body", body)
+
+	cl := &ChainLink{payloadJson: payload, unpacked: &ChainLinkUnpacked{}}
+	tcl, w := NewTypedChainLink(cl)
+	if w != nil {
+		t.Fatalf("unexpected warning: %s", w.Warning())
+	}
+	a, ok := tcl.(*AnnouncementChainLink)
+	if !ok {
+		t.Fatalf("expected an AnnouncementChainLink; got %s", tcl.Type())
+	}
+	if a.GetMessage() != "I have rotated my key" {
+		t.Errorf("bad message: %s", a.GetMessage())
+	}
+}
@@ -3,6 +3,7 @@ package libkb
 import (
 	"crypto/sha256"
 	"fmt"
+	"strings"
 )
 
 type BtcOpts struct {
@@ -44,13 +45,225 @@ func BtcAddrCheck(s string, opts *BtcOpts) (version int, pkhash []byte, err erro
 	}
 
 	pkhash = buf[0:(l - 4)]
+	err = checkBase58Sum(buf)
+	return
+}
+
+// checkBase58Sum checks the double-SHA256 checksum in the last 4 bytes
+// of a decoded base58check string.
+func checkBase58Sum(buf []byte) error {
+	l := len(buf)
 	c1 := buf[(l - 4):]
-	tmp := sha256.Sum256(pkhash)
+	tmp := sha256.Sum256(buf[0:(l - 4)])
 	tmp2 := sha256.Sum256(tmp[:])
 	c2 := tmp2[0:4]
 
 	if !FastByteArrayEq(c1, c2) {
-		err = fmt.Errorf("Bad checksum: %v != %v", c1, c2)
+		return fmt.Errorf("Bad checksum: %v != %v", c1, c2)
 	}
+	return nil
+}
+
+//=============================================================================
+// Bech32 and bech32m, as in BIP-173 and BIP-350, for SegWit addresses.
+
+const (
+	BECH32  = 1
+	BECH32M = 2
+)
+
+const bech32Charset = "qpzry9x8gf2tvdw0s3jn54khce6mua7l"
+
+const (
+	bech32Const  = 1
+	bech32mConst = 0x2bc830a3
+)
+
+func bech32Polymod(values []byte) uint32 {
+	gen := []uint32{0x3b6a57b2, 0x26508e6d, 0x1ea119fa, 0x3d4233dd, 0x2a1462b3}
+	chk := uint32(1)
+	for _, v := range values {
+		b := chk >> 25
+		chk = (chk&0x1ffffff)<<5 ^ uint32(v)
+		for i := uint(0); i < 5; i++ {
+			if (b>>i)&1 == 1 {
+				chk ^= gen[i]
+			}
+		}
+	}
+	return chk
+}
+
+func bech32HrpExpand(hrp string) []byte {
+	ret := make([]byte, 0, 2*len(hrp)+1)
+	for _, c := range []byte(hrp) {
+		ret = append(ret, c>>5)
+	}
+	ret = append(ret, 0)
+	for _, c := range []byte(hrp) {
+		ret = append(ret, c&31)
+	}
+	return ret
+}
+
+// Bech32Decode splits a bech32 or bech32m string into its human-readable
+// part and its 5-bit data values, minus the checksum. It reports which of
+// the two checksums the string used.
+func Bech32Decode(s string) (hrp string, data []byte, variant int, err error) {
+	if len(s) > 90 {
+		err = fmt.Errorf("Bech32 string is too long")
+		return
+	}
+	if strings.ToLower(s) != s && strings.ToUpper(s) != s {
+		err = fmt.Errorf("Bech32 string has mixed case")
+		return
+	}
+	s = strings.ToLower(s)
+
+	pos := strings.LastIndex(s, "1")
+	if pos < 1 || pos+7 > len(s) {
+		err = fmt.Errorf("Bech32 separator is missing or misplaced")
+		return
+	}
+	hrp = s[0:pos]
+	for _, c := range []byte(hrp) {
+		if c < 33 || c > 126 {
+			err = fmt.Errorf("Bad character in bech32 prefix: %d", c)
+			return
+		}
+	}
+
+	for i, c := range []byte(s[pos+1:]) {
+		d := strings.IndexByte(bech32Charset, c)
+		if d < 0 {
+			err = fmt.Errorf("Bad bech32 character '%c' found at pos %d", c, pos+1+i)
+			return
+		}
+		data = append(data, byte(d))
+	}
+
+	switch bech32Polymod(append(bech32HrpExpand(hrp), data...)) {
+	case bech32Const:
+		variant = BECH32
+	case bech32mConst:
+		variant = BECH32M
+	default:
+		err = fmt.Errorf("Bad bech32 checksum")
+		return
+	}
+	data = data[0 : len(data)-6]
 	return
 }
+
+// convertBits regroups a slice of from-bit values into to-bit values.
+func convertBits(data []byte, from, to uint, pad bool) (ret []byte, err error) {
+	acc := uint32(0)
+	bits := uint(0)
+	maxv := uint32(1<<to) - 1
+	for _, v := range data {
+		if uint32(v)>>from != 0 {
+			return nil, fmt.Errorf("Bad %d-bit value: %d", from, v)
+		}
+		acc = acc<<from | uint32(v)
+		bits += from
+		for bits >= to {
+			bits -= to
+			ret = append(ret, byte((acc>>bits)&maxv))
+		}
+	}
+	if pad {
+		if bits > 0 {
+			ret = append(ret, byte((acc<<(to-bits))&maxv))
+		}
+	} else if bits >= from || (acc<<(to-bits))&maxv != 0 {
+		return nil, fmt.Errorf("Bad padding in bech32 data")
+	}
+	return
+}
+
+// SegwitAddrCheck decodes a SegWit address with the given human-readable
+// part ("bc" for bitcoin), and returns its witness version and program.
+// Version 0 addresses must use bech32, and later versions bech32m.
+func SegwitAddrCheck(hrp string, s string) (version int, program []byte, err error) {
+	var gotHrp string
+	var data []byte
+	var variant int
+	if gotHrp, data, variant, err = Bech32Decode(s); err != nil {
+		return
+	}
+	if gotHrp != hrp {
+		err = fmt.Errorf("Wrong SegWit address prefix: %s", gotHrp)
+		return
+	}
+	if len(data) < 1 || data[0] > 16 {
+		err = fmt.Errorf("Bad SegWit version")
+		return
+	}
+	version = int(data[0])
+	if program, err = convertBits(data[1:], 5, 8, false); err != nil {
+		return
+	}
+	if len(program) < 2 || len(program) > 40 {
+		err = fmt.Errorf("Bad SegWit program length: %d", len(program))
+	} else if version == 0 && len(program) != 20 && len(program) != 32 {
+		err = fmt.Errorf("Bad SegWit v0 program length: %d", len(program))
+	} else if version == 0 && variant != BECH32 {
+		err = fmt.Errorf("SegWit v0 address must use bech32")
+	} else if version != 0 && variant != BECH32M {
+		err = fmt.Errorf("SegWit v%d address must use bech32m", version)
+	}
+	return
+}
+
+//=============================================================================
+// Checkers for the cryptocurrency types we know about. Each returns the
+// address's public key hash (or script hash, or witness program), with
+// the version prefixed.
+
+func checkBitcoinAddr(s string) (pkhash []byte, err error) {
+	if strings.HasPrefix(strings.ToLower(s), "bc1") {
+		var version int
+		var program []byte
+		if version, program, err = SegwitAddrCheck("bc", s); err == nil {
+			pkhash = append([]byte{byte(version)}, program...)
+		}
+		return
+	}
+	_, pkhash, err = BtcAddrCheck(s, nil)
+	return
+}
+
+// Zcash transparent addresses are base58check, like bitcoin's, but with
+// two-byte version prefixes: t1 for P2PKH, and t3 for P2SH.
+var zcashVersions = [][]byte{{0x1c, 0xb8}, {0x1c, 0xbd}}
+
+func checkZcashAddr(s string) (pkhash []byte, err error) {
+	var buf []byte
+	if buf, err = Decode58(s); err != nil {
+		return
+	}
+	if len(buf) != 2+20+4 {
+		err = fmt.Errorf("Bad Zcash address length: %d", len(buf))
+		return
+	}
+	found := false
+	for _, v := range zcashVersions {
+		if FastByteArrayEq(buf[0:2], v) {
+			found = true
+			break
+		}
+	}
+	if !found {
+		err = fmt.Errorf("Bad Zcash address version found: %x", buf[0:2])
+		return
+	}
+	if err = checkBase58Sum(buf); err == nil {
+		pkhash = buf[0 : len(buf)-4]
+	}
+	return
+}
+
+func init() {
+	RegisterCryptocurrency("bitcoin", checkBitcoinAddr)
+	RegisterCryptocurrency("zcash", checkZcashAddr)
+}
@@ -0,0 +1,94 @@
+package libkb
+
+import (
+	"fmt"
+	"github.com/keybase/go-jsonw"
+)
+
+type BTCArg struct {
+	Address string
+	Type    string // a registered cryptocurrency type; "bitcoin" by default
+	Force   bool   // replace an existing address, revoking its signature
+
+	SecretUI SecretUI
+	LogUI    LogUI
+}
+
+// BTCEngine signs a cryptocurrency address into our sigchain, so that
+// identify shows it alongside our proofs. We keep one address per
+// currency type.
+type BTCEngine struct {
+	arg *BTCArg
+}
+
+func NewBTCEngine(arg *BTCArg) *BTCEngine {
+	return &BTCEngine{arg: arg}
+}
+
+func (e *BTCEngine) Run() (err error) {
+	G.Log.Debug("+ BTCEngine.Run")
+	defer func() {
+		G.Log.Debug("- BTCEngine.Run -> %s", ErrToOk(err))
+	}()
+
+	if e.arg.LogUI == nil {
+		e.arg.LogUI = G.UI.GetLogUI()
+	}
+	if e.arg.SecretUI == nil {
+		e.arg.SecretUI = G.UI.GetSecretUI()
+	}
+
+	if len(e.arg.Type) == 0 {
+		e.arg.Type = "bitcoin"
+	}
+	if _, err = CryptocurrencyAddrCheck(e.arg.Type, e.arg.Address); err != nil {
+		return
+	}
+
+	if err = G.Session.Load(); err != nil {
+		return
+	}
+	var me *User
+	if me, err = LoadMe(LoadUserArg{}); err != nil {
+		return
+	}
+
+	var sigToRevoke *SigId
+	if me.IdTable != nil {
+		if prev := me.IdTable.ActiveCryptocurrency(e.arg.Type); prev == nil {
+		} else if prev.GetAddress() == e.arg.Address {
+			return fmt.Errorf("Address %s is already in your sigchain", e.arg.Address)
+		} else if !e.arg.Force {
+			return fmt.Errorf("You already have a %s address (%s); use --force to replace it",
+				e.arg.Type, prev.GetAddress())
+		} else {
+			sid := prev.GetSigId()
+			sigToRevoke = &sid
+		}
+	}
+
+	var key GenericKey
+	if key, err = G.Keyrings.GetSecretKey(e.arg.Type+" address signature", e.arg.SecretUI); err != nil {
+		return
+	} else if key == nil {
+		return NoSecretKeyError{}
+	}
+
+	var jw *jsonw.Wrapper
+	if jw, err = me.CryptocurrencySig(key, e.arg.Type, e.arg.Address, sigToRevoke); err != nil {
+		return
+	}
+	var sig string
+	var id *SigId
+	var lid LinkId
+	if sig, id, lid, err = SignJson(jw, key); err != nil {
+		return
+	}
+	if err = PostSig(PostSigArg{Sig: sig, Id: *id, Type: "cryptocurrency", SigningKey: key}); err != nil {
+		return
+	}
+	me.sigChain.Bump(MerkleTriple{linkId: lid, sigId: id})
+
+	e.arg.LogUI.Info("Added %s address %s", e.arg.Type, e.arg.Address)
+	return
+}
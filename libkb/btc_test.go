@@ -0,0 +1,100 @@
+package libkb
+
+import (
+	"encoding/hex"
+	"testing"
+)
+
+func TestSegwitAddrCheck(t *testing.T) {
+	good := []struct {
+		addr    string
+		version int
+		program string
+	}{
+		{"BC1QW508D6QEJXTDG4Y5R3ZARVARY0C5XW7KV8F3T4", 0, "751e76e8199196d454941c45d1b3a323f1433bd6"},
+		{"bc1p0xlxvlhemja6c4dqv22uapctqupfhlxm9h8z3k2e72q4k9hcz7vqzk5jj0", 1, "79be667ef9dcbbac55a06295ce870b07029bfcdb2dce28d959f2815b16f81798"},
+	}
+	for _, g := range good {
+		version, program, err := SegwitAddrCheck("bc", g.addr)
+		if err != nil {
+			t.Errorf("%s: %s", g.addr, err.Error())
+		} else if version != g.version || hex.EncodeToString(program) != g.program {
+			t.Errorf("%s: got version %d, program %x", g.addr, version, program)
+		}
+	}
+
+	bad := []string{
+		"bc1qw508d6qejxtdg4y5r3zarvary0c5xw7kemeawh",                     // v0 with a bech32m checksum
+		"bc1p0xlxvlhemja6c4dqv22uapctqupfhlxm9h8z3k2e72q4k9hcz7vqh2y7hd", // v1 with a bech32 checksum
+		"bc1qw508d6qejxtdg4y5r3zarvary0c5xw7kv8f3t5",                     // bad checksum
+		"bc1QW508D6QEJXTDG4Y5R3ZARVARY0C5XW7KV8F3T4",                     // mixed case
+		"ltc1qw508d6qejxtdg4y5r3zarvary0c5xw7kgmn4n9",                    // wrong prefix
+	}
+	for _, b := range bad {
+		if _, _, err := SegwitAddrCheck("bc", b); err == nil {
+			t.Errorf("%s: expected an error", b)
+		}
+	}
+}
+
+func TestCryptocurrencyAddrCheck(t *testing.T) {
+	good := []struct{ typ, addr string }{
+		{"bitcoin", "16L5yRNPTuciSgXGHqYwn9N6NeoKqopAu"},
+		{"bitcoin", "bc1qw508d6qejxtdg4y5r3zarvary0c5xw7kv8f3t4"},
+		{"zcash", "t1Hxw6JqWMnhDK5jRCieg5bFHM2qt7UtQvu"},
+		{"zcash", "t3Jex1rKwuh1bQFRrKpKGWDcDVZ8bbQuNrB"},
+	}
+	for _, g := range good {
+		if _, err := CryptocurrencyAddrCheck(g.typ, g.addr); err != nil {
+			t.Errorf("%s %s: %s", g.typ, g.addr, err.Error())
+		}
+	}
+
+	bad := []struct{ typ, addr string }{
+		{"bitcoin", "t1Hxw6JqWMnhDK5jRCieg5bFHM2qt7UtQvu"},
+		{"zcash", "16L5yRNPTuciSgXGHqYwn9N6NeoKqopAu"},
+		{"zcash", "t26e94XS5n9cxwx1bFZKK3qnrc3MmURMBS5"}, // unknown version
+		{"dogecoin", "16L5yRNPTuciSgXGHqYwn9N6NeoKqopAu"},
+	}
+	for _, b := range bad {
+		if _, err := CryptocurrencyAddrCheck(b.typ, b.addr); err == nil {
+			t.Errorf("%s %s: expected an error", b.typ, b.addr)
+		}
+	}
+}
+
+func TestCryptocurrencySig(t *testing.T) {
+	me, key := newTrackTestUser(t, "max", "9f9611a4b7920637b1c2a839b2a0e100")
+	addr := "bc1qw508d6qejxtdg4y5r3zarvary0c5xw7kv8f3t4"
+	old := ComputeSigIdFromSigBody([]byte("the old address"))
+
+	for _, revoke := range []*SigId{nil, &old} {
+		stmt, err := me.CryptocurrencySig(key, "bitcoin", addr, revoke)
+		if err != nil {
+			t.Fatal(err)
+		}
+		cl := &ChainLink{payloadJson: stmt, unsigned: true}
+		if err := cl.UnpackLocal(); err != nil {
+			t.Fatal(err)
+		}
+		if typ := cl.unpacked.typ; typ != "cryptocurrency" {
+			t.Errorf("link has type %q", typ)
+		}
+		ccl, err := ParseCryptocurrencyChainLink(GenericChainLink{cl})
+		if err != nil {
+			t.Fatal(err)
+		}
+		if ccl.GetCurrencyType() != "bitcoin" || ccl.ToDisplayString() != addr {
+			t.Errorf("got %s %s back", ccl.GetCurrencyType(), ccl.ToDisplayString())
+		}
+
+		revs := cl.GetRevocations()
+		if revoke == nil {
+			if len(revs) != 0 || !stmt.AtPath("body.revoke").IsNil() {
+				t.Errorf("new address revokes %v", revs)
+			}
+		} else if len(revs) != 1 || *revs[0] != old {
+			t.Errorf("replacement revokes %v, wanted %s", revs, old.ToString(true))
+		}
+	}
+}
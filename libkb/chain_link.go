@@ -362,6 +362,10 @@ func (c ChainLink) GetSeqno() Seqno {
 	}
 }
 
+func (c ChainLink) GetCTime() time.Time {
+	return time.Unix(c.unpacked.ctime, 0)
+}
+
 func (c ChainLink) GetSigId() *SigId {
 	if c.unpacked != nil {
 		return &c.unpacked.sigId
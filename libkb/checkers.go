@@ -2,6 +2,7 @@ package libkb
 
 import (
 	"encoding/hex"
+	"fmt"
 	"regexp"
 	"strings"
 	"unicode"
@@ -62,6 +63,24 @@ var CheckInviteCode = Checker{
 	Hint: "Invite codes are 24-digit hex strings",
 }
 
+var CheckKexPhrase = Checker{
+	F: func(s string) bool {
+		_, err := ParseKexSecret(s)
+		return err == nil
+	},
+	Hint:          fmt.Sprintf("the %d words shown on your new device", KEX_SECRET_LEN),
+	PreserveSpace: true,
+}
+
+var CheckPaperKey = Checker{
+	F: func(s string) bool {
+		_, err := ParsePaperKey(s)
+		return err == nil
+	},
+	Hint:          fmt.Sprintf("the %d words of your paper key", PAPER_KEY_LEN),
+	PreserveSpace: true,
+}
+
 func IsYes(s string) bool {
 	s = strings.ToLower(strings.TrimSpace(s))
 	return s == "y" || s == "yes"
@@ -1,6 +1,7 @@
 package libkb
 
 import (
+	"crypto"
 	"os"
 	"runtime"
 	"time"
@@ -45,6 +46,10 @@ var NACL_DH_EXPIRE_IN = 24 * 60 * 60 * 365 * 3    // 3 years
 
 var TRACK_SESSION_TIMEOUT = time.Minute
 
+// How long the daemon holds onto unlocked secret keys.
+var SECRET_CACHE_IDLE = 10 * time.Minute
+var SECRET_CACHE_MAX_TTL = time.Hour
+
 const (
 	SC_OK                        = 0
 	SC_BAD_SESSION               = 202
@@ -87,6 +92,8 @@ const (
 	SIG_TYPE_ANNOUNCEMENT   = 7
 )
 
+const ANNOUNCEMENT_MAX_LEN = 1024
+
 var PGP_VERSION = "Keybase Go CLI " + CLIENT_VERSION + " (" + runtime.GOOS + ")"
 
 func PgpArmorHeaders() map[string]string {
@@ -95,6 +102,16 @@ func PgpArmorHeaders() map[string]string {
 	}
 }
 
+// PgpArmorHeadersWithHash is PgpArmorHeaders plus a "Hash" header
+// recording which hash algorithm the enclosed signature uses.
+func PgpArmorHeadersWithHash(h crypto.Hash) map[string]string {
+	ret := PgpArmorHeaders()
+	if name, ok := PGP_HASH_NAMES[h]; ok {
+		ret["Hash"] = name
+	}
+	return ret
+}
+
 var REMOTE_SERVICE_TYPES = map[string]int{
 	"keybase":    PROOF_TYPE_KEYBASE,
 	"twitter":    PROOF_TYPE_TWITTER,
@@ -115,9 +132,12 @@ const (
 
 // Packet tags for OpenPGP and also Keybase packets
 var (
-	KEYBASE_PACKET_V1 = 1
-	TAG_P3SKB         = 513
-	TAG_SIGNATURE     = 514
+	KEYBASE_PACKET_V1     = 1
+	TAG_P3SKB             = 513
+	TAG_SIGNATURE         = 514
+	TAG_ENCRYPTION        = 515
+	TAG_ENCRYPTION_STREAM = 516
+	TAG_SIGNATURE_STREAM  = 517
 )
 
 var (
@@ -142,6 +162,17 @@ var (
 	HASH_PGP_SHA224    = 11
 )
 
+// OpenPGP hash names, as used in armor headers (RFC 4880 section 9.4)
+var PGP_HASH_NAMES = map[crypto.Hash]string{
+	crypto.MD5:       "MD5",
+	crypto.SHA1:      "SHA1",
+	crypto.RIPEMD160: "RIPEMD160",
+	crypto.SHA256:    "SHA256",
+	crypto.SHA384:    "SHA384",
+	crypto.SHA512:    "SHA512",
+	crypto.SHA224:    "SHA224",
+}
+
 var (
 	PROOF_TYPE_NONE             = 0
 	PROOF_TYPE_KEYBASE          = 1
@@ -158,6 +189,18 @@ var (
 	SIG_KB_EDDSA = KID_NACL_EDDSA
 )
 
+var (
+	ENC_KB_NACL_BOX = KID_NACL_DH
+)
+
+// Bitmask of operations we want a key for, as passed to key/fetch
+var (
+	PGP_OP_ENCRYPT = 0x1
+	PGP_OP_DECRYPT = 0x2
+	PGP_OP_VERIFY  = 0x4
+	PGP_OP_SIGN    = 0x8
+)
+
 var (
 	SERVER_UPDATE_LAG = time.Minute
 )
@@ -0,0 +1,35 @@
+package libkb
+
+import (
+	"fmt"
+	"sort"
+)
+
+// CryptocurrencyChecker validates an address of one cryptocurrency type,
+// and returns its public key hash.
+type CryptocurrencyChecker func(address string) (pkhash []byte, err error)
+
+var _cc_dispatch = make(map[string]CryptocurrencyChecker)
+
+// RegisterCryptocurrency makes a currency type available for
+// `cryptocurrency` links, under the given name (as in body.cryptocurrency.type).
+func RegisterCryptocurrency(typ string, f CryptocurrencyChecker) {
+	_cc_dispatch[typ] = f
+}
+
+func CryptocurrencyTypes() (ret []string) {
+	for k := range _cc_dispatch {
+		ret = append(ret, k)
+	}
+	sort.Strings(ret)
+	return
+}
+
+func CryptocurrencyAddrCheck(typ string, address string) (pkhash []byte, err error) {
+	f, found := _cc_dispatch[typ]
+	if !found {
+		err = fmt.Errorf("Unknown cryptocurrency type: %s", typ)
+		return
+	}
+	return f(address)
+}
@@ -0,0 +1,290 @@
+package libkb
+
+import (
+	"bufio"
+	"golang.org/x/crypto/openpgp"
+	"golang.org/x/crypto/openpgp/armor"
+	"io"
+	"io/ioutil"
+	"strings"
+)
+
+//=============================================================================
+
+// DecryptKeyRing is an openpgp.KeyRing that finds the secret key for a
+// given key ID in the local P3SKB keyring, among the secret keys synced
+// from the server, or in the local GnuPG keyrings, in that order, and
+// unlocks it. Signature issuers are resolved by the embedded
+// SignerKeyRing, so a signed message gets tied back to a Keybase user.
+type DecryptKeyRing struct {
+	SignerKeyRing
+	Reason    string
+	SecretUI  SecretUI
+	unlocked  *PgpKeyBundle
+	secretErr error
+}
+
+func (k *DecryptKeyRing) KeysById(id uint64) []openpgp.Key {
+	// Once we've unlocked a key, don't prompt for any of the message's
+	// other recipient keys.
+	if k.unlocked != nil {
+		return k.unlocked.KeysById(id)
+	}
+	if k.unlocked, k.secretErr = k.findSecretKey(id); k.secretErr != nil {
+		G.Log.Debug("| No secret key for ID %016X: %s", id, k.secretErr.Error())
+		k.unlocked = nil
+		return nil
+	}
+	return k.unlocked.KeysById(id)
+}
+
+func (k *DecryptKeyRing) DecryptionKeys() []openpgp.Key {
+	return nil
+}
+
+// SecretError returns why no secret key could be found or unlocked, if
+// that was the case.
+func (k *DecryptKeyRing) SecretError() error {
+	return k.secretErr
+}
+
+func (k *DecryptKeyRing) findSecretKey(id uint64) (ret *PgpKeyBundle, err error) {
+	G.Log.Debug("+ DecryptKeyRing.findSecretKey(%016X)", id)
+	defer func() {
+		G.Log.Debug("- DecryptKeyRing.findSecretKey -> %s", ErrToOk(err))
+	}()
+
+	var p3skb *P3SKB
+	var which string
+
+	if G.Keyrings.P3SKB != nil {
+		if p3skb = G.Keyrings.P3SKB.LookupByPgpKeyId(id); p3skb != nil {
+			G.Log.Debug("| Found key in local keychain")
+			which = "your local keychain"
+		}
+	}
+
+	if p3skb == nil {
+		if p3skb, err = k.findSyncedKey(id); err != nil {
+			G.Log.Debug("| No synced key: %s", err.Error())
+			err = nil
+		} else if p3skb != nil {
+			G.Log.Debug("| Found key in synced secret keys")
+			which = "your Keybase.io login"
+		}
+	}
+
+	if p3skb != nil {
+		var key GenericKey
+		var ok bool
+		if key, err = p3skb.PromptAndUnlock(k.Reason, which, k.SecretUI); err != nil {
+			return
+		} else if ret, ok = key.(*PgpKeyBundle); !ok {
+			err = NoSecretKeyError{}
+		}
+		return
+	}
+
+	return k.findGpgKey(id)
+}
+
+func (k *DecryptKeyRing) findSyncedKey(id uint64) (ret *P3SKB, err error) {
+	var me *User
+	if err = G.Session.Load(); err != nil {
+		return
+	}
+	if me, err = LoadMe(LoadUserArg{}); err != nil {
+		return
+	}
+	if err = G.SecretSyncer.Load(me.id); err != nil {
+		return
+	}
+	return G.SecretSyncer.FindPgpKeyById(id)
+}
+
+// findGpgKey looks through the GnuPG secret keyrings. Note that we
+// don't use Keyrings.KeysById, since it pads its result with empty keys.
+func (k *DecryptKeyRing) findGpgKey(id uint64) (ret *PgpKeyBundle, err error) {
+	for _, ring := range G.Keyrings.Secret {
+		for _, key := range ring.Entities.KeysById(id) {
+			if key.PrivateKey == nil {
+				continue
+			}
+			G.Log.Debug("| Found key in %s", ring.GetFilename())
+			ret = (*PgpKeyBundle)(key.Entity)
+			err = ret.Unlock(k.Reason, k.SecretUI)
+			return
+		}
+	}
+	err = NoSecretKeyError{}
+	return
+}
+
+//=============================================================================
+
+type DecryptArg struct {
+	Source io.Reader // armored or binary
+	Sink   io.Writer
+
+	IdentifyUI IdentifyUI
+	SecretUI   SecretUI
+	LogUI      LogUI
+}
+
+// DecryptEngine decrypts a PGP message with one of our secret keys. If the
+// message was also signed, it checks the signature and identifies the
+// Keybase user who made it.
+type DecryptEngine struct {
+	arg  *DecryptArg
+	ring DecryptKeyRing
+}
+
+func NewDecryptEngine(arg *DecryptArg) *DecryptEngine {
+	return &DecryptEngine{
+		arg:  arg,
+		ring: DecryptKeyRing{Reason: "decryption of a message", SecretUI: arg.SecretUI},
+	}
+}
+
+// Run decrypts the message. If it was signed by a Keybase user, it
+// returns who signed it; otherwise the returned result is nil.
+func (e *DecryptEngine) Run() (signer *VerifyRes, err error) {
+	G.Log.Debug("+ DecryptEngine.Run")
+	defer func() {
+		G.Log.Debug("- DecryptEngine.Run -> %s", ErrToOk(err))
+	}()
+
+	if e.arg.LogUI == nil {
+		e.arg.LogUI = G.UI.GetLogUI()
+	}
+
+	in := bufio.NewReader(e.arg.Source)
+	if isNaclStream(in) {
+		err = e.runNaclStream(in)
+		return
+	} else if isNaclArmored(in) {
+		err = e.runNacl(in)
+		return
+	}
+
+	var body io.Reader = in
+	if isArmored(in) {
+		var block *armor.Block
+		if block, err = armor.Decode(in); err != nil {
+			return
+		}
+		if block.Type == NACL_ENCRYPTED_ARMOR {
+			err = e.runNaclStream(block.Body)
+			return
+		}
+		body = block.Body
+	}
+
+	var md *openpgp.MessageDetails
+	if md, err = openpgp.ReadMessage(body, &e.ring, nil, nil); err != nil {
+		if serr := e.ring.SecretError(); serr != nil {
+			err = serr
+		}
+		return
+	}
+
+	// The signature, if any, is only checked once the literal data has
+	// been read through to EOF.
+	if _, err = io.Copy(e.arg.Sink, md.UnverifiedBody); err != nil {
+		return
+	}
+
+	if !md.IsSigned {
+		return
+	}
+	if md.SignedBy == nil {
+		e.arg.LogUI.Warning("Message was signed by key ID %016X, which isn't a Keybase key", md.SignedByKeyId)
+		if serr := e.ring.Error(); serr != nil {
+			e.arg.LogUI.Warning("Signer lookup failed: %s", serr.Error())
+		}
+		return
+	}
+	if md.SignatureError != nil {
+		err = md.SignatureError
+		return
+	}
+
+	return e.ring.IdentifySigner(e.arg.IdentifyUI, e.arg.LogUI)
+}
+
+// isNaclStream checks for a binary NaCl stream, which starts with the
+// big-endian length of its header, and so with a zero byte. A binary PGP
+// message always starts with a byte that has its high bit set.
+func isNaclStream(r *bufio.Reader) bool {
+	peek, err := r.Peek(1)
+	return err == nil && peek[0] == 0
+}
+
+// isNaclArmored checks for a base64-armored Keybase packet, which, unlike
+// a binary PGP message, starts with a printable character.
+func isNaclArmored(r *bufio.Reader) bool {
+	peek, err := r.Peek(1)
+	return err == nil && peek[0]&0x80 == 0 && !isArmored(r)
+}
+
+// findNaclDHKey finds and unlocks whichever of the given NaCl DH keys we
+// have in our local keychain.
+func (e *DecryptEngine) findNaclDHKey(kids []KID) (ret *NaclDHKeyPair, err error) {
+	if G.Keyrings.P3SKB == nil {
+		return nil, NoKeyringsError{}
+	}
+	for _, kid := range kids {
+		p3skb := G.Keyrings.P3SKB.LookupByKid(kid)
+		if p3skb == nil {
+			continue
+		}
+		G.Log.Debug("| Found NaCl DH key %s in local keychain", kid)
+
+		var key GenericKey
+		if key, err = p3skb.PromptAndUnlock(e.ring.Reason, "your local keychain", e.arg.SecretUI); err != nil {
+			return
+		}
+		dh, ok := key.(NaclDHKeyPair)
+		if !ok {
+			return nil, BadKeyError{"expected a NaCl DH key"}
+		}
+		return &dh, nil
+	}
+	return nil, NoSecretKeyError{}
+}
+
+// runNacl decrypts a NaclEncryption packet with whichever of our local
+// NaCl DH keys it was encrypted for.
+func (e *DecryptEngine) runNacl(in io.Reader) (err error) {
+	var data []byte
+	var enc *NaclEncryption
+	var dh *NaclDHKeyPair
+	var msg []byte
+	if data, err = ioutil.ReadAll(in); err != nil {
+		return
+	}
+	if enc, err = DecodeArmoredNaclEncryption(strings.TrimSpace(string(data))); err != nil {
+		return
+	}
+	if dh, err = e.findNaclDHKey(enc.RecipientKids()); err != nil {
+		return
+	}
+	if msg, err = enc.Decrypt(*dh); err != nil {
+		return
+	}
+	_, err = e.arg.Sink.Write(msg)
+	return
+}
+
+// runNaclStream decrypts a chunked NaCl stream. If the stream turns out
+// to be truncated or tampered with partway through, the chunks before
+// that have already been written to the sink, so the caller must discard
+// the output on error.
+func (e *DecryptEngine) runNaclStream(in io.Reader) (err error) {
+	var r io.Reader
+	if r, _, err = NewNaclDecryptStream(in, e.findNaclDHKey); err != nil {
+		return
+	}
+	_, err = io.Copy(e.arg.Sink, r)
+	return
+}
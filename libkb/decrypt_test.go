@@ -0,0 +1,130 @@
+package libkb
+
+import (
+	"bytes"
+	"github.com/keybase/go-triplesec"
+	"github.com/keybase/protocol/go"
+	"golang.org/x/crypto/openpgp"
+	"testing"
+)
+
+// testSecretUI answers every passphrase prompt with the next of its
+// passphrases.
+type testSecretUI struct {
+	passphrases []string
+	prompts     int
+}
+
+func (u *testSecretUI) GetSecret(pinentry keybase_1.SecretEntryArg, terminal *keybase_1.SecretEntryArg) (*keybase_1.SecretEntryRes, error) {
+	res := &keybase_1.SecretEntryRes{Canceled: u.prompts >= len(u.passphrases)}
+	if !res.Canceled {
+		res.Text = u.passphrases[u.prompts]
+	}
+	u.prompts++
+	return res, nil
+}
+
+func (u *testSecretUI) GetNewPassphrase(keybase_1.GetNewPassphraseArg) (string, error) {
+	return u.passphrases[0], nil
+}
+
+func (u *testSecretUI) GetKeybasePassphrase(keybase_1.GetKeybasePassphraseArg) (string, error) {
+	return u.passphrases[0], nil
+}
+
+// gpgSecretKeyring makes a GnuPG-style keyring holding key, locked with
+// the passphrase pp.
+func gpgSecretKeyring(t *testing.T, key *PgpKeyBundle, pp string) *KeyringFile {
+	var buf bytes.Buffer
+	if err := key.EncodeEncryptedSecretToStream(noOpCloser{&buf}, []byte(pp)); err != nil {
+		t.Fatalf("encode error: %s", err)
+	}
+	el, err := openpgp.ReadArmoredKeyRing(&buf)
+	if err != nil {
+		t.Fatalf("read error: %s", err)
+	}
+	return &KeyringFile{filename: "test.gpg", Entities: el}
+}
+
+func TestDecryptKeyRingGpgUsesSecretUI(t *testing.T) {
+	key := genSigningKey(t)
+	saved := G.Keyrings
+	defer func() { G.Keyrings = saved }()
+	G.Keyrings = &Keyrings{Secret: []*KeyringFile{gpgSecretKeyring(t, key, "the gpg passphrase")}}
+
+	ui := &testSecretUI{passphrases: []string{"a wrong guess", "the gpg passphrase"}}
+	ring := DecryptKeyRing{Reason: "test", SecretUI: ui}
+	ret, err := ring.findGpgKey(key.PrimaryKey.KeyId)
+	if err != nil {
+		t.Fatalf("find error: %s", err)
+	}
+	if ui.prompts != 2 {
+		t.Errorf("expected 2 prompts through our SecretUI; got %d", ui.prompts)
+	}
+	if err = ret.CheckSecretKey(); err != nil {
+		t.Errorf("key wasn't unlocked: %s", err)
+	}
+
+	if _, err = ring.findGpgKey(key.PrimaryKey.KeyId + 1); err == nil {
+		t.Errorf("found a key that isn't there")
+	} else if _, ok := err.(NoSecretKeyError); !ok {
+		t.Errorf("expected a NoSecretKeyError; got %s", err)
+	}
+}
+
+func TestDecryptKeyRingP3SKB(t *testing.T) {
+	key := genSigningKey(t)
+	tsec, err := triplesec.NewCipher([]byte("the keybase passphrase"), nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	p3skb, err := key.ToP3SKB(tsec)
+	if err != nil {
+		t.Fatal(err)
+	}
+	saved := G.Keyrings
+	defer func() { G.Keyrings = saved }()
+	ring := NewP3SKBKeyringFile("test.p3skb")
+	ring.Blocks = append(ring.Blocks, p3skb)
+	G.Keyrings = &Keyrings{P3SKB: ring}
+
+	var subkeyId uint64
+	for _, sk := range key.Subkeys {
+		if sk.Sig.FlagEncryptCommunications {
+			subkeyId = sk.PublicKey.KeyId
+		}
+	}
+
+	ui := &testSecretUI{passphrases: []string{"the keybase passphrase"}}
+	dkr := DecryptKeyRing{Reason: "test", SecretUI: ui}
+	if keys := dkr.KeysById(subkeyId); len(keys) != 1 {
+		t.Fatalf("expected the encryption subkey; got %d keys (%v)", len(keys), dkr.SecretError())
+	} else if keys[0].PrivateKey == nil || keys[0].PrivateKey.Encrypted {
+		t.Errorf("subkey wasn't unlocked")
+	}
+
+	// Once a key's unlocked, the message's other recipients shouldn't
+	// cost another prompt.
+	if keys := dkr.KeysById(key.PrimaryKey.KeyId); len(keys) != 1 {
+		t.Errorf("expected the primary key; got %d keys", len(keys))
+	}
+	if keys := dkr.KeysById(subkeyId + 1); len(keys) != 0 {
+		t.Errorf("found %d keys for an unknown key ID", len(keys))
+	}
+	if ui.prompts != 1 {
+		t.Errorf("expected 1 prompt; got %d", ui.prompts)
+	}
+
+	// If we can't unlock it, say why. (The P3SKB we unlocked above keeps
+	// hold of the unlocked key, so start again with a locked one.)
+	if ring.Blocks[0], err = key.ToP3SKB(tsec); err != nil {
+		t.Fatal(err)
+	}
+	dkr = DecryptKeyRing{Reason: "test", SecretUI: &testSecretUI{}}
+	if keys := dkr.KeysById(subkeyId); len(keys) != 0 {
+		t.Errorf("got keys without unlocking them")
+	}
+	if dkr.SecretError() == nil {
+		t.Errorf("no error for a key we couldn't unlock")
+	}
+}
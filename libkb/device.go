@@ -1,8 +1,10 @@
 package libkb
 
 import (
+	"crypto/rand"
 	"encoding/hex"
 	"fmt"
+	"github.com/keybase/go-jsonw"
 )
 
 const (
@@ -43,3 +45,129 @@ type DeviceStatus struct {
 	Keyed        bool
 	KeyAvailable bool
 }
+
+// NewDeviceId makes a new, random device ID.
+func NewDeviceId() (ret DeviceId, err error) {
+	if _, err = rand.Read(ret[:]); err == nil {
+		ret[DEVICE_ID_LEN-1] = DEVICE_ID_SUFFIX
+	}
+	return
+}
+
+const (
+	DEVICE_TYPE_DESKTOP = "desktop"
+	DEVICE_TYPE_MOBILE  = "mobile"
+	DEVICE_TYPE_SERVER  = "server"
+	DEVICE_TYPE_PAPER   = "paper"
+)
+
+// Device is the record we sign into the sibkey delegation for a device's
+// key, so that the sigchain says which device holds which key.
+type Device struct {
+	Id   DeviceId
+	Name string
+	Type string
+}
+
+// NewLocalDevice describes this device, minting a new device ID if we
+// don't have one in our config yet.
+func NewLocalDevice() (ret *Device, err error) {
+	ret = &Device{
+		Name: G.Env.GetDeviceName(),
+		Type: DEVICE_TYPE_DESKTOP,
+	}
+	if did := G.Env.GetDeviceId(); did != nil {
+		ret.Id = *did
+	} else if ret.Id, err = NewDeviceId(); err != nil {
+		ret = nil
+	}
+	return
+}
+
+// WriteDeviceConfig remembers in our config which device we are, and
+// which sibkey we delegated for it.
+func WriteDeviceConfig(device *Device, kid KID) error {
+	cw := G.Env.GetConfigWriter()
+	if cw == nil {
+		return fmt.Errorf("No configuration writer available")
+	}
+	if err := cw.SetDeviceId(&device.Id); err != nil {
+		return err
+	}
+	if err := cw.SetPerDeviceKID(kid); err != nil {
+		return err
+	}
+	return cw.Write()
+}
+
+func (d *Device) Export() *jsonw.Wrapper {
+	ret := jsonw.NewDictionary()
+	ret.SetKey("id", jsonw.NewString(d.Id.String()))
+	ret.SetKey("name", jsonw.NewString(d.Name))
+	ret.SetKey("type", jsonw.NewString(d.Type))
+	return ret
+}
+
+func ParseDevice(jw *jsonw.Wrapper) (ret *Device, err error) {
+	var s string
+	var did *DeviceId
+	d := &Device{}
+	if s, err = jw.AtKey("id").GetString(); err != nil {
+		return
+	} else if did, err = ImportDeviceId(s); err != nil {
+		return
+	}
+	d.Id = *did
+	if d.Name, err = jw.AtKey("name").GetString(); err != nil {
+		return
+	}
+	if d.Type, err = jw.AtKey("type").GetString(); err != nil {
+		return
+	}
+	ret = d
+	return
+}
+
+// DeviceKey is a device as we find it in a user's sigchain, with the
+// status of its key.
+type DeviceKey struct {
+	Device *Device
+	Kid    KID
+	Link   *SibkeyChainLink // the link that delegated the key
+	Status int              // KEY_LIVE or KEY_REVOKED
+	Known  bool             // whether the key is in the computed key family
+}
+
+func (dk *DeviceKey) Active() bool { return dk.Known && dk.Status == KEY_LIVE }
+
+// GetDevices finds the user's devices from the sibkey delegations in
+// their sigchain, oldest first. If a device was delegated more than one
+// key, the latest one wins.
+func (u *User) GetDevices() (ret []*DeviceKey, err error) {
+	var ckf *ComputedKeyFamily
+	if ckf, err = u.RequireComputedKeyFamily(); err != nil {
+		return
+	}
+	byId := make(map[DeviceId]*DeviceKey)
+	for _, link := range u.sigChain.LimitToKeyFamily(u.keyFamily) {
+		tcl, _ := NewTypedChainLink(link)
+		sk, ok := tcl.(*SibkeyChainLink)
+		if !ok || sk.device == nil {
+			continue
+		}
+		dk := byId[sk.device.Id]
+		if dk == nil {
+			dk = &DeviceKey{}
+			byId[sk.device.Id] = dk
+			ret = append(ret, dk)
+		}
+		dk.Device, dk.Kid, dk.Link = sk.device, sk.kid, sk
+	}
+	for _, dk := range ret {
+		if info, found := ckf.cki.Infos[dk.Kid.String()]; found {
+			dk.Known = true
+			dk.Status = info.Status
+		}
+	}
+	return
+}
@@ -0,0 +1,55 @@
+package libkb
+
+import (
+	"github.com/keybase/go-jsonw"
+	"testing"
+)
+
+func TestDeviceExportImport(t *testing.T) {
+	did, err := NewDeviceId()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err = ImportDeviceId(did.String()); err != nil {
+		t.Fatalf("new device ID didn't import: %s", err.Error())
+	}
+	d := &Device{Id: did, Name: "ci-runner-3", Type: DEVICE_TYPE_SERVER}
+	d2, err := ParseDevice(d.Export())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if *d2 != *d {
+		t.Errorf("device didn't survive a round trip: %+v != %+v", *d2, *d)
+	}
+}
+
+func TestParseSibkeyChainLinkDevice(t *testing.T) {
+	did, _ := NewDeviceId()
+	d := &Device{Id: did, Name: "laptop", Type: DEVICE_TYPE_DESKTOP}
+	kid := KID{0x01, 0x20, 0xaa, 0xbb, 0x0a}
+
+	body := jsonw.NewDictionary()
+	sibkey := jsonw.NewDictionary()
+	sibkey.SetKey("kid", jsonw.NewString(kid.String()))
+	body.SetKey("sibkey", sibkey)
+	body.SetKey("device", d.Export())
+	payload := jsonw.NewDictionary()
+	payload.SetKey("body", body)
+
+	link := &ChainLink{payloadJson: payload, unpacked: &ChainLinkUnpacked{}}
+	sk, err := ParseSibkeyChainLink(GenericChainLink{link})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if sk.GetDevice() == nil || *sk.GetDevice() != *d {
+		t.Errorf("expected device %+v, got %+v", *d, sk.GetDevice())
+	}
+
+	// Delegations from before we had devices don't name one.
+	body.DeleteKey("device")
+	if sk, err = ParseSibkeyChainLink(GenericChainLink{link}); err != nil {
+		t.Fatal(err)
+	} else if sk.GetDevice() != nil {
+		t.Errorf("expected no device, got %+v", *sk.GetDevice())
+	}
+}
@@ -0,0 +1,263 @@
+package libkb
+
+import (
+	"fmt"
+	"golang.org/x/crypto/openpgp"
+	"golang.org/x/crypto/openpgp/armor"
+	"io"
+	"io/ioutil"
+)
+
+type EncryptArg struct {
+	Recipients []string // assertions, like max+max@github
+	Source     io.Reader
+	Sink       io.Writer
+	Binary     bool // armored by default
+	NoSelf     bool // don't also encrypt for our own keys
+	Sign       bool // sign with our selected key in the same pass
+	Nacl       bool // encrypt for NaCl DH device keys rather than PGP keys
+	Stream     bool // with Nacl, write a chunked stream rather than one packet
+
+	IdentifyUI IdentifyUI
+	SecretUI   SecretUI
+	LogUI      LogUI
+}
+
+// EncryptEngine encrypts a stream for the active PGP keys of one or more
+// Keybase users, after identifying each of them.
+type EncryptEngine struct {
+	arg      *EncryptArg
+	keys     []*PgpKeyBundle
+	naclKeys []NaclDHKeyPublic
+	signer   *PgpKeyBundle
+}
+
+func NewEncryptEngine(arg *EncryptArg) *EncryptEngine {
+	return &EncryptEngine{arg: arg}
+}
+
+// addKeys adds all of the named user's active PGP keys that can encrypt.
+// It fails if there aren't any.
+func (e *EncryptEngine) addKeys(name string, keys []*PgpKeyBundle) error {
+	n := 0
+	for _, k := range keys {
+		if !k.CanEncrypt() {
+			continue
+		}
+		n++
+		if !e.haveKey(k) {
+			e.keys = append(e.keys, k)
+		}
+	}
+	if n == 0 {
+		return NoKeyError{fmt.Sprintf("%s has no active PGP keys that can encrypt", name)}
+	}
+	return nil
+}
+
+// addNaclKeys adds all of u's active NaCl DH keys, so that any of u's
+// devices can decrypt. It fails if there aren't any.
+func (e *EncryptEngine) addNaclKeys(u *User) error {
+	keys := u.GetActiveNaclDHKeys()
+	if len(keys) == 0 {
+		return NoKeyError{fmt.Sprintf("%s has no active NaCl DH keys", u.GetName())}
+	}
+	for _, k := range keys {
+		if !e.haveNaclKey(k.Public) {
+			e.naclKeys = append(e.naclKeys, k.Public)
+		}
+	}
+	return nil
+}
+
+func (e *EncryptEngine) haveNaclKey(k NaclDHKeyPublic) bool {
+	for _, k2 := range e.naclKeys {
+		if k2 == k {
+			return true
+		}
+	}
+	return false
+}
+
+func (e *EncryptEngine) addUser(u *User) error {
+	if e.arg.Nacl {
+		return e.addNaclKeys(u)
+	}
+	return e.addKeys(u.GetName(), u.GetActivePgpKeys(false))
+}
+
+func (e *EncryptEngine) haveKey(k *PgpKeyBundle) bool {
+	for _, k2 := range e.keys {
+		if k2.GetFingerprint().Eq(k.GetFingerprint()) {
+			return true
+		}
+	}
+	return false
+}
+
+func (e *EncryptEngine) loadRecipients() (err error) {
+	for _, a := range e.arg.Recipients {
+		res := LoadUserByAssertions(a, true, e.arg.IdentifyUI)
+		if res.Error != nil {
+			return res.Error
+		}
+		e.arg.LogUI.Debug("| Recipient %s resolved to %s", a, res.User.GetName())
+		if err = e.addUser(res.User); err != nil {
+			return
+		}
+	}
+
+	if !e.arg.NoSelf {
+		var me *User
+		if me, err = LoadMe(LoadUserArg{}); err != nil {
+			return
+		}
+		err = e.addUser(me)
+	}
+	return
+}
+
+func (e *EncryptEngine) loadSigner() (err error) {
+	var key GenericKey
+	var ok bool
+	if key, err = G.Keyrings.GetSecretKey("signature of encrypted message", e.arg.SecretUI); err != nil {
+		return
+	} else if key == nil {
+		err = NoSecretKeyError{}
+	} else if e.signer, ok = key.(*PgpKeyBundle); !ok {
+		err = KeyCannotSignError{}
+	}
+	return
+}
+
+func (e *EncryptEngine) Run() (err error) {
+	G.Log.Debug("+ EncryptEngine.Run")
+	defer func() {
+		G.Log.Debug("- EncryptEngine.Run -> %s", ErrToOk(err))
+	}()
+
+	if e.arg.LogUI == nil {
+		e.arg.LogUI = G.UI.GetLogUI()
+	}
+	if len(e.arg.Recipients) == 0 && e.arg.NoSelf {
+		return fmt.Errorf("No recipients given")
+	}
+
+	if e.arg.Nacl && e.arg.Sign {
+		return fmt.Errorf("NaCl messages can't be signed")
+	}
+	if e.arg.Stream && !e.arg.Nacl {
+		return fmt.Errorf("Only NaCl messages can be streamed")
+	}
+	if e.arg.Nacl && !e.arg.Stream && e.arg.Binary {
+		return fmt.Errorf("NaCl packets are always armored; stream for binary output")
+	}
+
+	if err = e.loadRecipients(); err != nil {
+		return
+	}
+	if e.arg.Stream {
+		return e.runNaclStream()
+	} else if e.arg.Nacl {
+		return e.runNacl()
+	}
+	return e.runPgp()
+}
+
+// runPgp encrypts the source for the recipients' PGP keys, and signs it
+// too if asked.
+func (e *EncryptEngine) runPgp() (err error) {
+	if e.arg.Sign {
+		if err = e.loadSigner(); err != nil {
+			return
+		}
+	}
+
+	to := make([]*openpgp.Entity, len(e.keys))
+	for i, k := range e.keys {
+		to[i] = (*openpgp.Entity)(k)
+	}
+
+	var aout io.WriteCloser
+	var out io.Writer = e.arg.Sink
+	if !e.arg.Binary {
+		if aout, err = armor.Encode(e.arg.Sink, "PGP MESSAGE", PgpArmorHeaders()); err != nil {
+			return
+		}
+		out = aout
+	}
+
+	var in io.WriteCloser
+	if in, err = openpgp.Encrypt(out, to, (*openpgp.Entity)(e.signer), nil, nil); err != nil {
+		return
+	}
+
+	var written int64
+	if written, err = io.Copy(in, e.arg.Source); err == nil && written == 0 {
+		err = fmt.Errorf("Empty source file, nothing to encrypt")
+	}
+	if err != nil {
+		return
+	}
+	if err = in.Close(); err != nil {
+		return
+	}
+	if aout != nil {
+		err = aout.Close()
+	}
+	return
+}
+
+// runNacl encrypts the source for the recipients' NaCl DH keys as a
+// single armored packet.
+func (e *EncryptEngine) runNacl() (err error) {
+	var msg []byte
+	if msg, err = ioutil.ReadAll(e.arg.Source); err != nil {
+		return
+	} else if len(msg) == 0 {
+		return fmt.Errorf("Empty source file, nothing to encrypt")
+	}
+
+	var enc *NaclEncryption
+	var armored string
+	if enc, err = NaclEncrypt(msg, e.naclKeys, nil); err != nil {
+		return
+	}
+	if armored, err = enc.ArmoredEncode(); err != nil {
+		return
+	}
+	_, err = io.WriteString(e.arg.Sink, armored+"\n")
+	return
+}
+
+// runNaclStream encrypts the source for the recipients' NaCl DH keys as
+// a chunked stream, so that large inputs never have to fit in memory.
+func (e *EncryptEngine) runNaclStream() (err error) {
+	var aout io.WriteCloser
+	var out io.Writer = e.arg.Sink
+	if !e.arg.Binary {
+		if aout, err = armor.Encode(e.arg.Sink, NACL_ENCRYPTED_ARMOR, PgpArmorHeaders()); err != nil {
+			return
+		}
+		out = aout
+	}
+
+	var in io.WriteCloser
+	if in, err = NewNaclEncryptStream(out, e.naclKeys, nil); err != nil {
+		return
+	}
+	var written int64
+	if written, err = io.Copy(in, e.arg.Source); err == nil && written == 0 {
+		err = fmt.Errorf("Empty source file, nothing to encrypt")
+	}
+	if err != nil {
+		return
+	}
+	if err = in.Close(); err != nil {
+		return
+	}
+	if aout != nil {
+		err = aout.Close()
+	}
+	return
+}
@@ -0,0 +1,126 @@
+package libkb
+
+import (
+	"bytes"
+	"golang.org/x/crypto/openpgp"
+	"golang.org/x/crypto/openpgp/armor"
+	"io/ioutil"
+	"strings"
+	"testing"
+	"time"
+)
+
+// withoutEncryptionSubkeys copies key, leaving out the subkeys it would
+// encrypt for.
+func withoutEncryptionSubkeys(key *PgpKeyBundle) *PgpKeyBundle {
+	ret := *key
+	ret.Subkeys = nil
+	for _, sk := range key.Subkeys {
+		if !sk.Sig.FlagEncryptCommunications {
+			ret.Subkeys = append(ret.Subkeys, sk)
+		}
+	}
+	return &ret
+}
+
+func TestCanEncrypt(t *testing.T) {
+	key := genSigningKey(t)
+	if !key.CanEncrypt() {
+		t.Errorf("key with an encryption subkey can't encrypt")
+	}
+	if withoutEncryptionSubkeys(key).CanEncrypt() {
+		t.Errorf("key without an encryption subkey can encrypt")
+	}
+
+	// An expired encryption subkey doesn't count.
+	expired := *key
+	expired.Subkeys = nil
+	for _, sk := range key.Subkeys {
+		if sk.Sig.FlagEncryptCommunications {
+			sig := *sk.Sig
+			sig.CreationTime = time.Now().Add(-48 * time.Hour)
+			lifetime := uint32(24 * 60 * 60)
+			sig.KeyLifetimeSecs = &lifetime
+			sk.Sig = &sig
+		}
+		expired.Subkeys = append(expired.Subkeys, sk)
+	}
+	if expired.CanEncrypt() {
+		t.Errorf("key with an expired encryption subkey can encrypt")
+	}
+}
+
+func TestEncryptAddKeys(t *testing.T) {
+	key := genSigningKey(t)
+	e := NewEncryptEngine(&EncryptArg{})
+
+	// Naming the same key twice, say as two assertions for the same
+	// user, only encrypts for it once.
+	if err := e.addKeys("max", []*PgpKeyBundle{key, key}); err != nil {
+		t.Fatal(err)
+	}
+	if err := e.addKeys("max", []*PgpKeyBundle{key}); err != nil {
+		t.Fatal(err)
+	}
+	if len(e.keys) != 1 {
+		t.Errorf("expected 1 recipient key; got %d", len(e.keys))
+	}
+
+	if err := e.addKeys("chris", []*PgpKeyBundle{withoutEncryptionSubkeys(key)}); err == nil {
+		t.Errorf("added a user whose keys can't encrypt")
+	} else if _, ok := err.(NoKeyError); !ok {
+		t.Errorf("expected a NoKeyError; got %s", err)
+	}
+	if err := e.addKeys("nobody", nil); err == nil {
+		t.Errorf("added a user with no keys")
+	}
+}
+
+func TestEncryptPgp(t *testing.T) {
+	key := genSigningKey(t)
+	// Our test keys don't state hash preferences, so openpgp falls back
+	// to RIPEMD-160, which we don't link in.
+	for _, id := range key.Identities {
+		id.SelfSignature.PreferredHash = []uint8{8} // SHA-256
+	}
+
+	var out bytes.Buffer
+	e := NewEncryptEngine(&EncryptArg{Source: strings.NewReader(""), Sink: &out})
+	e.keys = []*PgpKeyBundle{key}
+	if err := e.runPgp(); err == nil {
+		t.Errorf("encrypted an empty source")
+	}
+
+	msg := "attack at dawn"
+	out.Reset()
+	e = NewEncryptEngine(&EncryptArg{Source: strings.NewReader(msg), Sink: &out})
+	e.keys = []*PgpKeyBundle{key}
+	if err := e.runPgp(); err != nil {
+		t.Fatalf("encrypt error: %s", err)
+	}
+	block, err := armor.Decode(&out)
+	if err != nil {
+		t.Fatalf("armor error: %s", err)
+	}
+	md, err := openpgp.ReadMessage(block.Body, openpgp.EntityList{(*openpgp.Entity)(key)}, nil, nil)
+	if err != nil {
+		t.Fatalf("decrypt error: %s", err)
+	}
+	if md.IsSigned {
+		t.Errorf("message was signed without --sign")
+	}
+	body, err := ioutil.ReadAll(md.UnverifiedBody)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(body) != msg {
+		t.Errorf("expected %q; got %q", msg, body)
+	}
+}
+
+func TestEncryptNoRecipients(t *testing.T) {
+	e := NewEncryptEngine(&EncryptArg{NoSelf: true, LogUI: G.Log})
+	if err := e.Run(); err == nil {
+		t.Errorf("encrypted for nobody")
+	}
+}
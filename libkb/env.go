@@ -5,6 +5,7 @@ import (
 	"path/filepath"
 	"strconv"
 	"strings"
+	"time"
 )
 
 type NullConfiguration struct{}
@@ -506,6 +507,34 @@ func (e Env) GetPerDeviceKID() (ret KID) {
 	return
 }
 
+// GetDeviceName gets the human-readable name we give this device when
+// we delegate its key; by default, it's the hostname.
+func (e Env) GetDeviceName() string {
+	return e.GetString(
+		func() string { return os.Getenv("KEYBASE_DEVICE_NAME") },
+		func() string { s, _ := e.config.GetStringAtPath("device.name"); return s },
+		func() string { s, _ := os.Hostname(); return s },
+	)
+}
+
+// GetSecretCacheIdle is how long the daemon holds an unlocked secret key
+// that isn't being used. Zero turns the cache off.
+func (e Env) GetSecretCacheIdle() time.Duration {
+	return time.Duration(e.GetInt(int(SECRET_CACHE_IDLE/time.Second),
+		func() (int, bool) { return e.getEnvInt("KEYBASE_SECRET_CACHE_IDLE") },
+		func() (int, bool) { return e.config.GetIntAtPath("secret_cache.idle") },
+	)) * time.Second
+}
+
+// GetSecretCacheMaxTTL is the longest the daemon holds an unlocked secret
+// key, however often it's used. Zero turns the cache off.
+func (e Env) GetSecretCacheMaxTTL() time.Duration {
+	return time.Duration(e.GetInt(int(SECRET_CACHE_MAX_TTL/time.Second),
+		func() (int, bool) { return e.getEnvInt("KEYBASE_SECRET_CACHE_MAX_TTL") },
+		func() (int, bool) { return e.config.GetIntAtPath("secret_cache.max_ttl") },
+	)) * time.Second
+}
+
 func (e Env) GetDeviceId() (ret *DeviceId) {
 	s := e.GetString(
 		func() string { return e.cmd.GetDeviceId() },
@@ -546,6 +546,16 @@ func (e SelfTrackError) Error() string {
 
 //=============================================================================
 
+type NotTrackingError struct {
+	name string
+}
+
+func (e NotTrackingError) Error() string {
+	return fmt.Sprintf("You aren't tracking %s", e.name)
+}
+
+//=============================================================================
+
 type NoUiError struct {
 	which string
 }
@@ -665,3 +675,81 @@ func (e UnknownKeyTypeError) Error() string {
 }
 
 //=============================================================================
+
+type NotSignedError struct{}
+
+func (e NotSignedError) Error() string {
+	return "Message wasn't signed"
+}
+
+//=============================================================================
+
+type DecryptionError struct{}
+
+func (e DecryptionError) Error() string {
+	return "Decryption failed"
+}
+
+//=============================================================================
+
+type StreamError struct {
+	msg string
+}
+
+func (e StreamError) Error() string {
+	return "Bad stream: " + e.msg
+}
+
+//=============================================================================
+
+type SigBundleError struct {
+	msg string
+}
+
+func (e SigBundleError) Error() string {
+	return "Bad sig bundle: " + e.msg
+}
+
+//=============================================================================
+
+type MerkleForkError struct {
+	seqno        Seqno
+	ours, theirs string
+}
+
+func (e MerkleForkError) Error() string {
+	return fmt.Sprintf("Server forked Merkle tree at %d: root %s != %s, as logged",
+		int(e.seqno), e.theirs, e.ours)
+}
+
+//=============================================================================
+
+type MerkleProofError struct {
+	msg string
+}
+
+func (e MerkleProofError) Error() string {
+	return "Bad Merkle proof: " + e.msg
+}
+
+//=============================================================================
+
+type KexError struct {
+	msg string
+}
+
+func (e KexError) Error() string {
+	return "Key exchange failed: " + e.msg
+}
+
+//=============================================================================
+
+type KexPeerError struct {
+	msg string
+}
+
+func (e KexPeerError) Error() string {
+	return "The other device gave up on the key exchange: " + e.msg
+}
+
+//=============================================================================
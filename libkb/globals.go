@@ -41,6 +41,8 @@ type Global struct {
 	SocketInfo    SocketInfo     // which socket to bind/connect to
 	SocketWrapper *SocketWrapper // only need one connection per
 	SecretSyncer  *SecretSyncer  // For syncing secrets between the server and client
+	SecretCache   *SecretCache   // Unlocked secret keys, held by the daemon
+	SecretAgent   SecretKeyCache // The daemon's SecretCache, as seen from a client
 	UI            UI             // Interact with the UI
 	Daemon        bool           // whether we're in daemon mode
 	shutdown      bool           // whether we've shut down or not
@@ -144,6 +146,25 @@ func (g *Global) ConfigureSecretSyncer() error {
 	return nil
 }
 
+// ConfigureSecretCache is only for the daemon; a one-off command
+// shouldn't keep unlocked keys around.
+func (g *Global) ConfigureSecretCache() error {
+	g.SecretCache = NewSecretCache(g.Env.GetSecretCacheIdle(), g.Env.GetSecretCacheMaxTTL())
+	return nil
+}
+
+// secretKeyCache is where GetSecretKey keeps unlocked keys: our own
+// cache in the daemon, or the daemon's, over the socket, in a client.
+func (g *Global) secretKeyCache() SecretKeyCache {
+	if g.SecretCache.enabled() {
+		return g.SecretCache
+	}
+	if g.SecretAgent != nil && !g.Env.GetStandalone() {
+		return g.SecretAgent
+	}
+	return nil
+}
+
 func (g *Global) Shutdown() error {
 	if g.shutdown {
 		return nil
@@ -154,6 +175,7 @@ func (g *Global) Shutdown() error {
 	if g.UI != nil {
 		epick.Push(g.UI.Shutdown())
 	}
+	g.SecretCache.Clear()
 	if g.LocalDb != nil {
 		epick.Push(g.LocalDb.Close())
 	}
@@ -105,7 +105,7 @@ func (g *GpgCLI) ImportKey(secret bool, fp PgpFingerprint) (ret *PgpKeyBundle, e
 	return
 }
 
-func (g *GpgCLI) ExportKey(k PgpKeyBundle) (err error) {
+func (g *GpgCLI) ExportKey(k PgpKeyBundle, secret bool) (err error) {
 	arg := RunGpgArg{
 		Arguments: []string{"--import"},
 		Stdin:     true,
@@ -115,7 +115,12 @@ func (g *GpgCLI) ExportKey(k PgpKeyBundle) (err error) {
 		return res.Err
 	}
 
-	e1 := k.EncodeToStream(res.Stdin)
+	var e1 error
+	if secret {
+		e1 = k.EncodeSecretToStream(res.Stdin)
+	} else {
+		e1 = k.EncodeToStream(res.Stdin)
+	}
 	e2 := res.Stdin.Close()
 	e3 := res.Wait()
 	return PickFirstError(e1, e2, e3)
@@ -72,6 +72,9 @@ func (g *GenericChainLink) GetPgpFingerprint() *PgpFingerprint {
 func (g *GenericChainLink) GetCTime() time.Time {
 	return time.Unix(int64(g.unpacked.ctime), 0)
 }
+func (g *GenericChainLink) GetETime() time.Time {
+	return time.Unix(int64(g.unpacked.etime), 0)
+}
 func (g *GenericChainLink) GetArmoredSig() string {
 	return g.unpacked.sig
 }
@@ -451,15 +454,22 @@ func (l *TrackChainLink) ToServiceBlocks() (ret []*ServiceBlock) {
 
 type SibkeyChainLink struct {
 	GenericChainLink
-	kid KID
+	kid    KID
+	device *Device
 }
 
 func ParseSibkeyChainLink(b GenericChainLink) (ret *SibkeyChainLink, err error) {
 	var kid KID
+	var device *Device
 	if kid, err = GetKID(b.payloadJson.AtPath("body.sibkey.kid")); err != nil {
 		err = fmt.Errorf("Bad sibkey statement @%s: %s", b.ToDebugString(), err.Error())
-	} else {
-		ret = &SibkeyChainLink{b, kid}
+	} else if jw := b.payloadJson.AtPath("body.device"); !jw.IsNil() {
+		if device, err = ParseDevice(jw); err != nil {
+			err = fmt.Errorf("Bad device in sibkey statement @%s: %s", b.ToDebugString(), err.Error())
+		}
+	}
+	if err == nil {
+		ret = &SibkeyChainLink{b, kid, device}
 	}
 	return
 
@@ -469,6 +479,7 @@ func (s *SibkeyChainLink) GetDelegatedKid() KID    { return s.kid }
 func (s *SibkeyChainLink) IsDelegation() KeyStatus { return DLG_SIBKEY }
 func (s *SibkeyChainLink) Type() string            { return "sibkey" }
 func (r *SibkeyChainLink) ToDisplayString() string { return r.kid.String() }
+func (s *SibkeyChainLink) GetDevice() *Device       { return s.device }
 
 //
 //=========================================================================
@@ -546,12 +557,17 @@ type CryptocurrencyChainLink struct {
 	GenericChainLink
 	pkhash  []byte
 	address string
+	typ     string
 }
 
 func (c CryptocurrencyChainLink) GetAddress() string {
 	return c.address
 }
 
+func (c CryptocurrencyChainLink) GetCurrencyType() string {
+	return c.typ
+}
+
 func ParseCryptocurrencyChainLink(b GenericChainLink) (
 	cl *CryptocurrencyChainLink, err error) {
 
@@ -566,17 +582,12 @@ func ParseCryptocurrencyChainLink(b GenericChainLink) (
 		return
 	}
 
-	if typ != "bitcoin" {
-		err = fmt.Errorf("Can only handle 'bitcoin' addresses for now; got %s", typ)
-		return
-	}
-
-	_, pkhash, err = BtcAddrCheck(addr, nil)
+	pkhash, err = CryptocurrencyAddrCheck(typ, addr)
 	if err != nil {
 		err = fmt.Errorf("At signature %s: %s", b.ToDebugString(), err.Error())
 		return
 	}
-	cl = &CryptocurrencyChainLink{b, pkhash, addr}
+	cl = &CryptocurrencyChainLink{b, pkhash, addr, typ}
 	return
 }
 
@@ -596,6 +607,59 @@ func (l CryptocurrencyChainLink) Display(ui IdentifyUI) {
 //
 //=========================================================================
 
+//=========================================================================
+// AnnouncementChainLink
+
+type AnnouncementChainLink struct {
+	GenericChainLink
+	message string
+}
+
+func ParseAnnouncementChainLink(b GenericChainLink) (ret *AnnouncementChainLink, err error) {
+	var msg string
+	msg, err = b.payloadJson.AtPath("body.announcement.message").GetString()
+	if err != nil {
+		err = fmt.Errorf("Bad announcement @%s: %s", b.ToDebugString(), err.Error())
+	} else {
+		ret = &AnnouncementChainLink{b, msg}
+	}
+	return
+}
+
+func (a *AnnouncementChainLink) Type() string { return "announcement" }
+
+func (a *AnnouncementChainLink) ToDisplayString() string { return a.message }
+
+func (a *AnnouncementChainLink) GetMessage() string { return a.message }
+
+func (a *AnnouncementChainLink) insertIntoTable(tab *IdentityTable) {
+	tab.insertLink(a)
+	tab.announcements = append(tab.announcements, a)
+}
+
+// Verify checks the announcement's signature against the key in the
+// user's key family that made it, whether or not that key is still
+// active, and returns that key.
+func (a *AnnouncementChainLink) Verify(kf KeyFamily) (key GenericKey, err error) {
+	var sigId *SigId
+	if key, err = kf.FindActiveSibkey(a.ToFOKID()); err != nil {
+		return
+	}
+	if err = a.VerifyLink(); err != nil {
+		return
+	}
+	if sigId, err = key.Verify(a.unpacked.sig, []byte(a.unpacked.payloadJsonStr)); err != nil {
+		return
+	}
+	if *sigId != a.GetSigId() {
+		err = WrongSigError{sigId.ToString(true)}
+	}
+	return
+}
+
+//
+//=========================================================================
+
 //=========================================================================
 // RevokeChainLink
 
@@ -677,6 +741,7 @@ type IdentityTable struct {
 	sigHints       *SigHints
 	activeProofs   []RemoteProofChainLink
 	cryptocurrency []*CryptocurrencyChainLink
+	announcements  []*AnnouncementChainLink
 	checkResult    *CheckResult
 	eldest         FOKID
 }
@@ -738,6 +803,8 @@ func NewTypedChainLink(cl *ChainLink) (ret TypedChainLink, w Warning) {
 			ret, err = ParseUntrackChainLink(base)
 		case "cryptocurrency":
 			ret, err = ParseCryptocurrencyChainLink(base)
+		case "announcement":
+			ret, err = ParseAnnouncementChainLink(base)
 		case "revoke":
 			ret = &RevokeChainLink{base}
 		case "sibkey":
@@ -843,16 +910,42 @@ func (idt *IdentityTable) GetTrackingStatementFor(s string, uid UID) (
 	return
 }
 
-func (idt *IdentityTable) ActiveCryptocurrency() *CryptocurrencyChainLink {
-	var ret *CryptocurrencyChainLink
+// Announcements returns all of the user's announcements, oldest first,
+// including those that have since been revoked.
+func (idt *IdentityTable) Announcements() []*AnnouncementChainLink {
+	return idt.announcements
+}
+
+// ActiveCryptocurrency returns the address of the given currency type
+// that was signed in last, unless that signature has since been revoked.
+func (idt *IdentityTable) ActiveCryptocurrency(typ string) *CryptocurrencyChainLink {
 	tab := idt.cryptocurrency
-	if len(tab) > 0 {
-		last := tab[len(tab)-1]
-		if !last.IsRevoked() {
-			ret = last
+	for i := len(tab) - 1; i >= 0; i-- {
+		if tab[i].typ != typ {
+			continue
+		}
+		if tab[i].IsRevoked() {
+			return nil
 		}
+		return tab[i]
 	}
-	return ret
+	return nil
+}
+
+// ActiveCryptocurrencies returns one active address per currency type,
+// in the order the types first showed up in the sigchain.
+func (idt *IdentityTable) ActiveCryptocurrencies() (ret []*CryptocurrencyChainLink) {
+	seen := make(map[string]bool)
+	for _, l := range idt.cryptocurrency {
+		if seen[l.typ] {
+			continue
+		}
+		seen[l.typ] = true
+		if acc := idt.ActiveCryptocurrency(l.typ); acc != nil {
+			ret = append(ret, acc)
+		}
+	}
+	return
 }
 
 func (idt *IdentityTable) CollectAndDedupeActiveProofs() {
@@ -902,7 +995,7 @@ func (idt *IdentityTable) Identify(is IdentifyState) {
 	// wait for all goroutines to complete before exiting
 	wg.Wait()
 
-	if acc := idt.ActiveCryptocurrency(); acc != nil {
+	for _, acc := range idt.ActiveCryptocurrencies() {
 		acc.Display(is.GetUI())
 	}
 }
@@ -191,7 +191,7 @@ type ExternalAPI interface {
 // A minimal access to the GPG client.
 type GpgClient interface {
 	Configure() (bool, error)                                        // bool = Whether the error is fatal or not
-	ExportKey(k PgpKeyBundle) error                                  // Import a key into the GPG keyring
+	ExportKey(k PgpKeyBundle, secret bool) error                     // Import a key into the GPG keyring
 	Index(secret bool, query string) (*GpgKeyIndex, error, Warnings) // Index the keychain
 	ImportKey(bool, PgpFingerprint) (*PgpKeyBundle, error)
 }
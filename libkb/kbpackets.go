@@ -148,6 +148,8 @@ func (ret *KeybasePacket) MyUnmarshalBinary(data []byte) (err error) {
 		body = &P3SKB{}
 	case TAG_SIGNATURE:
 		body = &NaclSig{}
+	case TAG_ENCRYPTION:
+		body = &NaclEncryption{}
 	default:
 		err = fmt.Errorf("Unknown packet tag: %d", ret.Tag)
 		return
@@ -67,6 +67,25 @@ func (u *User) ToTrackingStatement(w *jsonw.Wrapper) (err error) {
 	return
 }
 
+// ToUntrackingStatement fills in an untrack statement, which names the
+// user we're no longer tracking. It has the same shape as a tracking
+// statement, minus the snapshot of their proofs and sigchain.
+func (u *User) ToUntrackingStatement(w *jsonw.Wrapper) (err error) {
+	untrack := jsonw.NewDictionary()
+	untrack.SetKey("key", u.ToTrackingStatementKey(&err))
+	untrack.SetKey("basics", u.ToTrackingStatementBasics(&err))
+	untrack.SetKey("id", jsonw.NewString(u.id.String()))
+
+	if err != nil {
+		return
+	}
+
+	w.SetKey("type", jsonw.NewString("untrack"))
+	w.SetKey("version", jsonw.NewInt(KEYBASE_SIGNATURE_V1))
+	w.SetKey("untrack", untrack)
+	return
+}
+
 func (u *User) ToKeyStanza(sk GenericKey, eldest *FOKID) (ret *jsonw.Wrapper, err error) {
 	ret = jsonw.NewDictionary()
 	ret.SetKey("uid", jsonw.NewString(u.id.String()))
@@ -210,6 +229,14 @@ func (u1 *User) TrackingProofFor(signingKey GenericKey, u2 *User) (ret *jsonw.Wr
 	return
 }
 
+func (u1 *User) UntrackingProofFor(signingKey GenericKey, u2 *User) (ret *jsonw.Wrapper, err error) {
+	ret, err = u1.ProofMetadata(0, signingKey, nil)
+	if err == nil {
+		err = u2.ToUntrackingStatement(ret.AtKey("body"))
+	}
+	return
+}
+
 func (u *User) SelfProof(signingKey GenericKey, eldest *FOKID) (ret *jsonw.Wrapper, err error) {
 	ret, err = u.ProofMetadata(0, signingKey, eldest)
 	if err == nil {
@@ -246,7 +273,9 @@ func KeyToProofJson(key GenericKey) *jsonw.Wrapper {
 	return d
 }
 
-func (u *User) KeyProof(newkey GenericKey, signingkey GenericKey, typ string, ei int) (ret *jsonw.Wrapper, err error) {
+// KeyProof makes a sibkey or subkey delegation for newkey. If device
+// is given, the link also says which device holds the new key.
+func (u *User) KeyProof(newkey GenericKey, signingkey GenericKey, typ string, ei int, device *Device) (ret *jsonw.Wrapper, err error) {
 	ret, err = u.ProofMetadata(ei, signingkey, nil)
 	if err != nil {
 		return
@@ -257,5 +286,76 @@ func (u *User) KeyProof(newkey GenericKey, signingkey GenericKey, typ string, ei
 
 	// 'typ' can be 'subkey' or 'sibkey'
 	body.SetKey(typ, KeyToProofJson(newkey))
+	if device != nil {
+		body.SetKey("device", device.Export())
+	}
+	return
+}
+
+// CryptocurrencySig signs a cryptocurrency address into our sigchain. If
+// sigToRevoke is given, the link also revokes that earlier signature,
+// which is how an address gets replaced.
+func (u *User) CryptocurrencySig(signingKey GenericKey, typ string, address string, sigToRevoke *SigId) (ret *jsonw.Wrapper, err error) {
+	ret, err = u.ProofMetadata(0, signingKey, nil)
+	if err != nil {
+		return
+	}
+	body := ret.AtKey("body")
+	body.SetKey("version", jsonw.NewInt(KEYBASE_SIGNATURE_V1))
+	body.SetKey("type", jsonw.NewString("cryptocurrency"))
+
+	currency := jsonw.NewDictionary()
+	currency.SetKey("type", jsonw.NewString(typ))
+	currency.SetKey("address", jsonw.NewString(address))
+	body.SetKey("cryptocurrency", currency)
+
+	if sigToRevoke != nil {
+		revoke := jsonw.NewDictionary()
+		revoke.SetKey("sig_id", jsonw.NewString(sigToRevoke.ToString(true)))
+		body.SetKey("revoke", revoke)
+	}
+	return
+}
+
+func (u *User) AnnouncementSig(signingKey GenericKey, msg string) (ret *jsonw.Wrapper, err error) {
+	ret, err = u.ProofMetadata(0, signingKey, nil)
+	if err != nil {
+		return
+	}
+	body := ret.AtKey("body")
+	body.SetKey("version", jsonw.NewInt(KEYBASE_SIGNATURE_V1))
+	body.SetKey("type", jsonw.NewString("announcement"))
+
+	announcement := jsonw.NewDictionary()
+	announcement.SetKey("message", jsonw.NewString(msg))
+	body.SetKey("announcement", announcement)
+	return
+}
+
+func (u *User) RevokeKeysProof(signingkey GenericKey, kids []KID, sigs []SigId) (ret *jsonw.Wrapper, err error) {
+	ret, err = u.ProofMetadata(0, signingkey, nil)
+	if err != nil {
+		return
+	}
+	body := ret.AtKey("body")
+	body.SetKey("version", jsonw.NewInt(KEYBASE_SIGNATURE_V1))
+	body.SetKey("type", jsonw.NewString("revoke"))
+
+	revoke := jsonw.NewDictionary()
+	if len(kids) > 0 {
+		v := jsonw.NewArray(len(kids))
+		for i, kid := range kids {
+			v.SetIndex(i, jsonw.NewString(kid.String()))
+		}
+		revoke.SetKey("kids", v)
+	}
+	if len(sigs) > 0 {
+		v := jsonw.NewArray(len(sigs))
+		for i, sig := range sigs {
+			v.SetIndex(i, jsonw.NewString(sig.ToString(true)))
+		}
+		revoke.SetKey("sig_ids", v)
+	}
+	body.SetKey("revoke", revoke)
 	return
 }
@@ -0,0 +1,258 @@
+package libkb
+
+import (
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"fmt"
+	"github.com/keybase/go-jsonw"
+	"golang.org/x/crypto/nacl/secretbox"
+	"time"
+)
+
+// Key exchange ("kex") between two of a user's devices. The new device
+// makes up a short secret, and shows it to the user as a phrase, which
+// they type into the existing device. Both sides derive from it a
+// session ID and a secretbox key; they then swap sealed messages through
+// a relay, which sees only the session ID, and so can't read or forge
+// them. Since the secret is short, it's only good for the one session.
+
+const (
+	KEX_SECRET_LEN     = 8 // bytes, and so words in the phrase
+	KEX_SESSION_ID_LEN = 16
+	KEX_NONCE_LEN      = 24
+	KEX_TIMEOUT        = 5 * time.Minute
+	KEX_POLL_WAIT      = 30 // seconds to ask the relay to hold a receive
+)
+
+type KexSecret [KEX_SECRET_LEN]byte
+
+func NewKexSecret() (ret KexSecret, err error) {
+	_, err = rand.Read(ret[:])
+	return
+}
+
+// ParseKexSecret reads back the phrase that Phrase makes.
+func ParseKexSecret(phrase string) (ret KexSecret, err error) {
+	var b []byte
+	if b, err = WordsToBytes(phrase); err != nil {
+		return
+	}
+	if len(b) != KEX_SECRET_LEN {
+		err = KexError{fmt.Sprintf("secret phrase should be %d words, not %d", KEX_SECRET_LEN, len(b))}
+		return
+	}
+	copy(ret[:], b)
+	return
+}
+
+func (s KexSecret) Phrase() string { return BytesToWords(s[:]) }
+
+func (s KexSecret) derive(label string) []byte {
+	mac := hmac.New(sha256.New, s[:])
+	mac.Write([]byte("Keybase-Kex-1 " + label))
+	return mac.Sum(nil)
+}
+
+func (s KexSecret) SessionId() string {
+	return hex.EncodeToString(s.derive("session id")[0:KEX_SESSION_ID_LEN])
+}
+
+type KexRole int
+
+const (
+	KEX_ROLE_NEW      KexRole = 1 // the device being provisioned
+	KEX_ROLE_EXISTING KexRole = 2 // the device that vouches for it
+)
+
+func (r KexRole) String() string {
+	switch r {
+	case KEX_ROLE_NEW:
+		return "new"
+	case KEX_ROLE_EXISTING:
+		return "existing"
+	}
+	return "unknown"
+}
+
+func (r KexRole) Peer() KexRole {
+	if r == KEX_ROLE_NEW {
+		return KEX_ROLE_EXISTING
+	}
+	return KEX_ROLE_NEW
+}
+
+// KexMailbox names where the relay keeps the messages for one side of
+// one session.
+type KexMailbox string
+
+// KexRelay carries messages between the two devices. Messages in each
+// mailbox are numbered from 1; Receive waits for the given one to show
+// up, or gives up with an error.
+type KexRelay interface {
+	Send(mb KexMailbox, seqno int, msg []byte) error
+	Receive(mb KexMailbox, seqno int) ([]byte, error)
+}
+
+//=============================================================================
+
+// KexApiRelay relays messages through the Keybase API server.
+type KexApiRelay struct{}
+
+func (r KexApiRelay) Send(mb KexMailbox, seqno int, msg []byte) (err error) {
+	_, err = G.API.Post(ApiArg{
+		Endpoint:    "kex/send",
+		NeedSession: false,
+		Args: HttpArgs{
+			"mailbox": S{string(mb)},
+			"seqno":   I{seqno},
+			"msg":     S{base64.StdEncoding.EncodeToString(msg)},
+		},
+	})
+	return
+}
+
+func (r KexApiRelay) Receive(mb KexMailbox, seqno int) (ret []byte, err error) {
+	deadline := time.Now().Add(KEX_TIMEOUT)
+	for time.Now().Before(deadline) {
+		var res *ApiRes
+		res, err = G.API.Get(ApiArg{
+			Endpoint:    "kex/receive",
+			NeedSession: false,
+			Args: HttpArgs{
+				"mailbox": S{string(mb)},
+				"seqno":   I{seqno},
+				"wait":    I{KEX_POLL_WAIT},
+			},
+		})
+		if err != nil {
+			return
+		}
+		if jw := res.Body.AtKey("msg"); !jw.IsNil() {
+			var s string
+			if s, err = jw.GetString(); err != nil {
+				return
+			}
+			return base64.StdEncoding.DecodeString(s)
+		}
+	}
+	err = KexError{"timed out waiting for the other device"}
+	return
+}
+
+//=============================================================================
+
+// KexChannel is one side's view of an authenticated, encrypted session.
+// Each message says who sent it and where it falls in the session, so
+// the relay can't replay, reorder or reflect them.
+type KexChannel struct {
+	relay     KexRelay
+	role      KexRole
+	key       [32]byte
+	sessionId string
+	nSent     int
+	nReceived int
+}
+
+func NewKexChannel(relay KexRelay, secret KexSecret, role KexRole) *KexChannel {
+	ret := &KexChannel{
+		relay:     relay,
+		role:      role,
+		sessionId: secret.SessionId(),
+	}
+	copy(ret.key[:], secret.derive("secretbox key"))
+	return ret
+}
+
+func (c *KexChannel) mailbox(r KexRole) KexMailbox {
+	return KexMailbox(c.sessionId + "." + r.String())
+}
+
+// Send seals a message of the given type, and passes it to the relay.
+func (c *KexChannel) Send(typ string, body *jsonw.Wrapper) (err error) {
+	seqno := c.nSent + 1
+	msg := jsonw.NewDictionary()
+	msg.SetKey("from", jsonw.NewString(c.role.String()))
+	msg.SetKey("seqno", jsonw.NewInt(seqno))
+	msg.SetKey("type", jsonw.NewString(typ))
+	msg.SetKey("body", body)
+
+	var plaintext []byte
+	if plaintext, err = msg.Marshal(); err != nil {
+		return
+	}
+	var nonce [KEX_NONCE_LEN]byte
+	if _, err = rand.Read(nonce[:]); err != nil {
+		return
+	}
+	sealed := secretbox.Seal(nonce[:], plaintext, &nonce, &c.key)
+	if err = c.relay.Send(c.mailbox(c.role.Peer()), seqno, sealed); err == nil {
+		c.nSent = seqno
+	}
+	return
+}
+
+// SendError tells the other side we're giving up, so it doesn't wait on
+// us until it times out.
+func (c *KexChannel) SendError(e error) {
+	body := jsonw.NewDictionary()
+	body.SetKey("msg", jsonw.NewString(e.Error()))
+	if err := c.Send("error", body); err != nil {
+		G.Log.Warning("Couldn't tell the other device about the error: %s", err.Error())
+	}
+}
+
+// Receive waits for the next message from the other side, which must be
+// of the given type, and returns its body.
+func (c *KexChannel) Receive(typ string) (body *jsonw.Wrapper, err error) {
+	seqno := c.nReceived + 1
+	var sealed []byte
+	if sealed, err = c.relay.Receive(c.mailbox(c.role), seqno); err != nil {
+		return
+	}
+	if len(sealed) < KEX_NONCE_LEN {
+		err = KexError{"message too short"}
+		return
+	}
+	var nonce [KEX_NONCE_LEN]byte
+	copy(nonce[:], sealed)
+	plaintext, ok := secretbox.Open(nil, sealed[KEX_NONCE_LEN:], &nonce, &c.key)
+	if !ok {
+		err = KexError{"couldn't authenticate message; was the secret phrase right?"}
+		return
+	}
+
+	var msg *jsonw.Wrapper
+	if msg, err = jsonw.Unmarshal(plaintext); err != nil {
+		return
+	}
+	var from, t string
+	var n int
+	msg.AtKey("from").GetStringVoid(&from, &err)
+	msg.AtKey("seqno").GetIntVoid(&n, &err)
+	msg.AtKey("type").GetStringVoid(&t, &err)
+	if err != nil {
+		return
+	}
+	if from != c.role.Peer().String() {
+		err = KexError{fmt.Sprintf("message from %q, expected %q", from, c.role.Peer())}
+	} else if n != seqno {
+		err = KexError{fmt.Sprintf("message %d, expected %d", n, seqno)}
+	}
+	if err != nil {
+		return
+	}
+	c.nReceived = seqno
+
+	body = msg.AtKey("body")
+	if t == "error" {
+		var s string
+		s, _ = body.AtKey("msg").GetString()
+		err = KexPeerError{s}
+	} else if t != typ {
+		err = KexError{fmt.Sprintf("got a %q message, expected %q", t, typ)}
+	}
+	return
+}
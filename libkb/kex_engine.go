@@ -0,0 +1,428 @@
+package libkb
+
+import (
+	"fmt"
+	"github.com/keybase/go-jsonw"
+)
+
+// The handshake, once the user has typed the new device's phrase into
+// the existing one:
+//
+//   new -> existing:  hello        {username, device, sibkey, subkey}
+//   existing:         signs and posts a sibkey delegation for the new
+//                     device's signing key, naming the device
+//   existing -> new:  sign_subkey  {uid, payload}
+//   new:              saves its keys to the local keyring
+//   new -> existing:  subkey_sig   {sig}
+//   existing:         posts the subkey delegation, signed by the new
+//                     sibkey, for the new device's DH key
+//   existing -> new:  done         {}
+//
+// The existing device builds both links, since it has the user's chain
+// state and a session; the new device only has to check and sign the
+// one that its own key signs.  By the time sign_subkey comes, the new
+// sibkey is live in the chain, so the new device saves its keys before
+// anything else can go wrong, and only writes its config once it's done.
+
+//=============================================================================
+
+type DeviceProvisionArg struct {
+	Username   string
+	DeviceName string // the hostname by default
+	Relay      KexRelay
+
+	// PaperKey, if given, signs in this device's keys directly, with no
+	// other device and no handshake. We have to log in to post them.
+	PaperKey *PaperKey
+	LoginUI  LoginUI
+	SecretUI SecretUI
+
+	LogUI LogUI
+}
+
+// DeviceProvisionEngine runs the new device's side of the handshake.
+type DeviceProvisionEngine struct {
+	arg    *DeviceProvisionArg
+	device *Device
+	sibkey NaclKeyPair
+	subkey NaclKeyPair
+	ch     *KexChannel
+	uid    *UID
+}
+
+func NewDeviceProvisionEngine(arg *DeviceProvisionArg) *DeviceProvisionEngine {
+	return &DeviceProvisionEngine{arg: arg}
+}
+
+func (e *DeviceProvisionEngine) Run() (err error) {
+	G.Log.Debug("+ DeviceProvisionEngine.Run")
+	defer func() {
+		if err != nil && e.ch != nil {
+			if _, ok := err.(KexPeerError); !ok {
+				e.ch.SendError(err)
+			}
+		}
+		G.Log.Debug("- DeviceProvisionEngine.Run -> %s", ErrToOk(err))
+	}()
+
+	if e.arg.LogUI == nil {
+		e.arg.LogUI = G.UI.GetLogUI()
+	}
+	if e.arg.Relay == nil {
+		e.arg.Relay = KexApiRelay{}
+	}
+	if len(e.arg.Username) == 0 {
+		return fmt.Errorf("No username given")
+	}
+	if e.arg.PaperKey != nil {
+		return e.runWithPaperKey()
+	}
+
+	if err = e.generate(); err != nil {
+		return
+	}
+
+	var secret KexSecret
+	if secret, err = NewKexSecret(); err != nil {
+		return
+	}
+	e.ch = NewKexChannel(e.arg.Relay, secret, KEX_ROLE_NEW)
+	e.arg.LogUI.Info("On a device that already has your keys, run 'keybase device add' and enter:")
+	e.arg.LogUI.Info("")
+	e.arg.LogUI.Info("    %s", secret.Phrase())
+	e.arg.LogUI.Info("")
+
+	hello := jsonw.NewDictionary()
+	hello.SetKey("username", jsonw.NewString(e.arg.Username))
+	hello.SetKey("device", e.device.Export())
+	hello.SetKey("sibkey", jsonw.NewString(e.sibkey.GetKid().String()))
+	hello.SetKey("subkey", jsonw.NewString(e.subkey.GetKid().String()))
+	if err = e.ch.Send("hello", hello); err != nil {
+		return
+	}
+
+	var body *jsonw.Wrapper
+	if body, err = e.ch.Receive("sign_subkey"); err != nil {
+		return
+	}
+	e.arg.LogUI.Info("Your other device signed in this device's key")
+	defer func() {
+		if err != nil {
+			kid := e.sibkey.GetKid()
+			e.arg.LogUI.Warning("This device's key %s is live, but its setup didn't finish", kid)
+			e.arg.LogUI.Warning("Revoke it with 'keybase revoke key %s' before trying again", kid)
+		}
+	}()
+	if err = e.signSubkey(body); err != nil {
+		return
+	}
+	if _, err = e.ch.Receive("done"); err != nil {
+		return
+	}
+
+	if err = e.saveConfig(); err != nil {
+		return
+	}
+	e.arg.LogUI.Info("Provisioned this device (%q) for %s", e.device.Name, e.arg.Username)
+	return
+}
+
+func (e *DeviceProvisionEngine) runWithPaperKey() (err error) {
+	err = G.LoginState.Login(LoginArg{
+		Username: e.arg.Username,
+		Ui:       e.arg.LoginUI,
+		SecretUI: e.arg.SecretUI,
+	})
+	if err != nil {
+		return
+	}
+	var me *User
+	if me, err = LoadMe(LoadUserArg{}); err != nil {
+		return
+	}
+	if err = e.arg.PaperKey.ProvisionDevice(me, e.arg.DeviceName, e.arg.LogUI); err != nil {
+		return
+	}
+	e.arg.LogUI.Info("Provisioned this device for %s with your paper key", me.GetName())
+	return
+}
+
+func (e *DeviceProvisionEngine) generate() (err error) {
+	if e.device, err = NewLocalDevice(); err != nil {
+		return
+	}
+	if len(e.arg.DeviceName) > 0 {
+		e.device.Name = e.arg.DeviceName
+	}
+	if e.sibkey, err = GenerateNaclSigningKeyPair(); err != nil {
+		return
+	}
+	e.subkey, err = GenerateNaclDHKeyPair()
+	return
+}
+
+// signSubkey checks that the link the existing device wants us to sign
+// is the delegation of our DH key that we expect, and signs it.  Our keys
+// are saved before the signature goes back, since that lets the existing
+// device finish adding us.
+func (e *DeviceProvisionEngine) signSubkey(body *jsonw.Wrapper) (err error) {
+	var payload, uid, username string
+	var subkey, signer KID
+	var link *jsonw.Wrapper
+
+	body.AtKey("payload").GetStringVoid(&payload, &err)
+	body.AtKey("uid").GetStringVoid(&uid, &err)
+	if err != nil {
+		return
+	}
+	if e.uid, err = UidFromHex(uid); err != nil {
+		return
+	}
+	if link, err = jsonw.Unmarshal([]byte(payload)); err != nil {
+		return
+	}
+	link.AtPath("body.key.username").GetStringVoid(&username, &err)
+	if err != nil {
+		return
+	}
+	if subkey, err = GetKID(link.AtPath("body.subkey.kid")); err != nil {
+		return
+	}
+	if signer, err = GetKID(link.AtPath("body.key.kid")); err != nil {
+		return
+	}
+	if t, _ := link.AtPath("body.type").GetString(); t != "subkey" {
+		err = KexError{fmt.Sprintf("asked to sign a %q link, not a subkey", t)}
+	} else if !subkey.Eq(e.subkey.GetKid()) || !signer.Eq(e.sibkey.GetKid()) {
+		err = KexError{"asked to sign a delegation for the wrong keys"}
+	} else if u, _ := link.AtPath("body.key.uid").GetString(); u != uid || username != e.arg.Username {
+		err = KexError{fmt.Sprintf("asked to sign a link for %s, not %s", username, e.arg.Username)}
+	}
+	if err != nil {
+		return
+	}
+
+	var sig string
+	if sig, _, err = e.sibkey.SignToString([]byte(payload)); err != nil {
+		return
+	}
+	if err = e.saveKeys(); err != nil {
+		return
+	}
+	reply := jsonw.NewDictionary()
+	reply.SetKey("sig", jsonw.NewString(sig))
+	return e.ch.Send("subkey_sig", reply)
+}
+
+// saveKeys writes our new keys to the local keyring.
+func (e *DeviceProvisionEngine) saveKeys() (err error) {
+	if _, err = WriteP3SKBToKeyring(e.sibkey, nil, e.arg.LogUI); err != nil {
+		return
+	}
+	_, err = WriteP3SKBToKeyring(e.subkey, nil, e.arg.LogUI)
+	return
+}
+
+// saveConfig writes which user and device we are to the config file.
+func (e *DeviceProvisionEngine) saveConfig() (err error) {
+	cw := G.Env.GetConfigWriter()
+	if cw == nil {
+		return fmt.Errorf("No configuration writer available")
+	}
+	cw.SetUsername(e.arg.Username)
+	cw.SetUid(*e.uid)
+	return WriteDeviceConfig(e.device, e.sibkey.GetKid())
+}
+
+//=============================================================================
+
+type DeviceAddArg struct {
+	Phrase   string    // as shown on the new device
+	PaperKey *PaperKey // signs for us, if given, rather than a key we hold
+	Relay    KexRelay
+	SecretUI SecretUI
+	LogUI    LogUI
+}
+
+// DeviceAddEngine runs the existing device's side of the handshake.
+type DeviceAddEngine struct {
+	arg     *DeviceAddArg
+	me      *User
+	key     GenericKey // our signing key
+	primary GenericKey
+	ch      *KexChannel
+	device  *Device
+	sibkey  NaclSigningKeyPair // the new device's, public half only
+	subkey  NaclDHKeyPair
+}
+
+func NewDeviceAddEngine(arg *DeviceAddArg) *DeviceAddEngine {
+	return &DeviceAddEngine{arg: arg}
+}
+
+func (e *DeviceAddEngine) Run() (err error) {
+	G.Log.Debug("+ DeviceAddEngine.Run")
+	defer func() {
+		if err != nil && e.ch != nil {
+			if _, ok := err.(KexPeerError); !ok {
+				e.ch.SendError(err)
+			}
+		}
+		G.Log.Debug("- DeviceAddEngine.Run -> %s", ErrToOk(err))
+	}()
+
+	if e.arg.LogUI == nil {
+		e.arg.LogUI = G.UI.GetLogUI()
+	}
+	if e.arg.SecretUI == nil {
+		e.arg.SecretUI = G.UI.GetSecretUI()
+	}
+	if e.arg.Relay == nil {
+		e.arg.Relay = KexApiRelay{}
+	}
+
+	var secret KexSecret
+	if secret, err = ParseKexSecret(e.arg.Phrase); err != nil {
+		return
+	}
+	if err = e.loadMe(); err != nil {
+		return
+	}
+
+	e.ch = NewKexChannel(e.arg.Relay, secret, KEX_ROLE_EXISTING)
+	var body *jsonw.Wrapper
+	if body, err = e.ch.Receive("hello"); err != nil {
+		return
+	}
+	if err = e.readHello(body); err != nil {
+		return
+	}
+	e.arg.LogUI.Info("Adding device %q (%s), with key %s", e.device.Name, e.device.Type,
+		e.sibkey.GetKid())
+
+	if err = e.delegateSibkey(); err != nil {
+		return
+	}
+	if err = e.delegateSubkey(); err != nil {
+		return
+	}
+	if err = e.ch.Send("done", jsonw.NewDictionary()); err != nil {
+		return
+	}
+	e.arg.LogUI.Info("Added device %q", e.device.Name)
+	return
+}
+
+func (e *DeviceAddEngine) loadMe() (err error) {
+	if err = G.Session.Load(); err != nil {
+		return
+	}
+	if e.me, err = LoadMe(LoadUserArg{}); err != nil {
+		return
+	}
+	if e.primary, err = e.me.GetEldestKey(); err != nil {
+		return
+	}
+	if e.arg.PaperKey != nil {
+		e.key = e.arg.PaperKey.Key
+		return e.arg.PaperKey.CheckActive(e.me)
+	}
+	if e.key, err = G.Keyrings.GetSecretKey("new device delegation", e.arg.SecretUI); err != nil {
+		return
+	} else if e.key == nil {
+		return NoSecretKeyError{}
+	}
+	return
+}
+
+func (e *DeviceAddEngine) readHello(body *jsonw.Wrapper) (err error) {
+	var username, sibkey, subkey string
+	body.AtKey("username").GetStringVoid(&username, &err)
+	body.AtKey("sibkey").GetStringVoid(&sibkey, &err)
+	body.AtKey("subkey").GetStringVoid(&subkey, &err)
+	if err != nil {
+		return
+	}
+	if username != e.me.GetName() {
+		return KexError{fmt.Sprintf("the new device is for %s, but you're %s", username, e.me.GetName())}
+	}
+	if e.device, err = ParseDevice(body.AtKey("device")); err != nil {
+		return
+	}
+	if e.sibkey, err = ImportNaclSigningKeyPairFromHex(sibkey); err != nil {
+		return
+	}
+	e.subkey, err = ImportNaclDHKeyPairFromHex(subkey)
+	return
+}
+
+func (e *DeviceAddEngine) delegateSibkey() (err error) {
+	var jw *jsonw.Wrapper
+	if jw, err = e.me.KeyProof(e.sibkey, e.key, "sibkey", NACL_EDDSA_EXPIRE_IN, e.device); err != nil {
+		return
+	}
+	var sig string
+	var id *SigId
+	var lid LinkId
+	if sig, id, lid, err = SignJson(jw, e.key); err != nil {
+		return
+	}
+	err = PostNewKey(PostNewKeyArg{
+		Sig:        sig,
+		Id:         *id,
+		Type:       "sibkey",
+		PublicKey:  e.sibkey,
+		SigningKey: e.key,
+		PrimaryKey: e.primary,
+	})
+	if err != nil {
+		return
+	}
+	e.me.sigChain.Bump(MerkleTriple{linkId: lid, sigId: id})
+	return
+}
+
+// delegateSubkey has the new device sign the delegation of its DH key,
+// since the new sibkey, not ours, should own it.
+func (e *DeviceAddEngine) delegateSubkey() (err error) {
+	var jw *jsonw.Wrapper
+	if jw, err = e.me.KeyProof(e.subkey, e.sibkey, "subkey", NACL_DH_EXPIRE_IN, nil); err != nil {
+		return
+	}
+	var payload []byte
+	if payload, err = jw.Marshal(); err != nil {
+		return
+	}
+	req := jsonw.NewDictionary()
+	req.SetKey("uid", jsonw.NewString(e.me.GetUid().String()))
+	req.SetKey("payload", jsonw.NewString(string(payload)))
+	if err = e.ch.Send("sign_subkey", req); err != nil {
+		return
+	}
+
+	var body *jsonw.Wrapper
+	if body, err = e.ch.Receive("subkey_sig"); err != nil {
+		return
+	}
+	var sig string
+	if sig, err = body.AtKey("sig").GetString(); err != nil {
+		return
+	}
+	var id *SigId
+	if id, err = e.sibkey.Verify(sig, payload); err != nil {
+		return
+	}
+	err = PostNewKey(PostNewKeyArg{
+		Sig:        sig,
+		Id:         *id,
+		Type:       "subkey",
+		PublicKey:  e.subkey,
+		SigningKey: e.sibkey,
+		PrimaryKey: e.primary,
+	})
+	if err != nil {
+		return
+	}
+	e.me.sigChain.Bump(MerkleTriple{linkId: ComputeLinkId(payload), sigId: id})
+	return
+}
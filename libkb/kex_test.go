@@ -0,0 +1,310 @@
+package libkb
+
+import (
+	"fmt"
+	"github.com/keybase/go-jsonw"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sync"
+	"testing"
+	"time"
+)
+
+// kexLocalRelay stands in for the server's relay, in memory.
+type kexLocalRelay struct {
+	sync.Mutex
+	cond  *sync.Cond
+	boxes map[string][]byte
+}
+
+func newKexLocalRelay() *kexLocalRelay {
+	r := &kexLocalRelay{boxes: make(map[string][]byte)}
+	r.cond = sync.NewCond(r)
+	return r
+}
+
+func (r *kexLocalRelay) key(mb KexMailbox, seqno int) string {
+	return fmt.Sprintf("%s/%d", mb, seqno)
+}
+
+func (r *kexLocalRelay) Send(mb KexMailbox, seqno int, msg []byte) error {
+	r.Lock()
+	defer r.Unlock()
+	r.boxes[r.key(mb, seqno)] = msg
+	r.cond.Broadcast()
+	return nil
+}
+
+func (r *kexLocalRelay) Receive(mb KexMailbox, seqno int) ([]byte, error) {
+	timeout := time.AfterFunc(time.Second, func() {
+		r.Lock()
+		r.cond.Broadcast()
+		r.Unlock()
+	})
+	defer timeout.Stop()
+	start := time.Now()
+
+	r.Lock()
+	defer r.Unlock()
+	for {
+		if msg, found := r.boxes[r.key(mb, seqno)]; found {
+			return msg, nil
+		} else if time.Since(start) >= time.Second {
+			return nil, KexError{"timed out"}
+		}
+		r.cond.Wait()
+	}
+}
+
+func TestKexSecretPhrase(t *testing.T) {
+	s, err := NewKexSecret()
+	if err != nil {
+		t.Fatal(err)
+	}
+	s2, err := ParseKexSecret("  " + s.Phrase() + "\n")
+	if err != nil {
+		t.Fatal(err)
+	} else if s2 != s {
+		t.Errorf("phrase didn't round trip: %q", s.Phrase())
+	}
+	if _, err = ParseKexSecret("acid acorn actor"); err == nil {
+		t.Errorf("expected a short phrase to fail")
+	}
+	if _, err = ParseKexSecret("acid acorn actor nonsense agent alarm album alert"); err == nil {
+		t.Errorf("expected an unknown word to fail")
+	}
+}
+
+func TestKexChannel(t *testing.T) {
+	relay := newKexLocalRelay()
+	s, _ := NewKexSecret()
+	y := NewKexChannel(relay, s, KEX_ROLE_NEW)
+	x := NewKexChannel(relay, s, KEX_ROLE_EXISTING)
+
+	hello := jsonw.NewDictionary()
+	hello.SetKey("username", jsonw.NewString("max"))
+	go y.Send("hello", hello)
+
+	body, err := x.Receive("hello")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if u, _ := body.AtKey("username").GetString(); u != "max" {
+		t.Errorf("wrong username: %q", u)
+	}
+
+	x.SendError(fmt.Errorf("not today"))
+	if _, err = y.Receive("sign_subkey"); err == nil {
+		t.Fatal("expected an error from the other side")
+	} else if _, ok := err.(KexPeerError); !ok {
+		t.Errorf("expected a KexPeerError, got %T: %s", err, err.Error())
+	}
+}
+
+func TestKexChannelWrongPhrase(t *testing.T) {
+	relay := newKexLocalRelay()
+	s, _ := NewKexSecret()
+	y := NewKexChannel(relay, s, KEX_ROLE_NEW)
+
+	// Same session, so the same mailboxes, but the wrong key.
+	x := NewKexChannel(relay, s, KEX_ROLE_EXISTING)
+	x.key[0] ^= 0x01
+
+	y.Send("hello", jsonw.NewDictionary())
+	if _, err := x.Receive("hello"); err == nil {
+		t.Fatal("expected the wrong key to fail")
+	} else if _, ok := err.(KexError); !ok {
+		t.Errorf("expected a KexError, got %T: %s", err, err.Error())
+	}
+}
+
+func TestKexChannelReflection(t *testing.T) {
+	relay := newKexLocalRelay()
+	s, _ := NewKexSecret()
+	y := NewKexChannel(relay, s, KEX_ROLE_NEW)
+
+	// A relay that bounces our own message back to us mustn't fool us.
+	y.Send("hello", jsonw.NewDictionary())
+	msg, _ := relay.Receive(y.mailbox(KEX_ROLE_EXISTING), 1)
+	relay.Send(y.mailbox(KEX_ROLE_NEW), 1, msg)
+	if _, err := y.Receive("hello"); err == nil {
+		t.Fatal("expected a reflected message to fail")
+	}
+}
+
+const kexTestUid = "9f9611a4b7920637b1c2a839b2a0e100"
+
+// newKexTestDevice sets up the new device's side, as after generate().
+func newKexTestDevice(t *testing.T, relay KexRelay, s KexSecret) *DeviceProvisionEngine {
+	e := NewDeviceProvisionEngine(&DeviceProvisionArg{Username: "max", Relay: relay})
+	did, err := NewDeviceId()
+	if err != nil {
+		t.Fatal(err)
+	}
+	e.device = &Device{Id: did, Name: "laptop", Type: DEVICE_TYPE_DESKTOP}
+	if e.sibkey, err = GenerateNaclSigningKeyPair(); err != nil {
+		t.Fatal(err)
+	}
+	if e.subkey, err = GenerateNaclDHKeyPair(); err != nil {
+		t.Fatal(err)
+	}
+	e.ch = NewKexChannel(relay, s, KEX_ROLE_NEW)
+	return e
+}
+
+func kexTestHello(e *DeviceProvisionEngine) *jsonw.Wrapper {
+	hello := jsonw.NewDictionary()
+	hello.SetKey("username", jsonw.NewString(e.arg.Username))
+	hello.SetKey("device", e.device.Export())
+	hello.SetKey("sibkey", jsonw.NewString(e.sibkey.GetKid().String()))
+	hello.SetKey("subkey", jsonw.NewString(e.subkey.GetKid().String()))
+	return hello
+}
+
+func TestKexReadHello(t *testing.T) {
+	s, _ := NewKexSecret()
+	y := newKexTestDevice(t, newKexLocalRelay(), s)
+	x := NewDeviceAddEngine(&DeviceAddArg{})
+	x.me = &User{name: "max"}
+
+	if err := x.readHello(kexTestHello(y)); err != nil {
+		t.Fatalf("good hello failed: %s", err)
+	}
+	if !x.sibkey.GetKid().Eq(y.sibkey.GetKid()) || !x.subkey.GetKid().Eq(y.subkey.GetKid()) {
+		t.Errorf("got the wrong keys out of the hello")
+	}
+	if x.device.Name != "laptop" || x.device.Id != y.device.Id {
+		t.Errorf("got the wrong device out of the hello: %+v", x.device)
+	}
+
+	bad := map[string]func(*jsonw.Wrapper){
+		"wrong user":      func(h *jsonw.Wrapper) { h.SetKey("username", jsonw.NewString("chris")) },
+		"no sibkey":       func(h *jsonw.Wrapper) { h.DeleteKey("sibkey") },
+		"DH sibkey":       func(h *jsonw.Wrapper) { h.SetKey("sibkey", jsonw.NewString(y.subkey.GetKid().String())) },
+		"signing subkey":  func(h *jsonw.Wrapper) { h.SetKey("subkey", jsonw.NewString(y.sibkey.GetKid().String())) },
+		"garbage subkey":  func(h *jsonw.Wrapper) { h.SetKey("subkey", jsonw.NewString("not hex")) },
+		"no device":       func(h *jsonw.Wrapper) { h.DeleteKey("device") },
+		"bad device id":   func(h *jsonw.Wrapper) { h.AtKey("device").SetKey("id", jsonw.NewString("1234")) },
+		"username number": func(h *jsonw.Wrapper) { h.SetKey("username", jsonw.NewInt(1)) },
+	}
+	for name, f := range bad {
+		h := kexTestHello(y)
+		f(h)
+		if err := x.readHello(h); err == nil {
+			t.Errorf("%s: bad hello accepted", name)
+		}
+	}
+}
+
+// kexTestSubkeyLink is the sign_subkey request that the existing device
+// would send for y's keys.
+func kexTestSubkeyLink(y *DeviceProvisionEngine) *jsonw.Wrapper {
+	key := jsonw.NewDictionary()
+	key.SetKey("kid", jsonw.NewString(y.sibkey.GetKid().String()))
+	key.SetKey("uid", jsonw.NewString(kexTestUid))
+	key.SetKey("username", jsonw.NewString("max"))
+	subkey := jsonw.NewDictionary()
+	subkey.SetKey("kid", jsonw.NewString(y.subkey.GetKid().String()))
+	body := jsonw.NewDictionary()
+	body.SetKey("type", jsonw.NewString("subkey"))
+	body.SetKey("key", key)
+	body.SetKey("subkey", subkey)
+	link := jsonw.NewDictionary()
+	link.SetKey("body", body)
+	return link
+}
+
+func kexTestSignRequest(t *testing.T, link *jsonw.Wrapper, uid string) (*jsonw.Wrapper, []byte) {
+	payload, err := link.Marshal()
+	if err != nil {
+		t.Fatal(err)
+	}
+	req := jsonw.NewDictionary()
+	req.SetKey("uid", jsonw.NewString(uid))
+	req.SetKey("payload", jsonw.NewString(string(payload)))
+	return req, payload
+}
+
+func TestKexSignSubkey(t *testing.T) {
+	dir, err := ioutil.TempDir("", "kex_test")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	ring := NewP3SKBKeyringFile(filepath.Join(dir, "secretkeys"))
+	old := G.Keyrings
+	G.Keyrings = &Keyrings{P3SKB: ring}
+	defer func() { G.Keyrings = old }()
+
+	relay := newKexLocalRelay()
+	s, _ := NewKexSecret()
+	y := newKexTestDevice(t, relay, s)
+	y.arg.LogUI = G.Log
+	x := NewKexChannel(relay, s, KEX_ROLE_EXISTING)
+
+	sibkey, subkey := y.sibkey.GetKid().String(), y.subkey.GetKid().String()
+	bad := map[string]func(*jsonw.Wrapper){
+		"sibkey link":   func(l *jsonw.Wrapper) { l.AtKey("body").SetKey("type", jsonw.NewString("sibkey")) },
+		"wrong subkey":  func(l *jsonw.Wrapper) { l.AtPath("body.subkey").SetKey("kid", jsonw.NewString(sibkey)) },
+		"wrong signer":  func(l *jsonw.Wrapper) { l.AtPath("body.key").SetKey("kid", jsonw.NewString(subkey)) },
+		"wrong user":    func(l *jsonw.Wrapper) { l.AtPath("body.key").SetKey("username", jsonw.NewString("chris")) },
+		"wrong uid":     func(l *jsonw.Wrapper) { l.AtPath("body.key").SetKey("uid", jsonw.NewString("00"+kexTestUid[2:])) },
+		"no subkey":     func(l *jsonw.Wrapper) { l.AtKey("body").DeleteKey("subkey") },
+		"no key at all": func(l *jsonw.Wrapper) { l.AtKey("body").DeleteKey("key") },
+	}
+	for name, f := range bad {
+		link := kexTestSubkeyLink(y)
+		f(link)
+		req, _ := kexTestSignRequest(t, link, kexTestUid)
+		if err := y.signSubkey(req); err == nil {
+			t.Errorf("%s: signed a bad link", name)
+		}
+	}
+	req, _ := kexTestSignRequest(t, kexTestSubkeyLink(y), "not a uid")
+	if err := y.signSubkey(req); err == nil {
+		t.Errorf("signed a link with a bad uid")
+	}
+	req = jsonw.NewDictionary()
+	req.SetKey("uid", jsonw.NewString(kexTestUid))
+	req.SetKey("payload", jsonw.NewString("{not json"))
+	if err := y.signSubkey(req); err == nil {
+		t.Errorf("signed a payload that isn't JSON")
+	}
+	if len(ring.Blocks) != 0 {
+		t.Errorf("saved our keys for a bad link")
+	}
+
+	// If we can't save our keys, we mustn't let the other device finish.
+	G.Keyrings = nil
+	req, payload := kexTestSignRequest(t, kexTestSubkeyLink(y), kexTestUid)
+	if err := y.signSubkey(req); err == nil {
+		t.Errorf("signed without saving our keys")
+	}
+	G.Keyrings = &Keyrings{P3SKB: ring}
+
+	// None of those should have sent anything, so the first reply the
+	// existing device sees is the good signature.
+	if err := y.signSubkey(req); err != nil {
+		t.Fatalf("good link failed: %s", err)
+	}
+	for _, k := range []GenericKey{y.sibkey, y.subkey} {
+		if ring.LookupByKid(k.GetKid()) == nil {
+			t.Errorf("key %s wasn't saved before we signed", k.GetKid())
+		}
+	}
+	body, err := x.Receive("subkey_sig")
+	if err != nil {
+		t.Fatal(err)
+	}
+	sig, err := body.AtKey("sig").GetString()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err = y.sibkey.Verify(sig, payload); err != nil {
+		t.Errorf("subkey_sig didn't verify: %s", err)
+	}
+	if y.uid == nil || y.uid.String() != kexTestUid {
+		t.Errorf("didn't keep the uid")
+	}
+}
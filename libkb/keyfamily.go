@@ -398,21 +398,100 @@ func (ckf *ComputedKeyFamily) RevokeKids(kids []KID, tcl TypedChainLink) (err er
 }
 
 func (ckf *ComputedKeyFamily) RevokeSig(sig SigId, tcl TypedChainLink) (err error) {
+	return ckf.revokeSig(sig, TclToKeybaseTime(tcl))
+}
+
+func (ckf *ComputedKeyFamily) RevokeKid(kid KID, tcl TypedChainLink) (err error) {
+	return ckf.revokeKid(kid, TclToKeybaseTime(tcl))
+}
+
+func (ckf *ComputedKeyFamily) revokeSig(sig SigId, tm *KeybaseTime) (err error) {
 	if info, found := ckf.cki.Sigs[sig.ToString(true)]; !found {
 	} else if _, found = info.Delegations[sig.ToString(true)]; !found {
 		err = BadRevocationError{fmt.Sprintf("Can't find sigId %s in delegation list",
 			sig.ToString(true))}
 	} else {
 		info.Status = KEY_REVOKED
-		info.RevokedAt = TclToKeybaseTime(tcl)
+		info.RevokedAt = tm
 	}
 	return
 }
 
-func (ckf *ComputedKeyFamily) RevokeKid(kid KID, tcl TypedChainLink) (err error) {
+func (ckf *ComputedKeyFamily) revokeKid(kid KID, tm *KeybaseTime) (err error) {
 	if info, found := ckf.cki.Infos[kid.String()]; found {
 		info.Status = KEY_REVOKED
-		info.RevokedAt = TclToKeybaseTime(tcl)
+		info.RevokedAt = tm
+	}
+	return
+}
+
+// LocalRevoke applies a revocation we just posted to our own
+// ComputedKeyInfos, so that the revoked keys stop being used right away,
+// without waiting to reload our sigchain from the server.
+func (ckf *ComputedKeyFamily) LocalRevoke(kids []KID, sigs []SigId) (err error) {
+	tm := NowAsKeybaseTime(0)
+	for _, s := range sigs {
+		if err = ckf.revokeSig(s, tm); err != nil {
+			return
+		}
+	}
+	for _, k := range kids {
+		if err = ckf.revokeKid(k, tm); err != nil {
+			return
+		}
+	}
+	ckf.cki.dirty = true
+	return
+}
+
+// KeyStateQuery picks a point in a sigchain's history, either by the
+// seqno of the last link to consider, or by wallclock time. If both are
+// set, a link has to be within both to count.
+type KeyStateQuery struct {
+	Seqno Seqno
+	Time  time.Time
+}
+
+func (q KeyStateQuery) includes(link *ChainLink) bool {
+	if q.Seqno > 0 && link.GetSeqno() > q.Seqno {
+		return false
+	}
+	if !q.Time.IsZero() && link.GetCTime().After(q.Time) {
+		return false
+	}
+	return true
+}
+
+func (q KeyStateQuery) String() string {
+	if q.Seqno > 0 && !q.Time.IsZero() {
+		return fmt.Sprintf("seqno %d, %s", int(q.Seqno), FormatTime(q.Time))
+	} else if q.Seqno > 0 {
+		return fmt.Sprintf("seqno %d", int(q.Seqno))
+	} else {
+		return FormatTime(q.Time)
+	}
+}
+
+// ReplayTo plays the given links forward, as far as q, and returns the
+// key family as it stood then.  The links should be the verified subchain
+// that yielded ckf, as from SigChain.LimitToKeyFamily, so we don't recheck
+// the signatures.  Use it to tell if a key was good when it signed
+// something, even if it's since been revoked.
+func (ckf ComputedKeyFamily) ReplayTo(links []*ChainLink, q KeyStateQuery) (ret *ComputedKeyFamily, err error) {
+	ret = &ComputedKeyFamily{kf: ckf.kf, cki: ckf.kf.NewComputedKeyInfos()}
+	for _, link := range links {
+		if !q.includes(link) {
+			break
+		}
+		tcl, _ := NewTypedChainLink(link)
+		if tcl.IsDelegation() != DLG_NONE {
+			if err = ret.Delegate(tcl); err != nil {
+				return
+			}
+		}
+		if err = ret.Revoke(tcl); err != nil {
+			return
+		}
 	}
 	return
 }
@@ -554,6 +633,21 @@ func (ckf ComputedKeyFamily) GetActivePgpKeys(sibkey bool) (ret []*PgpKeyBundle)
 	return
 }
 
+// GetActiveNaclDHKeys gets the active NaCl DH subkeys from the
+// ComputedKeyFamily, which are the keys that others encrypt to.
+func (ckf ComputedKeyFamily) GetActiveNaclDHKeys() (ret []NaclDHKeyPair) {
+	for _, skr := range ckf.kf.Subkeys {
+		dh, ok := skr.key.(NaclDHKeyPair)
+		if !ok {
+			continue
+		}
+		if info, ok := ckf.cki.Infos[skr.Kid]; ok && info.Status == KEY_LIVE {
+			ret = append(ret, dh)
+		}
+	}
+	return
+}
+
 // DumpToLog dumps info about the current KeyFamily to the given log UI
 func (ckf ComputedKeyFamily) DumpToLog(ui LogUI) {
 
@@ -359,7 +359,11 @@ func (s *KeyGen) GenNacl() (err error) {
 		G.Log.Debug("- GenNacl() -> %s", ErrToOk(err))
 	}()
 	if !s.arg.NoNaclEddsa {
-		s.arg.LogUI.Info("Generating NaCl EdDSA key (255 bits on Curve25519)")
+		var device *Device
+		if device, err = NewLocalDevice(); err != nil {
+			return
+		}
+		s.arg.LogUI.Info("Generating NaCl EdDSA key (255 bits on Curve25519) for device %q", device.Name)
 		gen := NewNaclKeyGen(NaclKeyGenArg{
 			Signer:    signer,
 			Primary:   s.bundle,
@@ -367,10 +371,16 @@ func (s *KeyGen) GenNacl() (err error) {
 			Type:      "sibkey",
 			Me:        s.me,
 			ExpireIn:  NACL_EDDSA_EXPIRE_IN,
+			Device:    device,
 			LogUI:     s.arg.LogUI,
 		})
-		err = gen.Run()
+		if err = gen.Run(); err != nil {
+			return
+		}
 		signer = gen.GetKeyPair()
+		if err = WriteDeviceConfig(device, signer.GetKid()); err != nil {
+			return
+		}
 	}
 
 	if err != nil || s.arg.NoNaclDh {
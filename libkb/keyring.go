@@ -246,9 +246,33 @@ func (k Keyrings) GetSecretKey(reason string, ui SecretUI) (key GenericKey, err
 	}()
 	var p3skb *P3SKB
 	var which string
-	if p3skb, which, err = k.GetSecretKeyLocked(); err == nil && p3skb != nil {
-		G.Log.Debug("| Prompt/Unlock key")
-		key, err = p3skb.PromptAndUnlock(reason, which, ui)
+	if p3skb, which, err = k.GetSecretKeyLocked(); err != nil || p3skb == nil {
+		return
+	}
+
+	cache := G.secretKeyCache()
+	if cache != nil {
+		var pub GenericKey
+		if pub, err = p3skb.GetPubKey(); err != nil {
+			return
+		}
+		if key, err = cache.GetSecretKey(pub.GetKid()); err != nil {
+			G.Log.Debug("| SecretKeyCache lookup failed: %s", err.Error())
+			err = nil
+		} else if key != nil {
+			G.Log.Debug("| Found unlocked key in SecretKeyCache")
+			return
+		}
+	}
+
+	G.Log.Debug("| Prompt/Unlock key")
+	if key, err = p3skb.PromptAndUnlock(reason, which, ui); err == nil && cache != nil {
+		// The cache keeps its own copy, which it scrubs when it times
+		// out, so don't let the P3SKB hang onto the key after this.
+		p3skb.decryptedSecret = nil
+		if e2 := cache.PutSecretKey(key); e2 != nil {
+			G.Log.Debug("| SecretKeyCache store failed: %s", e2.Error())
+		}
 	}
 	return
 }
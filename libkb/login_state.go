@@ -162,6 +162,51 @@ func (s *LoginState) PostLoginToServer(eOu string, lgpw []byte) error {
 	return nil
 }
 
+// PassphraseProof gets a fresh login session from the server, and
+// proves to it that we know the given passphrase, as we do when we log
+// in, but without logging in again.
+func (s *LoginState) PassphraseProof(username, pp string) (hmacPwh []byte, loginSession string, err error) {
+	s.login_session = nil
+	if err = s.GetSaltAndLoginSession(username); err != nil {
+		return
+	}
+	var tsec *triplesec.Cipher
+	if tsec, err = triplesec.NewCipher([]byte(pp), s.salt); err != nil {
+		return
+	}
+	defer tsec.Scrub()
+	var sharedSecret []byte
+	if _, sharedSecret, err = tsec.DeriveKey(SharedSecretLen); err != nil {
+		return
+	}
+	mac := hmac.New(sha512.New, sharedSecret)
+	mac.Write(s.login_session)
+	hmacPwh = mac.Sum(nil)
+	loginSession = s.login_session_b64
+	return
+}
+
+// SetNewPassphrase switches us over to a new passphrase, once the server
+// has taken it, with the salt from GenerateNewSalt. tsec is the cipher
+// for the new passphrase and salt.
+func (s *LoginState) SetNewPassphrase(tsec *triplesec.Cipher) (err error) {
+	if s.tsec != nil && s.tsec != tsec {
+		s.tsec.Scrub()
+	}
+	s.tsec = tsec
+	if _, s.sharedSecret, err = tsec.DeriveKey(SharedSecretLen); err != nil {
+		return
+	}
+	s.login_session = nil
+	s.login_session_b64 = ""
+
+	if cfg := G.Env.GetConfigWriter(); cfg != nil {
+		cfg.SetSalt(s.salt)
+		err = cfg.Write()
+	}
+	return
+}
+
 func (s *LoginState) SaveLoginState(prompted bool) error {
 	s.LoggedIn = true
 	s.SessionVerified = true
@@ -199,6 +244,7 @@ func (s *LoginState) Logout() error {
 			s.tsec = nil
 		}
 	}
+	G.SecretCache.Clear()
 	G.Log.Debug("- Logout called")
 	return err
 }
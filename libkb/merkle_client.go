@@ -44,8 +44,9 @@ func (h1 NodeHashLong) Check(s string) bool {
 type MerkleClient struct {
 	keyring *SpecialKeyRing
 
-	// Blocks that have been verified
-	verified map[Seqno]bool
+	// Blocks that have been verified, by seqno, and the root hash we
+	// verified for each
+	verified map[Seqno]string
 
 	// The most recently-available root
 	lastRoot *MerkleRoot
@@ -126,7 +127,7 @@ func GetNodeHashVoid(w *jsonw.Wrapper, nhp *NodeHash, errp *error) {
 func NewMerkleClient() *MerkleClient {
 	return &MerkleClient{
 		keyring:  NewSpecialKeyRing(G.Env.GetMerkleKeyFingerprints()),
-		verified: make(map[Seqno]bool),
+		verified: make(map[Seqno]string),
 		lastRoot: nil,
 	}
 }
@@ -161,17 +162,6 @@ func (mc *MerkleClient) LoadRoot() error {
 	return nil
 }
 
-func (mr *MerkleRoot) Store() error {
-	err := G.LocalDb.Put(DbKey{
-		Typ: DB_MERKLE_ROOT,
-		Key: fmt.Sprintf("%d", mr.seqno),
-	},
-		[]DbKey{merkleHeadKey()},
-		mr.ToJson(),
-	)
-	return err
-}
-
 func (mr *MerkleRoot) ToJson() (jw *jsonw.Wrapper) {
 	ret := jsonw.NewDictionary()
 	ret.SetKey("sig", jsonw.NewString(mr.sig))
@@ -233,17 +223,25 @@ func (mc *MerkleClient) LookupPath(q HttpArgs) (vp *VerificationPath, err error)
 		return
 	}
 
-	root, err := NewMerkleRootFromJson(res.Body.AtKey("root"))
+	vp, err = NewVerificationPathFromJson(res.Body)
+	return
+}
+
+// NewVerificationPathFromJson parses a path as returned by the
+// merkle/path endpoint, or as written by VerificationPath.ToJson.
+func NewVerificationPathFromJson(jw *jsonw.Wrapper) (vp *VerificationPath, err error) {
+
+	root, err := NewMerkleRootFromJson(jw.AtKey("root"))
 	if err != nil {
 		return
 	}
 
-	path, err := res.Body.AtKey("path").ToArray()
+	path, err := jw.AtKey("path").ToArray()
 	if err != nil {
 		return
 	}
 
-	uid, err := GetUid(res.Body.AtKey("uid"))
+	uid, err := GetUid(jw.AtKey("uid"))
 	if err != nil {
 		return
 	}
@@ -251,7 +249,7 @@ func (mc *MerkleClient) LookupPath(q HttpArgs) (vp *VerificationPath, err error)
 	// We don't trust this version, but it's useful to tell us if there
 	// are new versions unsigned data, like basics, and maybe uploaded
 	// keys
-	idv, err := res.Body.AtKey("id_version").GetInt64()
+	idv, err := jw.AtKey("id_version").GetInt64()
 	if err != nil {
 		return
 	}
@@ -275,6 +273,28 @@ func (mc *MerkleClient) LookupPath(q HttpArgs) (vp *VerificationPath, err error)
 	return
 }
 
+func (vp *VerificationPath) ToJson() *jsonw.Wrapper {
+	ret := jsonw.NewDictionary()
+	ret.SetKey("root", vp.root.ToJson())
+	ret.SetKey("uid", jsonw.NewString(vp.uid.String()))
+	ret.SetKey("id_version", jsonw.NewInt64(vp.idVersion))
+	path := jsonw.NewArray(len(vp.path))
+	for i, step := range vp.path {
+		path.SetIndex(i, step.ToJson())
+	}
+	ret.SetKey("path", path)
+	return ret
+}
+
+func (ps *PathStep) ToJson() *jsonw.Wrapper {
+	node := jsonw.NewDictionary()
+	node.SetKey("val", jsonw.NewString(ps.node))
+	ret := jsonw.NewDictionary()
+	ret.SetKey("prefix", jsonw.NewString(ps.prefix))
+	ret.SetKey("node", node)
+	return ret
+}
+
 func pathStepFromJson(jw *jsonw.Wrapper) (ps *PathStep, err error) {
 
 	var prefix string
@@ -304,19 +324,35 @@ func (mc *MerkleClient) LastSeqno() Seqno {
 
 func (mc *MerkleClient) VerifyRoot(root *MerkleRoot) error {
 
-	// First make sure it's not a rollback
+	if mc.lastRoot == nil {
+		if err := mc.LoadRoot(); err != nil {
+			return err
+		}
+	}
+
+	// First make sure it's not a rollback, even to a root we verified
+	// before
 	q := mc.LastSeqno()
 	if q >= 0 && q > root.seqno {
 		return fmt.Errorf("Server rolled back Merkle tree: %d > %d",
 			q, root.seqno)
 	}
 
+	// Maybe we've already verified it before. A different root with the
+	// same seqno goes on to the checks below, which catch the fork.
+	if h, found := mc.verified[root.seqno]; found && h == root.rootHash.String() {
+		return nil
+	}
+
 	G.Log.Debug("| Merkle root: got back %d, >= cached %d", int(root.seqno), int(q))
 
-	// Maybe we've already verified it before.
-	verified, found := mc.verified[root.seqno]
-	if verified && found {
-		return nil
+	// Nor a fork of a root we've already logged
+	prev, err := LoadMerkleRootLogEntry(root.seqno)
+	if err != nil {
+		return err
+	}
+	if prev != nil && prev.Root.rootHash.String() != root.rootHash.String() {
+		return MerkleForkError{root.seqno, prev.Root.rootHash.String(), root.rootHash.String()}
 	}
 
 	key, err := mc.keyring.Load(root.pgpFingerprint)
@@ -335,12 +371,16 @@ func (mc *MerkleClient) VerifyRoot(root *MerkleRoot) error {
 		return err
 	}
 
-	if e2 := root.Store(); e2 != nil {
-		G.Log.Error("Cannot commit Merkle root to local DB: %s",
-			e2.Error())
+	// A root we can't log is one we can't check later forks against.
+	if prev != nil {
+		// Already in the log
+	} else if err = root.Store(q); err != nil {
+		return fmt.Errorf("Cannot commit Merkle root to local DB: %s", err.Error())
+	} else {
+		mc.lastRoot = root
 	}
 
-	mc.verified[root.seqno] = true
+	mc.verified[root.seqno] = root.rootHash.String()
 
 	return nil
 }
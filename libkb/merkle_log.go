@@ -0,0 +1,136 @@
+package libkb
+
+import (
+	"fmt"
+	"github.com/keybase/go-jsonw"
+	"time"
+)
+
+// We keep every Merkle root we've verified in the local DB, keyed by
+// seqno, and never overwrite one. Each entry points back to the root we
+// logged before it, and the HEAD alias points at the newest, so the
+// entries form an append-only log. With it we can tell if the server
+// ever shows us a tree that forks from, or rolls back, what we saw.
+
+type MerkleRootLogEntry struct {
+	Root    *MerkleRoot
+	Prev    Seqno // the seqno of the root logged before this one, or -1
+	AddedAt time.Time
+}
+
+func merkleRootKey(seqno Seqno) DbKey {
+	return DbKey{
+		Typ: DB_MERKLE_ROOT,
+		Key: fmt.Sprintf("%d", seqno),
+	}
+}
+
+// Store appends the root to our log, after the root with seqno prev.
+func (mr *MerkleRoot) Store(prev Seqno) error {
+	jw := mr.ToJson()
+	jw.SetKey("prev", jsonw.NewInt64(int64(prev)))
+	jw.SetKey("added_at", jsonw.NewInt64(time.Now().Unix()))
+	return G.LocalDb.Put(merkleRootKey(mr.seqno), []DbKey{merkleHeadKey()}, jw)
+}
+
+func newMerkleRootLogEntry(jw *jsonw.Wrapper) (ret *MerkleRootLogEntry, err error) {
+	ret = &MerkleRootLogEntry{Prev: -1}
+	if ret.Root, err = NewMerkleRootFromJson(jw); err != nil {
+		return nil, err
+	}
+	// Roots stored before we kept a log don't have these.
+	if p, e2 := jw.AtKey("prev").GetInt64(); e2 == nil {
+		ret.Prev = Seqno(p)
+	}
+	if t, e2 := jw.AtKey("added_at").GetInt64(); e2 == nil {
+		ret.AddedAt = time.Unix(t, 0)
+	}
+	return
+}
+
+// LoadMerkleRootLogEntry loads the root we logged at the given seqno, or
+// nil if we haven't seen one.
+func LoadMerkleRootLogEntry(seqno Seqno) (ret *MerkleRootLogEntry, err error) {
+	var jw *jsonw.Wrapper
+	if jw, err = G.LocalDb.Get(merkleRootKey(seqno)); err != nil || jw == nil {
+		return
+	}
+	return newMerkleRootLogEntry(jw)
+}
+
+// Log returns our log of verified roots, newest first.
+func (mc *MerkleClient) Log() (ret []*MerkleRootLogEntry, err error) {
+	var jw *jsonw.Wrapper
+	if jw, err = G.LocalDb.Lookup(merkleHeadKey()); err != nil || jw == nil {
+		return
+	}
+	var e *MerkleRootLogEntry
+	if e, err = newMerkleRootLogEntry(jw); err != nil {
+		return
+	}
+	for e != nil {
+		ret = append(ret, e)
+		if e.Prev < 0 {
+			break
+		}
+		if e, err = LoadMerkleRootLogEntry(e.Prev); err != nil {
+			return
+		} else if e == nil {
+			err = fmt.Errorf("Merkle root log is missing root %d", int(ret[len(ret)-1].Prev))
+			return
+		}
+	}
+	return
+}
+
+// CheckLog rechecks the signature on every root in our log, and that
+// their seqnos only go up. It returns the log, newest first.
+func (mc *MerkleClient) CheckLog() (ret []*MerkleRootLogEntry, err error) {
+	if ret, err = mc.Log(); err != nil {
+		return
+	}
+	for i, e := range ret {
+		if i > 0 && ret[i-1].Root.seqno <= e.Root.seqno {
+			err = fmt.Errorf("Merkle root log out of order: %d came after %d",
+				int(ret[i-1].Root.seqno), int(e.Root.seqno))
+			return
+		}
+		var key *PgpKeyBundle
+		if key, err = mc.keyring.Load(e.Root.pgpFingerprint); err != nil {
+			return
+		} else if key == nil {
+			err = fmt.Errorf("Failed to find a Merkle signing key for %s",
+				e.Root.pgpFingerprint.String())
+			return
+		}
+		if _, err = key.Verify(e.Root.sig, []byte(e.Root.payloadJsonString)); err != nil {
+			err = fmt.Errorf("Bad signature on logged Merkle root %d: %s",
+				int(e.Root.seqno), err.Error())
+			return
+		}
+	}
+	return
+}
+
+// FetchRoot gets the server's current Merkle root, and checks it
+// against our log, which it joins if it's new.
+func (mc *MerkleClient) FetchRoot() (root *MerkleRoot, err error) {
+	var res *ApiRes
+	res, err = G.API.Get(ApiArg{
+		Endpoint:    "merkle/root",
+		NeedSession: false,
+	})
+	if err != nil {
+		return
+	}
+	if root, err = NewMerkleRootFromJson(res.Body); err != nil {
+		return
+	}
+	err = mc.VerifyRoot(root)
+	return
+}
+
+func (mr *MerkleRoot) GetSeqno() Seqno                { return mr.seqno }
+func (mr *MerkleRoot) GetRootHash() string            { return mr.rootHash.String() }
+func (mr *MerkleRoot) GetCTime() time.Time            { return time.Unix(mr.ctime, 0) }
+func (mr *MerkleRoot) GetFingerprint() PgpFingerprint { return mr.pgpFingerprint }
@@ -0,0 +1,79 @@
+package libkb
+
+import (
+	"github.com/keybase/go-jsonw"
+	"strings"
+	"testing"
+)
+
+func TestMerkleRootLogEntry(t *testing.T) {
+	G.Init()
+	root := fakeVerificationPath(strings.Repeat("1", 30)+"00", strings.Repeat("c", 64)).AtKey("root")
+
+	// Roots stored before the log was kept have no back pointer.
+	e, err := newMerkleRootLogEntry(root)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if e.Prev != -1 || !e.AddedAt.IsZero() || e.Root.GetSeqno() != 42 {
+		t.Errorf("bad legacy entry: %+v", e)
+	}
+
+	root.SetKey("prev", jsonw.NewInt(41))
+	root.SetKey("added_at", jsonw.NewInt(1420000001))
+	if e, err = newMerkleRootLogEntry(root); err != nil {
+		t.Fatal(err)
+	}
+	if e.Prev != 41 || e.AddedAt.Unix() != 1420000001 {
+		t.Errorf("bad entry: %+v", e)
+	}
+}
+
+func fakeMerkleRoot(t *testing.T, linkId string) *MerkleRoot {
+	root, err := NewMerkleRootFromJson(fakeVerificationPath(strings.Repeat("1", 30)+"00", linkId).AtKey("root"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	return root
+}
+
+func TestMerkleVerifyRootRollback(t *testing.T) {
+	root := fakeMerkleRoot(t, strings.Repeat("c", 64))
+	mc := &MerkleClient{verified: make(map[Seqno]string)}
+
+	// We verified root 42 once, but have since seen 50.
+	mc.verified[root.seqno] = root.GetRootHash()
+	mc.lastRoot = &MerkleRoot{seqno: root.seqno + 8}
+	if err := mc.VerifyRoot(root); err == nil {
+		t.Errorf("server rolled back to a root we'd verified before, and we took it")
+	}
+
+	mc.lastRoot = root
+	if err := mc.VerifyRoot(root); err != nil {
+		t.Errorf("rejected the root we're at: %s", err)
+	}
+}
+
+func TestMerkleVerifyRootFork(t *testing.T) {
+	defer useMemLocalDb()()
+	root := fakeMerkleRoot(t, strings.Repeat("c", 64))
+	fork := fakeMerkleRoot(t, strings.Repeat("d", 64))
+	if root.seqno != fork.seqno || root.GetRootHash() == fork.GetRootHash() {
+		t.Fatalf("bad test roots")
+	}
+	if err := root.Store(-1); err != nil {
+		t.Fatal(err)
+	}
+
+	// A fresh client, which has only the log to go on.
+	mc := &MerkleClient{verified: make(map[Seqno]string), lastRoot: root}
+	err := mc.VerifyRoot(fork)
+	if _, ok := err.(MerkleForkError); !ok {
+		t.Errorf("expected a MerkleForkError; got %v", err)
+	}
+	if e, err := LoadMerkleRootLogEntry(root.seqno); err != nil || e == nil {
+		t.Fatalf("lost the logged root: %v", err)
+	} else if e.Root.GetRootHash() != root.GetRootHash() {
+		t.Errorf("fork overwrote the logged root")
+	}
+}
@@ -0,0 +1,152 @@
+package libkb
+
+import (
+	"fmt"
+	"github.com/keybase/go-jsonw"
+)
+
+// A Merkle proof is a user's path through the Merkle tree, from the
+// signed root down to their leaf, along with the PGP key that signed the
+// root. Anyone who trusts that key's fingerprint can check it later, with
+// no help from the server.
+
+// MerkleProofSummary describes a proof that passed VerifyMerkleProof.
+type MerkleProofSummary struct {
+	Uid            UID
+	RootSeqno      Seqno
+	RootCTime      int64
+	RootHash       string
+	RootSigner     PgpFingerprint
+	TailSeqno      Seqno // -1 if the user has no public chain
+	TailLinkId     LinkId
+	UnverifiedName string // as given to ExportMerkleProof; not part of the proof
+}
+
+// ExportMerkleProof looks up the given user's path in the Merkle tree,
+// checks it, and packs it up with the root and its signing key.
+func ExportMerkleProof(name string) (ret *jsonw.Wrapper, err error) {
+	G.Log.Debug("+ ExportMerkleProof(%s)", name)
+	defer func() {
+		G.Log.Debug("- ExportMerkleProof(%s) -> %s", name, ErrToOk(err))
+	}()
+
+	rres := ResolveUid(name)
+	if err = rres.err; err != nil {
+		return
+	} else if rres.uid == nil {
+		err = fmt.Errorf("No resolution for name=%s", name)
+		return
+	}
+	if ret, _, err = exportMerkleProof(*rres.uid); err != nil {
+		return
+	}
+	ret.SetKey("username", jsonw.NewString(name))
+	return
+}
+
+func exportMerkleProof(uid UID) (ret *jsonw.Wrapper, leaf *MerkleUserLeaf, err error) {
+	var vp *VerificationPath
+	q := NewHttpArgs()
+	q.Add("uid", S{uid.String()})
+	if vp, err = G.MerkleClient.LookupPath(q); err != nil {
+		return
+	}
+	if err = G.MerkleClient.VerifyRoot(vp.root); err != nil {
+		return
+	}
+	if leaf, err = vp.Verify(); err != nil {
+		return
+	} else if leaf == nil {
+		err = UserNotFoundError{vp.uid, "not in the Merkle tree"}
+		return
+	}
+
+	var key *PgpKeyBundle
+	if key, err = G.MerkleClient.keyring.Load(vp.root.pgpFingerprint); err != nil {
+		return
+	}
+	var armored string
+	if armored, err = key.Encode(); err != nil {
+		return
+	}
+
+	ret = vp.ToJson()
+	ret.SetKey("key", jsonw.NewString(armored))
+	return
+}
+
+// VerifyMerkleProof checks a proof written by ExportMerkleProof, trusting
+// only the Merkle key fingerprints in our config.  It never touches the
+// network, or our log of Merkle roots.
+func VerifyMerkleProof(jw *jsonw.Wrapper) (ret *MerkleProofSummary, err error) {
+	G.Log.Debug("+ VerifyMerkleProof")
+	defer func() {
+		G.Log.Debug("- VerifyMerkleProof -> %s", ErrToOk(err))
+	}()
+
+	var vp *VerificationPath
+	var leaf *MerkleUserLeaf
+	if vp, leaf, err = verifyMerkleProof(jw); err != nil {
+		return
+	}
+	ret = &MerkleProofSummary{
+		Uid:        vp.uid,
+		RootSeqno:  vp.root.seqno,
+		RootCTime:  vp.root.ctime,
+		RootHash:   vp.root.rootHash.String(),
+		RootSigner: vp.root.pgpFingerprint,
+		TailSeqno:  -1,
+	}
+	if leaf.public != nil {
+		ret.TailSeqno = leaf.public.seqno
+		ret.TailLinkId = leaf.public.linkId
+	}
+	ret.UnverifiedName, _ = jw.AtKey("username").GetString()
+	return
+}
+
+func verifyMerkleProof(jw *jsonw.Wrapper) (vp *VerificationPath, leaf *MerkleUserLeaf, err error) {
+	if vp, err = NewVerificationPathFromJson(jw); err != nil {
+		return
+	}
+	var armored string
+	if armored, err = jw.AtKey("key").GetString(); err != nil {
+		return
+	}
+	if err = verifyRootOffline(vp.root, armored); err != nil {
+		return
+	}
+	if leaf, err = vp.Verify(); err != nil {
+		return
+	} else if leaf == nil {
+		err = UserNotFoundError{vp.uid, "not in the Merkle tree"}
+	}
+	return
+}
+
+// verifyRootOffline checks the Merkle root's signature against the given
+// key, which in turn must be one of the Merkle keys we're configured to
+// trust.
+func verifyRootOffline(root *MerkleRoot, armored string) (err error) {
+	trusted := false
+	for _, fp := range G.Env.GetMerkleKeyFingerprints() {
+		if fp.Eq(root.pgpFingerprint) {
+			trusted = true
+			break
+		}
+	}
+	if !trusted {
+		return MerkleProofError{fmt.Sprintf("root signed by untrusted key %s",
+			root.pgpFingerprint.String())}
+	}
+
+	var key *PgpKeyBundle
+	if key, err = ReadOneKeyFromString(armored); err != nil {
+		return
+	}
+	if !key.GetFingerprint().Eq(root.pgpFingerprint) {
+		return MerkleProofError{"key doesn't match the root's fingerprint"}
+	}
+	_, err = key.Verify(root.sig, []byte(root.payloadJsonString))
+	return
+}
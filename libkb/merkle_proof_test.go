@@ -0,0 +1,20 @@
+package libkb
+
+import (
+	"github.com/keybase/go-jsonw"
+	"strings"
+	"testing"
+)
+
+func TestVerifyMerkleProofUntrustedKey(t *testing.T) {
+	G.Init()
+	jw := fakeVerificationPath(strings.Repeat("1", 30)+"00", strings.Repeat("c", 64))
+	jw.SetKey("key", jsonw.NewString("not checked, since the fingerprint isn't trusted"))
+
+	_, err := VerifyMerkleProof(jw)
+	if err == nil {
+		t.Fatal("expected a root signed by an unknown key to fail")
+	} else if _, ok := err.(MerkleProofError); !ok {
+		t.Errorf("expected a MerkleProofError; got %T: %s", err, err)
+	}
+}
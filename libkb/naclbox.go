@@ -0,0 +1,201 @@
+package libkb
+
+//
+// A Keybase-native encrypted message format, for encrypting to one or more
+// NaCl Curve25519 DH keys without PGP. The payload is encrypted once with
+// a random secretbox key, and that key is boxed separately for each
+// recipient, along with a hash of the ciphertext.
+//
+
+import (
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha512"
+	"golang.org/x/crypto/nacl/box"
+	"golang.org/x/crypto/nacl/secretbox"
+)
+
+const (
+	NACL_NONCE_SIZE     = 24
+	NACL_SECRETBOX_SIZE = 32
+)
+
+// NaclBoxRecipient holds the message key, boxed from the sender's DH key
+// to one recipient's DH key.  For a NaclEncryption, the box also holds
+// a hash of the ciphertext, so it can't be reused for another message.
+type NaclBoxRecipient struct {
+	Kid   KID                   `codec:"kid"`
+	Nonce [NACL_NONCE_SIZE]byte `codec:"nonce"`
+	Box   []byte                `codec:"box"`
+}
+
+type NaclEncryption struct {
+	EncType    int                   `codec:"enc_type"`
+	Sender     KID                   `codec:"sender"`
+	Ephemeral  bool                  `codec:"ephemeral"`
+	Recipients []NaclBoxRecipient    `codec:"recipients"`
+	Nonce      [NACL_NONCE_SIZE]byte `codec:"nonce"`
+	Ciphertext []byte                `codec:"ciphertext"`
+}
+
+func (k KID) ToNaclDHKeyPublic() *NaclDHKeyPublic {
+	body, err := importNaclKid(k, byte(KID_NACL_DH), NACL_DH_KEYSIZE)
+	if err != nil {
+		return nil
+	}
+	var ret NaclDHKeyPublic
+	copy(ret[:], body)
+	return &ret
+}
+
+func (k NaclDHKeyPublic) ToNaclLibrary() *[NACL_DH_KEYSIZE]byte {
+	b := [NACL_DH_KEYSIZE]byte(k)
+	return &b
+}
+
+func (k NaclDHKeyPrivate) ToNaclLibrary() *[NACL_DH_KEYSIZE]byte {
+	b := [NACL_DH_KEYSIZE]byte(k)
+	return &b
+}
+
+func randomNonce() (ret [NACL_NONCE_SIZE]byte, err error) {
+	_, err = rand.Read(ret[:])
+	return
+}
+
+// NaclEncrypt encrypts msg for each of the given recipient keys. If sender
+// is nil, the message keys are boxed from a fresh ephemeral key, so
+// recipients learn nothing about who sent it. Otherwise the sender's
+// secret DH key is used, and recipients can tell that the message came
+// from its holder.  Every recipient learns the message key, so each box
+// also pins down the ciphertext; otherwise one recipient could encrypt
+// something else under the same key, and pass it off to the others, with
+// the sender's boxes, as the sender's.
+func NaclEncrypt(msg []byte, recipients []NaclDHKeyPublic, sender *NaclDHKeyPair) (ret *NaclEncryption, err error) {
+	if len(recipients) == 0 {
+		err = NoKeyError{"No recipients to encrypt for"}
+		return
+	}
+
+	ret = &NaclEncryption{EncType: ENC_KB_NACL_BOX}
+
+	if sender == nil {
+		var pub, priv *[NACL_DH_KEYSIZE]byte
+		if pub, priv, err = box.GenerateKey(rand.Reader); err != nil {
+			return
+		}
+		sender = &NaclDHKeyPair{Public: NaclDHKeyPublic(*pub), Private: (*NaclDHKeyPrivate)(priv)}
+		ret.Ephemeral = true
+	} else if err = sender.CheckSecretKey(); err != nil {
+		return
+	}
+	ret.Sender = sender.GetKid()
+
+	var key [NACL_SECRETBOX_SIZE]byte
+	if _, err = rand.Read(key[:]); err != nil {
+		return
+	}
+
+	if ret.Nonce, err = randomNonce(); err != nil {
+		return
+	}
+	ret.Ciphertext = secretbox.Seal(nil, msg, &ret.Nonce, &key)
+	boxed := append(key[:], ret.ciphertextHash()...)
+
+	for _, r := range recipients {
+		rb := NaclBoxRecipient{Kid: r.GetKid()}
+		if rb.Nonce, err = randomNonce(); err != nil {
+			return
+		}
+		rb.Box = box.Seal(nil, boxed, &rb.Nonce, r.ToNaclLibrary(), sender.Private.ToNaclLibrary())
+		ret.Recipients = append(ret.Recipients, rb)
+	}
+	return
+}
+
+// ciphertextHash is what each recipient's box pins the message down with.
+func (e *NaclEncryption) ciphertextHash() []byte {
+	h := sha512.New()
+	h.Write(e.Nonce[:])
+	h.Write(e.Ciphertext)
+	return h.Sum(nil)
+}
+
+// RecipientKids returns the KIDs of all the keys this message was
+// encrypted for.
+func (e *NaclEncryption) RecipientKids() (ret []KID) {
+	for _, r := range e.Recipients {
+		ret = append(ret, r.Kid)
+	}
+	return
+}
+
+// Decrypt opens the message with the given secret DH key, which must be
+// one of the recipients'.
+func (e *NaclEncryption) Decrypt(k NaclDHKeyPair) (ret []byte, err error) {
+	if err = k.CheckSecretKey(); err != nil {
+		return
+	}
+	if e.EncType != ENC_KB_NACL_BOX {
+		err = UnmarshalError{"NaCl encryption"}
+		return
+	}
+	sender := e.Sender.ToNaclDHKeyPublic()
+	if sender == nil {
+		err = BadKeyError{"bad sender key"}
+		return
+	}
+
+	kid := k.GetKid()
+	for _, r := range e.Recipients {
+		if !r.Kid.Eq(kid) {
+			continue
+		}
+		b, ok := box.Open(nil, r.Box, &r.Nonce, sender.ToNaclLibrary(), k.Private.ToNaclLibrary())
+		if !ok || len(b) != NACL_SECRETBOX_SIZE+sha512.Size {
+			err = DecryptionError{}
+			return
+		}
+		if !hmac.Equal(b[NACL_SECRETBOX_SIZE:], e.ciphertextHash()) {
+			err = DecryptionError{}
+			return
+		}
+		var key [NACL_SECRETBOX_SIZE]byte
+		copy(key[:], b)
+		if ret, ok = secretbox.Open(nil, e.Ciphertext, &e.Nonce, &key); !ok {
+			err = DecryptionError{}
+		}
+		return
+	}
+	err = NoKeyError{"Message wasn't encrypted for key " + kid.String()}
+	return
+}
+
+func (e *NaclEncryption) ToPacket() (ret *KeybasePacket, err error) {
+	ret = &KeybasePacket{
+		Version: KEYBASE_PACKET_V1,
+		Tag:     TAG_ENCRYPTION,
+	}
+	ret.Body = e
+	return
+}
+
+func (p KeybasePacket) ToNaclEncryption() (*NaclEncryption, error) {
+	ret, ok := p.Body.(*NaclEncryption)
+	if !ok {
+		return nil, UnmarshalError{"NaCl encryption"}
+	}
+	return ret, nil
+}
+
+func (e *NaclEncryption) ArmoredEncode() (ret string, err error) {
+	return PacketArmoredEncode(e)
+}
+
+func DecodeArmoredNaclEncryption(s string) (ret *NaclEncryption, err error) {
+	var packet *KeybasePacket
+	if packet, err = DecodeArmoredPacket(s); err == nil {
+		ret, err = packet.ToNaclEncryption()
+	}
+	return
+}
@@ -0,0 +1,98 @@
+package libkb
+
+import (
+	"bytes"
+	"golang.org/x/crypto/nacl/box"
+	"golang.org/x/crypto/nacl/secretbox"
+	"testing"
+)
+
+func genNaclDHKey(t *testing.T) NaclDHKeyPair {
+	key, err := GenerateNaclDHKeyPair()
+	if err != nil {
+		t.Fatalf("keygen error: %s", err)
+	}
+	return key.(NaclDHKeyPair)
+}
+
+func TestNaclEncryptRoundTrip(t *testing.T) {
+	alice, bob, eve := genNaclDHKey(t), genNaclDHKey(t), genNaclDHKey(t)
+	sender := genNaclDHKey(t)
+	msg := []byte("The mission is a go")
+
+	for _, s := range []*NaclDHKeyPair{nil, &sender} {
+		enc, err := NaclEncrypt(msg, []NaclDHKeyPublic{alice.Public, bob.Public}, s)
+		if err != nil {
+			t.Fatalf("encrypt error: %s", err)
+		}
+		if enc.Ephemeral != (s == nil) {
+			t.Errorf("ephemeral=%v with sender %v", enc.Ephemeral, s)
+		}
+		armored, err := enc.ArmoredEncode()
+		if err != nil {
+			t.Fatalf("armor error: %s", err)
+		}
+		dec, err := DecodeArmoredNaclEncryption(armored)
+		if err != nil {
+			t.Fatalf("decode error: %s", err)
+		}
+		if s != nil && !dec.Sender.Eq(sender.GetKid()) {
+			t.Errorf("wrong sender KID: %s", dec.Sender)
+		}
+		for _, k := range []NaclDHKeyPair{alice, bob} {
+			out, err := dec.Decrypt(k)
+			if err != nil {
+				t.Fatalf("decrypt error: %s", err)
+			}
+			if !bytes.Equal(out, msg) {
+				t.Errorf("bad plaintext: %q", out)
+			}
+		}
+		if _, err := dec.Decrypt(eve); err == nil {
+			t.Errorf("non-recipient decrypted the message")
+		}
+	}
+}
+
+func TestNaclEncryptTampered(t *testing.T) {
+	alice := genNaclDHKey(t)
+	enc, err := NaclEncrypt([]byte("hello"), []NaclDHKeyPublic{alice.Public}, nil)
+	if err != nil {
+		t.Fatalf("encrypt error: %s", err)
+	}
+	enc.Ciphertext[0] ^= 1
+	if _, err = enc.Decrypt(alice); err == nil {
+		t.Errorf("tampered message decrypted")
+	} else if _, ok := err.(DecryptionError); !ok {
+		t.Errorf("unexpected error: %s", err)
+	}
+}
+
+// openKey opens a recipient's box, as that recipient, to get at the
+// message key, which every recipient learns.
+func openKey(t *testing.T, rb NaclBoxRecipient, sender KID, k NaclDHKeyPair) (key [NACL_SECRETBOX_SIZE]byte) {
+	b, ok := box.Open(nil, rb.Box, &rb.Nonce, sender.ToNaclDHKeyPublic().ToNaclLibrary(), k.Private.ToNaclLibrary())
+	if !ok {
+		t.Fatalf("recipient couldn't open their own box")
+	}
+	copy(key[:], b)
+	return
+}
+
+func TestNaclEncryptRecipientForgery(t *testing.T) {
+	alice, bob, sender := genNaclDHKey(t), genNaclDHKey(t), genNaclDHKey(t)
+	enc, err := NaclEncrypt([]byte("pay bob $10"), []NaclDHKeyPublic{alice.Public, bob.Public}, &sender)
+	if err != nil {
+		t.Fatalf("encrypt error: %s", err)
+	}
+
+	// Bob encrypts his own message under the key he was sent, and hands
+	// it to alice along with the sender's boxes.
+	key := openKey(t, enc.Recipients[1], enc.Sender, bob)
+	enc.Ciphertext = secretbox.Seal(nil, []byte("pay bob $1000"), &enc.Nonce, &key)
+	if out, err := enc.Decrypt(alice); err == nil {
+		t.Errorf("alice took bob's forgery for the sender's message: %q", out)
+	} else if _, ok := err.(DecryptionError); !ok {
+		t.Errorf("unexpected error: %s", err)
+	}
+}
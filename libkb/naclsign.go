@@ -0,0 +1,223 @@
+package libkb
+
+import (
+	"crypto/sha512"
+	"fmt"
+	"golang.org/x/crypto/openpgp/armor"
+	"io"
+	"strings"
+)
+
+// LookupKidOwner asks the server which user has the key with the given
+// KID in their key family.
+func LookupKidOwner(kid KID) (uid *UID, err error) {
+	var res *ApiRes
+	res, err = G.API.Get(ApiArg{
+		Endpoint:    "key/fetch",
+		NeedSession: false,
+		Args: HttpArgs{
+			"kids": S{kid.String()},
+			"ops":  I{PGP_OP_VERIFY},
+		},
+	})
+	if err != nil {
+		return
+	}
+	var n int
+	keys := res.Body.AtKey("keys")
+	if n, err = keys.Len(); err != nil {
+		return
+	} else if n == 0 {
+		err = NoKeyError{fmt.Sprintf("No Keybase user has key %s", kid)}
+		return
+	}
+	return GetUid(keys.AtIndex(0).AtKey("uid"))
+}
+
+// LoadSibkeyOwner finds the user who owns the given KID, and checks
+// against the ComputedKeyFamily that their sigchain yields that it's one
+// of their active sibkeys, and so can sign on their behalf.
+func LoadSibkeyOwner(kid KID) (owner *User, key GenericKey, err error) {
+	return LoadSibkeyOwnerAt(kid, nil)
+}
+
+// LoadSibkeyOwnerAt is like LoadSibkeyOwner, but if at is given, the key
+// has to have been an active sibkey at that point in the owner's chain,
+// rather than now.
+func LoadSibkeyOwnerAt(kid KID, at *KeyStateQuery) (owner *User, key GenericKey, err error) {
+	var uid *UID
+	if uid, err = LookupKidOwner(kid); err != nil {
+		return
+	}
+	if owner, err = LoadUser(LoadUserArg{Uid: uid, AllKeys: (at != nil)}); err != nil {
+		return
+	}
+	var ckf *ComputedKeyFamily
+	if at != nil {
+		ckf, err = owner.GetComputedKeyFamilyAt(*at)
+	} else {
+		ckf, err = owner.RequireComputedKeyFamily()
+	}
+	if err != nil {
+		return
+	}
+	key, err = ckf.FindActiveSibkey(FOKID{Kid: kid})
+	return
+}
+
+// GetDeviceSigningKey unlocks the secret half of this device's Ed25519
+// sibkey, as configured by the per-device KID.
+func GetDeviceSigningKey(reason string, ui SecretUI) (ret NaclSigningKeyPair, err error) {
+	var p3skb *P3SKB
+	var key GenericKey
+	var ok bool
+
+	kid := G.Env.GetPerDeviceKID()
+	if kid == nil {
+		err = NotProvisionedError{}
+	} else if G.Keyrings.P3SKB == nil {
+		err = NoKeyringsError{}
+	} else if p3skb = G.Keyrings.P3SKB.LookupByKid(kid); p3skb == nil {
+		err = NoSecretKeyError{}
+	} else if key, err = p3skb.PromptAndUnlock(reason, "your device key", ui); err != nil {
+	} else if ret, ok = key.(NaclSigningKeyPair); !ok {
+		err = KeyCannotSignError{}
+	}
+	return
+}
+
+// naclDetachedPrefix goes in front of the digest in a detached signature,
+// so that it can't be passed off as a signature over some other message
+// that happens to be 64 bytes long, or vice versa.
+const naclDetachedPrefix = "Keybase-Detached-1\x00"
+
+// naclDetachedPayload is what a detached NaclSig actually signs: the
+// prefix, then the SHA-512 digest of everything read from r.
+func naclDetachedPayload(r io.Reader) (ret []byte, err error) {
+	h := sha512.New()
+	if _, err = io.Copy(h, r); err == nil {
+		ret = h.Sum([]byte(naclDetachedPrefix))
+	}
+	return
+}
+
+// SignNaclDetached makes a detached NaclSig over the SHA-512 digest of
+// everything read from r, behind a fixed prefix. The packet leaves the
+// payload out, so the verifier must hash the data itself.
+func SignNaclDetached(r io.Reader, key NaclSigningKeyPair) (ret *NaclSig, err error) {
+	var payload []byte
+	if payload, err = naclDetachedPayload(r); err != nil {
+		return
+	}
+	if ret, err = key.Sign(payload); err == nil {
+		ret.Payload = nil
+	}
+	return
+}
+
+// VerifyNaclDetached checks an armored, detached NaclSig against the data
+// read from r, and returns the KID that made it.
+func VerifyNaclDetached(r io.Reader, armored string) (kid KID, err error) {
+	var packet *KeybasePacket
+	var sig *NaclSig
+	if packet, err = DecodeArmoredPacket(strings.TrimSpace(armored)); err != nil {
+		return
+	}
+	if sig, err = packet.ToNaclSig(); err != nil {
+		return
+	}
+	if !sig.Detached || len(sig.Payload) > 0 {
+		err = BadSigError{"expected a detached signature"}
+		return
+	}
+	if sig.Payload, err = naclDetachedPayload(r); err != nil {
+		return
+	}
+	if err = sig.Verify(); err == nil {
+		kid = sig.Kid
+	}
+	return
+}
+
+//=============================================================================
+
+type NaclSignArg struct {
+	Source   io.Reader
+	Sink     io.Writer
+	Detached bool
+	Binary   bool // armored by default; detached signatures are always armored
+
+	SecretUI SecretUI
+	LogUI    LogUI
+}
+
+// NaclSignEngine signs with this device's Ed25519 sibkey, for machines
+// that have no PGP key. It makes either a chunked signed stream with
+// the payload attached, or a detached NaclSig packet.
+type NaclSignEngine struct {
+	arg *NaclSignArg
+}
+
+func NewNaclSignEngine(arg *NaclSignArg) *NaclSignEngine {
+	return &NaclSignEngine{arg: arg}
+}
+
+func (e *NaclSignEngine) Run() (err error) {
+	G.Log.Debug("+ NaclSignEngine.Run")
+	defer func() {
+		G.Log.Debug("- NaclSignEngine.Run -> %s", ErrToOk(err))
+	}()
+
+	var key NaclSigningKeyPair
+	if key, err = GetDeviceSigningKey("command-line signature", e.arg.SecretUI); err != nil {
+		return
+	}
+
+	if e.arg.Detached {
+		return e.signDetached(key)
+	}
+	return e.signAttached(key)
+}
+
+func (e *NaclSignEngine) signDetached(key NaclSigningKeyPair) (err error) {
+	var sig *NaclSig
+	var armored string
+	if sig, err = SignNaclDetached(e.arg.Source, key); err != nil {
+		return
+	}
+	if armored, err = sig.ArmoredEncode(); err != nil {
+		return
+	}
+	_, err = io.WriteString(e.arg.Sink, armored+"\n")
+	return
+}
+
+func (e *NaclSignEngine) signAttached(key NaclSigningKeyPair) (err error) {
+	var aout io.WriteCloser
+	var out io.Writer = e.arg.Sink
+	if !e.arg.Binary {
+		if aout, err = armor.Encode(e.arg.Sink, NACL_SIGNED_ARMOR, PgpArmorHeaders()); err != nil {
+			return
+		}
+		out = aout
+	}
+
+	var in io.WriteCloser
+	if in, err = NewNaclSignStream(out, key); err != nil {
+		return
+	}
+	var written int64
+	if written, err = io.Copy(in, e.arg.Source); err == nil && written == 0 {
+		err = fmt.Errorf("Empty source file, nothing to sign")
+	}
+	if err != nil {
+		return
+	}
+	if err = in.Close(); err != nil {
+		return
+	}
+	if aout != nil {
+		err = aout.Close()
+	}
+	return
+}
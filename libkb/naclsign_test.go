@@ -0,0 +1,63 @@
+package libkb
+
+import (
+	"crypto/sha512"
+	"strings"
+	"testing"
+)
+
+func TestNaclDetachedSign(t *testing.T) {
+	key, err := GenerateNaclSigningKeyPair()
+	if err != nil {
+		t.Fatalf("keygen error: %s", err)
+	}
+	msg := strings.Repeat("build artifact bytes\n", 1000)
+
+	sig, err := SignNaclDetached(strings.NewReader(msg), key.(NaclSigningKeyPair))
+	if err != nil {
+		t.Fatalf("sign error: %s", err)
+	}
+	if len(sig.Payload) > 0 || !sig.Detached {
+		t.Errorf("expected a detached signature without a payload")
+	}
+	armored, err := sig.ArmoredEncode()
+	if err != nil {
+		t.Fatalf("armor error: %s", err)
+	}
+
+	kid, err := VerifyNaclDetached(strings.NewReader(msg), armored+"\n")
+	if err != nil {
+		t.Fatalf("verify error: %s", err)
+	}
+	if !kid.Eq(key.GetKid()) {
+		t.Errorf("wrong KID: %s", kid)
+	}
+
+	if _, err = VerifyNaclDetached(strings.NewReader(msg+"x"), armored); err == nil {
+		t.Errorf("signature verified over the wrong data")
+	}
+}
+
+func TestNaclDetachedSignDomain(t *testing.T) {
+	key, err := GenerateNaclSigningKeyPair()
+	if err != nil {
+		t.Fatalf("keygen error: %s", err)
+	}
+	msg := "some build artifact"
+
+	// A signature over the bare digest, as some other protocol might
+	// make, mustn't pass for a detached signature of the message.
+	digest := sha512.Sum512([]byte(msg))
+	sig, err := key.(NaclSigningKeyPair).Sign(digest[:])
+	if err != nil {
+		t.Fatalf("sign error: %s", err)
+	}
+	sig.Payload = nil
+	armored, err := sig.ArmoredEncode()
+	if err != nil {
+		t.Fatalf("armor error: %s", err)
+	}
+	if _, err = VerifyNaclDetached(strings.NewReader(msg), armored); err == nil {
+		t.Errorf("a signature over the bare digest verified")
+	}
+}
@@ -0,0 +1,469 @@
+package libkb
+
+//
+// Streaming versions of the NaCl encryption and signature formats, for
+// payloads too big to hold in memory. A stream is a header followed by
+// chunks of at most NACL_STREAM_CHUNK_SIZE bytes of payload each, where
+// every chunk is authenticated along with its position in the stream and
+// whether it's the last one. So a reader that gets to the end without
+// seeing a final chunk knows the stream was truncated, and chunks that were
+// dropped, reordered, or spliced in from another stream fail to open.
+//
+// Every recipient of an encrypted stream learns the stream key, so each
+// chunk also carries a MAC for each recipient, under a key that only that
+// recipient and the sender can derive.  That way, one recipient can't
+// pass off chunks of their own to another as the sender's.
+//
+// Each header and chunk is msgpack-encoded and framed with a 4-byte
+// big-endian length, so that readers can refuse oversized frames before
+// allocating anything.
+//
+
+import (
+	"bytes"
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha512"
+	"encoding/binary"
+	"fmt"
+	"github.com/agl/ed25519"
+	"github.com/ugorji/go/codec"
+	"golang.org/x/crypto/nacl/box"
+	"golang.org/x/crypto/nacl/secretbox"
+	"io"
+)
+
+const (
+	NACL_STREAM_V1                = 1
+	NACL_STREAM_CHUNK_SIZE        = 1 << 20
+	NACL_STREAM_NONCE_PREFIX_SIZE = 16
+	NACL_STREAM_MAX_HEADER_SIZE   = 1 << 20
+	NACL_STREAM_MAX_FRAME_SIZE    = NACL_STREAM_CHUNK_SIZE + 1024
+	NACL_STREAM_MAC_SIZE          = 32
+
+	// NACL_STREAM_MAC_OVERHEAD is the most that each recipient's MAC adds to
+	// a chunk frame, msgpack framing and all.
+	NACL_STREAM_MAC_OVERHEAD = NACL_STREAM_MAC_SIZE + 8
+)
+
+// Armor block types for streams.
+var (
+	NACL_ENCRYPTED_ARMOR = "KEYBASE ENCRYPTED MESSAGE"
+	NACL_SIGNED_ARMOR    = "KEYBASE SIGNED MESSAGE"
+)
+
+var naclStreamSigPrefix = []byte("Keybase chunked signature v1\x00")
+var naclStreamMacKeyPrefix = []byte("Keybase chunked encryption MAC key v1\x00")
+
+type NaclStreamHeader struct {
+	Tag     int `codec:"tag"`
+	Version int `codec:"version"`
+
+	// Kid is the sender's DH key for an encrypted stream, or the signing
+	// key for a signed one.
+	Kid       KID  `codec:"kid"`
+	Ephemeral bool `codec:"ephemeral,omitempty"`
+
+	// Recipients have the stream key, boxed for each of them. Only set
+	// for encrypted streams.
+	Recipients []NaclBoxRecipient `codec:"recipients,omitempty"`
+
+	// Nonce is a random per-stream prefix for the chunk nonces, so that
+	// chunks can't be moved from one stream to another.
+	Nonce [NACL_STREAM_NONCE_PREFIX_SIZE]byte `codec:"nonce"`
+}
+
+type naclStreamChunk struct {
+	Final bool   `codec:"final"`
+	Data  []byte `codec:"data"`
+	Sig   []byte `codec:"sig,omitempty"`
+
+	// Macs has a MAC of the chunk for each recipient of an encrypted
+	// stream, in the same order as the header's Recipients.
+	Macs [][]byte `codec:"macs,omitempty"`
+}
+
+func writeStreamFrame(w io.Writer, v interface{}) (err error) {
+	var encoded []byte
+	if err = codec.NewEncoderBytes(&encoded, CodecHandle()).Encode(v); err != nil {
+		return
+	}
+	var l [4]byte
+	binary.BigEndian.PutUint32(l[:], uint32(len(encoded)))
+	if _, err = w.Write(l[:]); err == nil {
+		_, err = w.Write(encoded)
+	}
+	return
+}
+
+// readStreamFrame reads and decodes one frame, returning io.EOF only if
+// the stream ended cleanly before it.
+func readStreamFrame(r io.Reader, max int, v interface{}) (err error) {
+	var l [4]byte
+	if _, err = io.ReadFull(r, l[:]); err == io.ErrUnexpectedEOF {
+		return StreamError{"truncated frame length"}
+	} else if err != nil {
+		return
+	}
+	n := binary.BigEndian.Uint32(l[:])
+	if n > uint32(max) {
+		return StreamError{fmt.Sprintf("frame too big (%d bytes)", n)}
+	}
+	buf := make([]byte, n)
+	if _, err = io.ReadFull(r, buf); err == io.EOF || err == io.ErrUnexpectedEOF {
+		return StreamError{"truncated frame"}
+	} else if err != nil {
+		return
+	}
+	return codec.NewDecoderBytes(buf, CodecHandle()).Decode(v)
+}
+
+// chunkCounter packs a chunk's position and whether it's the last one
+// into the value that gets authenticated with it.
+func chunkCounter(seqno uint64, final bool) (ret [8]byte) {
+	if final {
+		seqno |= 1 << 63
+	}
+	binary.BigEndian.PutUint64(ret[:], seqno)
+	return
+}
+
+func (h *NaclStreamHeader) chunkNonce(seqno uint64, final bool) (ret [NACL_NONCE_SIZE]byte) {
+	ctr := chunkCounter(seqno, final)
+	copy(ret[:], h.Nonce[:])
+	copy(ret[NACL_STREAM_NONCE_PREFIX_SIZE:], ctr[:])
+	return
+}
+
+func (h *NaclStreamHeader) chunkSigPayload(seqno uint64, final bool, data []byte) []byte {
+	ctr := chunkCounter(seqno, final)
+	sum := sha512.Sum512(data)
+	var buf bytes.Buffer
+	buf.Write(naclStreamSigPrefix)
+	buf.Write(h.Nonce[:])
+	buf.Write(ctr[:])
+	buf.Write(sum[:])
+	return buf.Bytes()
+}
+
+// naclStreamMacKey derives the key for the chunk MACs between the sender
+// and one recipient, from their DH shared secret.
+func naclStreamMacKey(pub NaclDHKeyPublic, priv NaclDHKeyPrivate) []byte {
+	var shared [NACL_DH_KEYSIZE]byte
+	box.Precompute(&shared, pub.ToNaclLibrary(), priv.ToNaclLibrary())
+	mac := hmac.New(sha512.New, shared[:])
+	mac.Write(naclStreamMacKeyPrefix)
+	return mac.Sum(nil)[:NACL_STREAM_MAC_SIZE]
+}
+
+func (h *NaclStreamHeader) chunkMac(macKey []byte, seqno uint64, final bool, ciphertext []byte) []byte {
+	ctr := chunkCounter(seqno, final)
+	sum := sha512.Sum512(ciphertext)
+	mac := hmac.New(sha512.New, macKey)
+	mac.Write(h.Nonce[:])
+	mac.Write(ctr[:])
+	mac.Write(sum[:])
+	return mac.Sum(nil)[:NACL_STREAM_MAC_SIZE]
+}
+
+//=============================================================================
+
+type naclStreamWriter struct {
+	w      io.Writer
+	hdr    *NaclStreamHeader
+	seal   func(seqno uint64, final bool, data []byte) (*naclStreamChunk, error)
+	buf    []byte
+	seqno  uint64
+	closed bool
+}
+
+func newNaclStreamWriter(w io.Writer, hdr *NaclStreamHeader,
+	seal func(uint64, bool, []byte) (*naclStreamChunk, error)) (ret *naclStreamWriter, err error) {
+
+	hdr.Version = NACL_STREAM_V1
+	if _, err = rand.Read(hdr.Nonce[:]); err != nil {
+		return
+	}
+	if err = writeStreamFrame(w, hdr); err != nil {
+		return
+	}
+	ret = &naclStreamWriter{
+		w:    w,
+		hdr:  hdr,
+		seal: seal,
+		buf:  make([]byte, 0, NACL_STREAM_CHUNK_SIZE),
+	}
+	return
+}
+
+func (s *naclStreamWriter) flush(final bool) (err error) {
+	var chunk *naclStreamChunk
+	if chunk, err = s.seal(s.seqno, final, s.buf); err != nil {
+		return
+	}
+	if err = writeStreamFrame(s.w, chunk); err != nil {
+		return
+	}
+	s.seqno++
+	s.buf = s.buf[:0]
+	return
+}
+
+// Write buffers up a chunk's worth of data. A full chunk is only written
+// out once more data comes in, since until then we don't know whether
+// it's the final one.
+func (s *naclStreamWriter) Write(p []byte) (n int, err error) {
+	if s.closed {
+		return 0, StreamError{"write to closed stream"}
+	}
+	for len(p) > 0 {
+		if len(s.buf) == NACL_STREAM_CHUNK_SIZE {
+			if err = s.flush(false); err != nil {
+				return
+			}
+		}
+		k := NACL_STREAM_CHUNK_SIZE - len(s.buf)
+		if k > len(p) {
+			k = len(p)
+		}
+		s.buf = append(s.buf, p[:k]...)
+		p = p[k:]
+		n += k
+	}
+	return
+}
+
+// Close writes out the final chunk. It doesn't close the underlying writer.
+func (s *naclStreamWriter) Close() error {
+	if s.closed {
+		return nil
+	}
+	s.closed = true
+	return s.flush(true)
+}
+
+type naclStreamReader struct {
+	r     io.Reader
+	max   int // biggest chunk frame we'll take
+	open  func(seqno uint64, chunk *naclStreamChunk) ([]byte, error)
+	buf   []byte
+	seqno uint64
+	done  bool
+	err   error
+}
+
+func (s *naclStreamReader) next() (err error) {
+	var chunk naclStreamChunk
+	if err = readStreamFrame(s.r, s.max, &chunk); err == io.EOF {
+		return StreamError{"stream was truncated"}
+	} else if err != nil {
+		return
+	}
+	if s.buf, err = s.open(s.seqno, &chunk); err != nil {
+		return
+	}
+	s.seqno++
+	if s.done = chunk.Final; s.done {
+		var extra [1]byte
+		if n, _ := s.r.Read(extra[:]); n > 0 {
+			return StreamError{"trailing data after final chunk"}
+		}
+	}
+	return
+}
+
+// Read returns data one chunk at a time, after that chunk has been
+// authenticated. Callers should only trust the data they've read once
+// Read has returned io.EOF, since a truncated stream isn't detected until
+// the end.
+func (s *naclStreamReader) Read(p []byte) (n int, err error) {
+	for len(s.buf) == 0 {
+		if s.err != nil {
+			return 0, s.err
+		}
+		if s.done {
+			return 0, io.EOF
+		}
+		s.err = s.next()
+	}
+	n = copy(p, s.buf)
+	s.buf = s.buf[n:]
+	return
+}
+
+func readStreamHeader(r io.Reader, tag int) (hdr *NaclStreamHeader, err error) {
+	hdr = &NaclStreamHeader{}
+	if err = readStreamFrame(r, NACL_STREAM_MAX_HEADER_SIZE, hdr); err == io.EOF {
+		err = StreamError{"empty stream"}
+	}
+	if err != nil {
+		return
+	}
+	if hdr.Tag != tag {
+		err = StreamError{fmt.Sprintf("wrong stream type %d (wanted %d)", hdr.Tag, tag)}
+	} else if hdr.Version != NACL_STREAM_V1 {
+		err = StreamError{fmt.Sprintf("unknown stream version %d", hdr.Version)}
+	}
+	return
+}
+
+//=============================================================================
+
+// NewNaclEncryptStream returns a writer that encrypts everything written
+// to it for the given recipients, in chunks. As with NaclEncrypt, a nil
+// sender means to use an ephemeral key; otherwise, the chunk MACs tell
+// each recipient that the stream came from the sender. The caller must
+// Close the writer to finish the stream.
+func NewNaclEncryptStream(w io.Writer, recipients []NaclDHKeyPublic, sender *NaclDHKeyPair) (ret io.WriteCloser, err error) {
+	if len(recipients) == 0 {
+		err = NoKeyError{"No recipients to encrypt for"}
+		return
+	}
+	hdr := &NaclStreamHeader{Tag: TAG_ENCRYPTION_STREAM}
+
+	if sender == nil {
+		var pub, priv *[NACL_DH_KEYSIZE]byte
+		if pub, priv, err = box.GenerateKey(rand.Reader); err != nil {
+			return
+		}
+		sender = &NaclDHKeyPair{Public: NaclDHKeyPublic(*pub), Private: (*NaclDHKeyPrivate)(priv)}
+		hdr.Ephemeral = true
+	} else if err = sender.CheckSecretKey(); err != nil {
+		return
+	}
+	hdr.Kid = sender.GetKid()
+
+	var key [NACL_SECRETBOX_SIZE]byte
+	if _, err = rand.Read(key[:]); err != nil {
+		return
+	}
+	var macKeys [][]byte
+	for _, r := range recipients {
+		rb := NaclBoxRecipient{Kid: r.GetKid()}
+		if rb.Nonce, err = randomNonce(); err != nil {
+			return
+		}
+		rb.Box = box.Seal(nil, key[:], &rb.Nonce, r.ToNaclLibrary(), sender.Private.ToNaclLibrary())
+		hdr.Recipients = append(hdr.Recipients, rb)
+		macKeys = append(macKeys, naclStreamMacKey(r, *sender.Private))
+	}
+
+	seal := func(seqno uint64, final bool, data []byte) (*naclStreamChunk, error) {
+		nonce := hdr.chunkNonce(seqno, final)
+		chunk := &naclStreamChunk{Final: final, Data: secretbox.Seal(nil, data, &nonce, &key)}
+		for _, mk := range macKeys {
+			chunk.Macs = append(chunk.Macs, hdr.chunkMac(mk, seqno, final, chunk.Data))
+		}
+		return chunk, nil
+	}
+	return newNaclStreamWriter(w, hdr, seal)
+}
+
+// NewNaclDecryptStream reads the header of an encrypted stream, calls
+// findKey with the KIDs it was encrypted for, and returns a reader of
+// the decrypted payload.
+func NewNaclDecryptStream(r io.Reader, findKey func(kids []KID) (*NaclDHKeyPair, error)) (ret io.Reader, hdr *NaclStreamHeader, err error) {
+	if hdr, err = readStreamHeader(r, TAG_ENCRYPTION_STREAM); err != nil {
+		return
+	}
+	sender := hdr.Kid.ToNaclDHKeyPublic()
+	if sender == nil {
+		err = BadKeyError{"bad sender key"}
+		return
+	}
+
+	var kids []KID
+	for _, rb := range hdr.Recipients {
+		kids = append(kids, rb.Kid)
+	}
+	var dh *NaclDHKeyPair
+	if dh, err = findKey(kids); err != nil {
+		return
+	}
+	if err = dh.CheckSecretKey(); err != nil {
+		return
+	}
+
+	var key [NACL_SECRETBOX_SIZE]byte
+	found := false
+	var index int
+	for i, rb := range hdr.Recipients {
+		if !rb.Kid.Eq(dh.GetKid()) {
+			continue
+		}
+		b, ok := box.Open(nil, rb.Box, &rb.Nonce, sender.ToNaclLibrary(), dh.Private.ToNaclLibrary())
+		if !ok || len(b) != NACL_SECRETBOX_SIZE {
+			err = DecryptionError{}
+			return
+		}
+		copy(key[:], b)
+		found = true
+		index = i
+		break
+	}
+	if !found {
+		err = NoKeyError{"Message wasn't encrypted for key " + dh.GetKid().String()}
+		return
+	}
+	macKey := naclStreamMacKey(*sender, *dh.Private)
+
+	open := func(seqno uint64, chunk *naclStreamChunk) ([]byte, error) {
+		if len(chunk.Macs) != len(hdr.Recipients) ||
+			!hmac.Equal(chunk.Macs[index], hdr.chunkMac(macKey, seqno, chunk.Final, chunk.Data)) {
+			return nil, DecryptionError{}
+		}
+		nonce := hdr.chunkNonce(seqno, chunk.Final)
+		data, ok := secretbox.Open(nil, chunk.Data, &nonce, &key)
+		if !ok {
+			return nil, DecryptionError{}
+		}
+		return data, nil
+	}
+	max := NACL_STREAM_MAX_FRAME_SIZE + len(hdr.Recipients)*NACL_STREAM_MAC_OVERHEAD
+	ret = &naclStreamReader{r: r, max: max, open: open}
+	return
+}
+
+// NewNaclSignStream returns a writer that signs everything written to it
+// with the given key, in chunks, and writes the payload and signatures
+// out to w. The caller must Close the writer to finish the stream.
+func NewNaclSignStream(w io.Writer, key NaclSigningKeyPair) (ret io.WriteCloser, err error) {
+	if err = key.CheckSecretKey(); err != nil {
+		return
+	}
+	hdr := &NaclStreamHeader{Tag: TAG_SIGNATURE_STREAM, Kid: key.GetKid()}
+	seal := func(seqno uint64, final bool, data []byte) (*naclStreamChunk, error) {
+		sig := ed25519.Sign(key.Private.ToNaclLibrary(), hdr.chunkSigPayload(seqno, final, data))
+		// Copy data, since the writer reuses its buffer.
+		return &naclStreamChunk{Final: final, Data: append([]byte{}, data...), Sig: sig[:]}, nil
+	}
+	return newNaclStreamWriter(w, hdr, seal)
+}
+
+// NewNaclVerifyStream reads the header of a signed stream and returns a
+// reader of the payload, which checks each chunk's signature as it goes.
+// The header says who signed it; it's up to the caller to decide whether
+// that key is trusted.
+func NewNaclVerifyStream(r io.Reader) (ret io.Reader, hdr *NaclStreamHeader, err error) {
+	if hdr, err = readStreamHeader(r, TAG_SIGNATURE_STREAM); err != nil {
+		return
+	}
+	key := hdr.Kid.ToNaclSigningKeyPublic()
+	if key == nil {
+		err = BadKeyError{"bad signing key"}
+		return
+	}
+	open := func(seqno uint64, chunk *naclStreamChunk) ([]byte, error) {
+		var sig [ed25519.SignatureSize]byte
+		if len(chunk.Sig) != len(sig) {
+			return nil, VerificationError{}
+		}
+		copy(sig[:], chunk.Sig)
+		if !ed25519.Verify(key.ToNaclLibrary(), hdr.chunkSigPayload(seqno, chunk.Final, chunk.Data), &sig) {
+			return nil, VerificationError{}
+		}
+		return chunk.Data, nil
+	}
+	ret = &naclStreamReader{r: r, max: NACL_STREAM_MAX_FRAME_SIZE, open: open}
+	return
+}
@@ -0,0 +1,234 @@
+package libkb
+
+import (
+	"bytes"
+	"encoding/binary"
+	"golang.org/x/crypto/nacl/secretbox"
+	"io"
+	"io/ioutil"
+	"testing"
+)
+
+func streamPayload(n int) []byte {
+	ret := make([]byte, n)
+	for i := range ret {
+		ret[i] = byte(i * 7)
+	}
+	return ret
+}
+
+// splitFrames splits a stream into its length-prefixed frames.
+func splitFrames(t *testing.T, b []byte) (ret [][]byte) {
+	for len(b) > 0 {
+		n := int(binary.BigEndian.Uint32(b[:4])) + 4
+		if n > len(b) {
+			t.Fatalf("short frame")
+		}
+		ret = append(ret, b[:n])
+		b = b[n:]
+	}
+	return
+}
+
+func joinFrames(frames ...[]byte) []byte {
+	return bytes.Join(frames, nil)
+}
+
+func encryptStream(t *testing.T, msg []byte, to NaclDHKeyPair) []byte {
+	var out bytes.Buffer
+	w, err := NewNaclEncryptStream(&out, []NaclDHKeyPublic{to.Public}, nil)
+	if err != nil {
+		t.Fatalf("encrypt error: %s", err)
+	}
+	// Write in odd-sized pieces to exercise the chunking.
+	for p := msg; len(p) > 0; {
+		k := 100003
+		if k > len(p) {
+			k = len(p)
+		}
+		if _, err = w.Write(p[:k]); err != nil {
+			t.Fatalf("write error: %s", err)
+		}
+		p = p[k:]
+	}
+	if err = w.Close(); err != nil {
+		t.Fatalf("close error: %s", err)
+	}
+	return out.Bytes()
+}
+
+func decryptStream(stream []byte, key NaclDHKeyPair) ([]byte, error) {
+	findKey := func(kids []KID) (*NaclDHKeyPair, error) { return &key, nil }
+	r, _, err := NewNaclDecryptStream(bytes.NewReader(stream), findKey)
+	if err != nil {
+		return nil, err
+	}
+	return ioutil.ReadAll(r)
+}
+
+func TestNaclEncryptStream(t *testing.T) {
+	key := genNaclDHKey(t)
+	for _, n := range []int{0, 10, NACL_STREAM_CHUNK_SIZE, 2*NACL_STREAM_CHUNK_SIZE + 17} {
+		msg := streamPayload(n)
+		stream := encryptStream(t, msg, key)
+		out, err := decryptStream(stream, key)
+		if err != nil {
+			t.Fatalf("n=%d: decrypt error: %s", n, err)
+		}
+		if !bytes.Equal(out, msg) {
+			t.Errorf("n=%d: plaintext mismatch", n)
+		}
+	}
+}
+
+func TestNaclEncryptStreamTruncated(t *testing.T) {
+	key := genNaclDHKey(t)
+	frames := splitFrames(t, encryptStream(t, streamPayload(2*NACL_STREAM_CHUNK_SIZE+17), key))
+	if len(frames) != 4 {
+		t.Fatalf("expected a header and 3 chunks, got %d frames", len(frames))
+	}
+	if _, err := decryptStream(joinFrames(frames[:3]...), key); err == nil {
+		t.Errorf("truncated stream decrypted")
+	} else if _, ok := err.(StreamError); !ok {
+		t.Errorf("unexpected error: %s", err)
+	}
+}
+
+func TestNaclEncryptStreamReordered(t *testing.T) {
+	key := genNaclDHKey(t)
+	frames := splitFrames(t, encryptStream(t, streamPayload(2*NACL_STREAM_CHUNK_SIZE+17), key))
+	if _, err := decryptStream(joinFrames(frames[0], frames[2], frames[1], frames[3]), key); err == nil {
+		t.Errorf("reordered stream decrypted")
+	}
+	// Dropping a middle chunk shifts the rest out of position too.
+	if _, err := decryptStream(joinFrames(frames[0], frames[1], frames[3]), key); err == nil {
+		t.Errorf("stream with a dropped chunk decrypted")
+	}
+}
+
+func TestNaclSignStream(t *testing.T) {
+	key, err := GenerateNaclSigningKeyPair()
+	if err != nil {
+		t.Fatalf("keygen error: %s", err)
+	}
+	msg := streamPayload(NACL_STREAM_CHUNK_SIZE + 5)
+
+	var out bytes.Buffer
+	w, err := NewNaclSignStream(&out, key.(NaclSigningKeyPair))
+	if err != nil {
+		t.Fatalf("sign error: %s", err)
+	}
+	w.Write(msg)
+	if err = w.Close(); err != nil {
+		t.Fatalf("close error: %s", err)
+	}
+
+	verify := func(stream []byte) ([]byte, error) {
+		r, hdr, err := NewNaclVerifyStream(bytes.NewReader(stream))
+		if err != nil {
+			return nil, err
+		}
+		if !hdr.Kid.Eq(key.GetKid()) {
+			t.Errorf("wrong signer KID: %s", hdr.Kid)
+		}
+		return ioutil.ReadAll(r)
+	}
+
+	stream := out.Bytes()
+	if got, err := verify(stream); err != nil {
+		t.Fatalf("verify error: %s", err)
+	} else if !bytes.Equal(got, msg) {
+		t.Errorf("payload mismatch")
+	}
+
+	frames := splitFrames(t, stream)
+	if _, err := verify(joinFrames(frames[:2]...)); err == nil {
+		t.Errorf("truncated stream verified")
+	}
+	if _, err := verify(joinFrames(frames[0], frames[2], frames[1])); err == nil {
+		t.Errorf("reordered stream verified")
+	}
+	tampered := append([]byte{}, stream...)
+	tampered[len(tampered)-200] ^= 1
+	if _, err := verify(tampered); err == nil {
+		t.Errorf("tampered stream verified")
+	}
+}
+
+func TestNaclStreamOversizedFrame(t *testing.T) {
+	var l [4]byte
+	binary.BigEndian.PutUint32(l[:], NACL_STREAM_MAX_HEADER_SIZE+1)
+	_, _, err := NewNaclVerifyStream(io.MultiReader(bytes.NewReader(l[:]), bytes.NewReader(make([]byte, 16))))
+	if _, ok := err.(StreamError); !ok {
+		t.Errorf("expected a StreamError, got %v", err)
+	}
+}
+
+func TestNaclEncryptStreamRecipientForgery(t *testing.T) {
+	alice, bob, sender := genNaclDHKey(t), genNaclDHKey(t), genNaclDHKey(t)
+	var out bytes.Buffer
+	w, err := NewNaclEncryptStream(&out, []NaclDHKeyPublic{alice.Public, bob.Public}, &sender)
+	if err != nil {
+		t.Fatalf("encrypt error: %s", err)
+	}
+	w.Write([]byte("pay bob $10"))
+	if err = w.Close(); err != nil {
+		t.Fatalf("close error: %s", err)
+	}
+	frames := splitFrames(t, out.Bytes())
+	if _, err = decryptStream(out.Bytes(), alice); err != nil {
+		t.Fatalf("alice couldn't decrypt: %s", err)
+	}
+
+	// Bob encrypts his own chunk under the stream key he was sent, and
+	// hands it to alice with the sender's header and MACs.
+	hdr, err := readStreamHeader(bytes.NewReader(frames[0]), TAG_ENCRYPTION_STREAM)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var chunk naclStreamChunk
+	if err = readStreamFrame(bytes.NewReader(frames[1]), NACL_STREAM_MAX_FRAME_SIZE, &chunk); err != nil {
+		t.Fatal(err)
+	}
+	key := openKey(t, hdr.Recipients[1], hdr.Kid, bob)
+	nonce := hdr.chunkNonce(0, true)
+	chunk.Data = secretbox.Seal(nil, []byte("pay bob $1000"), &nonce, &key)
+	var forged bytes.Buffer
+	forged.Write(frames[0])
+	if err = writeStreamFrame(&forged, &chunk); err != nil {
+		t.Fatal(err)
+	}
+	if got, err := decryptStream(forged.Bytes(), alice); err == nil {
+		t.Errorf("alice took bob's forgery for the sender's stream: %q", got)
+	} else if _, ok := err.(DecryptionError); !ok {
+		t.Errorf("unexpected error: %s", err)
+	}
+}
+
+func TestNaclEncryptStreamManyRecipients(t *testing.T) {
+	// Enough recipients that their MACs overflow the slack in
+	// NACL_STREAM_MAX_FRAME_SIZE.
+	keys := make([]NaclDHKeyPair, 64)
+	var pubs []NaclDHKeyPublic
+	for i := range keys {
+		keys[i] = genNaclDHKey(t)
+		pubs = append(pubs, keys[i].Public)
+	}
+	msg := streamPayload(NACL_STREAM_CHUNK_SIZE + 5)
+	var out bytes.Buffer
+	w, err := NewNaclEncryptStream(&out, pubs, nil)
+	if err != nil {
+		t.Fatalf("encrypt error: %s", err)
+	}
+	w.Write(msg)
+	if err = w.Close(); err != nil {
+		t.Fatalf("close error: %s", err)
+	}
+	for _, i := range []int{0, len(keys) - 1} {
+		if got, err := decryptStream(out.Bytes(), keys[i]); err != nil {
+			t.Errorf("recipient %d: decrypt error: %s", i, err)
+		} else if !bytes.Equal(got, msg) {
+			t.Errorf("recipient %d: plaintext mismatch", i)
+		}
+	}
+}
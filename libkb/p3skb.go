@@ -7,9 +7,12 @@ package libkb
 
 import (
 	"bytes"
+	"crypto/hmac"
 	"encoding/base64"
 	"fmt"
+	"github.com/agl/ed25519"
 	"github.com/keybase/go-triplesec"
+	"golang.org/x/crypto/curve25519"
 	"golang.org/x/crypto/openpgp"
 	"io"
 	"os"
@@ -132,6 +135,83 @@ func (p *P3SKB) UnlockSecretKey(tsec *triplesec.Cipher) (key GenericKey, err err
 	return
 }
 
+// CheckUnlocked checks that an unencrypted block's secret key really is
+// the private half of its public key, by deriving the public key again
+// from the secret. Otherwise a block could claim any KID it liked.
+func (p *P3SKB) CheckUnlocked() (err error) {
+	if p.Priv.Encryption != 0 {
+		return BadKeyError{"can only check unlocked keys"}
+	}
+	var pub GenericKey
+	if pub, err = p.GetPubKey(); err != nil {
+		return
+	}
+
+	var kid KID
+	switch {
+	case IsPgpAlgo(p.Type) || p.Type == 0:
+		var key *PgpKeyBundle
+		if key, err = ReadOneKeyFromBytes(p.Priv.Data); err != nil {
+			return
+		}
+		if err = key.checkSecretKeysMatch(); err != nil {
+			return
+		}
+		kid = key.GetKid()
+	case p.Type == KID_NACL_EDDSA:
+		if len(p.Priv.Data) != ed25519.PrivateKeySize {
+			return BadKeyError{"Secret key was wrong size"}
+		}
+		// The second half of an EdDSA secret key is its public key, so
+		// rederive the whole thing from the seed in the first half.
+		var derivedPub *[ed25519.PublicKeySize]byte
+		var derivedPriv *[ed25519.PrivateKeySize]byte
+		seed := bytes.NewReader(p.Priv.Data[:ed25519.PrivateKeySize-ed25519.PublicKeySize])
+		if derivedPub, derivedPriv, err = ed25519.GenerateKey(seed); err != nil {
+			return
+		}
+		if !hmac.Equal(derivedPriv[:], p.Priv.Data) {
+			return BadKeyError{"secret key is inconsistent"}
+		}
+		kid = NaclSigningKeyPublic(*derivedPub).GetKid()
+	case p.Type == KID_NACL_DH:
+		if len(p.Priv.Data) != NACL_DH_KEYSIZE {
+			return BadKeyError{"Secret key was wrong size"}
+		}
+		var priv, derived [NACL_DH_KEYSIZE]byte
+		copy(priv[:], p.Priv.Data)
+		curve25519.ScalarBaseMult(&derived, &priv)
+		kid = NaclDHKeyPublic(derived).GetKid()
+	default:
+		return UnknownKeyTypeError{p.Type}
+	}
+
+	if !kid.Eq(pub.GetKid()) {
+		err = BadKeyError{fmt.Sprintf("secret key doesn't match public key %s", pub.GetKid())}
+	}
+	return
+}
+
+// Reencrypt unlocks the block with the old passphrase, and locks it up
+// again with tsec. Blocks that aren't encrypted come back as they are.
+func (p *P3SKB) Reencrypt(oldpp string, tsec *triplesec.Cipher) (ret *P3SKB, err error) {
+	if p.Priv.Encryption == 0 {
+		return p, nil
+	}
+	// A fresh cipher for each block, since it takes the salt from the
+	// ciphertext, and caches the key it derives from it.
+	var old *triplesec.Cipher
+	if old, err = triplesec.NewCipher([]byte(oldpp), nil); err != nil {
+		return
+	}
+	defer old.Scrub()
+	var key GenericKey
+	if key, err = p.UnlockSecretKey(old); err != nil {
+		return
+	}
+	return key.ToP3SKB(tsec)
+}
+
 type P3SKBKeyringFile struct {
 	filename string
 	Blocks   []*P3SKB
@@ -258,6 +338,26 @@ func (f P3SKBKeyringFile) LookupByKid(k KID) *P3SKB {
 	return ret
 }
 
+// LookupByPgpKeyId finds the P3SKB holding the PGP key that has a primary
+// key or subkey with the given 64-bit key ID.
+func (f P3SKBKeyringFile) LookupByPgpKeyId(id uint64) *P3SKB {
+	for _, b := range f.Blocks {
+		if b.HasPgpKeyId(id) {
+			return b
+		}
+	}
+	return nil
+}
+
+func (p *P3SKB) HasPgpKeyId(id uint64) bool {
+	key, err := p.GetPubKey()
+	if err != nil {
+		return false
+	}
+	pgp, ok := key.(*PgpKeyBundle)
+	return ok && len(pgp.KeysById(id)) > 0
+}
+
 func (k *P3SKBKeyringFile) LoadAndIndex() error {
 	err := k.Load()
 	if err == nil {
@@ -383,3 +483,47 @@ func (p *P3SKBKeyringFile) PushAndSave(p3skb *P3SKB, lui LogUI) (err error) {
 	}
 	return
 }
+
+// P3SKBStagedKeyring is a new set of blocks for a keyring, written out
+// beside it, so that it can replace the keyring all at once.
+type P3SKBStagedKeyring struct {
+	ring   *P3SKBKeyringFile
+	blocks []*P3SKB
+	tmpfn  string
+}
+
+// Stage writes the given blocks to a temporary file; Commit then swaps
+// them in, and Abort throws them away.
+func (k *P3SKBKeyringFile) Stage(blocks []*P3SKB) (ret *P3SKBStagedKeyring, err error) {
+	tmpfn, tmp, err := TempFile(k.filename, PERM_FILE)
+	if err != nil {
+		return
+	}
+	staged := P3SKBKeyringFile{filename: k.filename, Blocks: blocks}
+	if err = staged.WriteTo(tmp); err == nil {
+		err = tmp.Close()
+	} else {
+		tmp.Close()
+	}
+	if err != nil {
+		os.Remove(tmpfn)
+		return
+	}
+	ret = &P3SKBStagedKeyring{ring: k, blocks: blocks, tmpfn: tmpfn}
+	return
+}
+
+func (s *P3SKBStagedKeyring) Commit() (err error) {
+	if err = os.Rename(s.tmpfn, s.ring.filename); err != nil {
+		return
+	}
+	s.ring.Blocks = s.blocks
+	s.ring.fpIndex = make(map[PgpFingerprint]*P3SKB)
+	s.ring.kidIndex = make(map[string]*P3SKB)
+	s.ring.dirty = false
+	return s.ring.Index()
+}
+
+func (s *P3SKBStagedKeyring) Abort() {
+	os.Remove(s.tmpfn)
+}
@@ -3,7 +3,9 @@ package libkb
 import (
 	"bytes"
 	"encoding/base64"
+	"github.com/keybase/go-triplesec"
 	"github.com/ugorji/go/codec"
+	"os"
 	"testing"
 )
 
@@ -58,3 +60,59 @@ func TestDecodeP3SKBSequence(t *testing.T) {
 		t.Errorf("Expected a repeat of the same key")
 	}
 }
+
+func TestP3SKBReencrypt(t *testing.T) {
+	os.Setenv("KEYBASE_USERNAME", "foo")
+	G.Init()
+	arg := KeyGenArg{PrimaryBits: 1024, SubkeyBits: 1024}
+	if err := arg.Init(); err != nil {
+		t.Fatal(err)
+	}
+	if err := arg.CreatePgpIDs(); err != nil {
+		t.Fatal(err)
+	}
+	arg.AddDefaultUid()
+	key, err := NewPgpKeyBundle(arg)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	oldpp, newpp := "old passphrase", "new passphrase"
+	tsec, _ := triplesec.NewCipher([]byte(oldpp), nil)
+	p3skb, err := key.ToP3SKB(tsec)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	newTsec, _ := triplesec.NewCipher([]byte(newpp), nil)
+	if _, err = p3skb.Reencrypt(newpp, newTsec); err == nil {
+		t.Errorf("reencrypted with the wrong old passphrase")
+	}
+	reenc, err := p3skb.Reencrypt(oldpp, newTsec)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	bad, _ := triplesec.NewCipher([]byte(oldpp), nil)
+	if _, err = reenc.UnlockSecretKey(bad); err == nil {
+		t.Errorf("old passphrase still unlocks the key")
+	}
+	good, _ := triplesec.NewCipher([]byte(newpp), nil)
+	unlocked, err := reenc.UnlockSecretKey(good)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !unlocked.GetKid().Eq(key.GetKid()) {
+		t.Errorf("got a different key back")
+	}
+
+	// NaCl keys are stored unencrypted, and come back as they are.
+	nacl, _ := GenerateNaclSigningKeyPair()
+	np3skb, err := nacl.ToP3SKB(nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if ret, err := np3skb.Reencrypt(oldpp, newTsec); err != nil || ret != np3skb {
+		t.Errorf("NaCl key changed on reencrypt: %v", err)
+	}
+}
@@ -0,0 +1,232 @@
+package libkb
+
+import (
+	"bytes"
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"fmt"
+	"github.com/agl/ed25519"
+	"time"
+)
+
+// A paper key is a sibkey that we derive from a random phrase, which the
+// user writes down rather than keeps on any device. It's signed into the
+// sigchain like a device's key, so if every device is lost, the phrase
+// can still sign in a new device or revoke the old ones.
+
+const (
+	PAPER_KEY_LEN = 16 // bytes, and so words in the phrase
+)
+
+type PaperKey struct {
+	Phrase string
+	Key    NaclSigningKeyPair
+	Device *Device
+}
+
+func NewPaperKey() (ret *PaperKey, err error) {
+	b := make([]byte, PAPER_KEY_LEN)
+	if _, err = rand.Read(b); err != nil {
+		return
+	}
+	if ret, err = newPaperKeyFromBytes(b); err != nil {
+		return
+	}
+	ret.Device.Name = "paper key made " + time.Now().Format("2006-01-02")
+	return
+}
+
+// ParsePaperKey derives the key again from the phrase that NewPaperKey
+// made.
+func ParsePaperKey(phrase string) (ret *PaperKey, err error) {
+	var b []byte
+	if b, err = WordsToBytes(phrase); err != nil {
+		return
+	}
+	if len(b) != PAPER_KEY_LEN {
+		err = fmt.Errorf("A paper key should be %d words, not %d", PAPER_KEY_LEN, len(b))
+		return
+	}
+	return newPaperKeyFromBytes(b)
+}
+
+func paperKeyDerive(b []byte, label string) []byte {
+	mac := hmac.New(sha256.New, b)
+	mac.Write([]byte("Keybase-Paper-Key-1 " + label))
+	return mac.Sum(nil)
+}
+
+func newPaperKeyFromBytes(b []byte) (ret *PaperKey, err error) {
+	ret = &PaperKey{
+		Phrase: BytesToWords(b),
+		Device: &Device{Name: "paper key", Type: DEVICE_TYPE_PAPER},
+	}
+
+	seed := paperKeyDerive(b, "eddsa")
+	pub, priv, err := ed25519.GenerateKey(bytes.NewReader(seed))
+	if err != nil {
+		return nil, err
+	}
+	copy(ret.Key.Public[:], pub[:])
+	ret.Key.Private = &NaclSigningKeyPrivate{}
+	copy(ret.Key.Private[:], priv[:])
+
+	// The device ID comes from the phrase too, so the paper key is
+	// always the same device, wherever it's used.
+	copy(ret.Device.Id[:], paperKeyDerive(b, "device id"))
+	ret.Device.Id[DEVICE_ID_LEN-1] = DEVICE_ID_SUFFIX
+	return
+}
+
+// CheckActive checks that the paper key is one of the user's active
+// sibkeys, and so can sign for them.
+func (pk *PaperKey) CheckActive(u *User) (err error) {
+	var ckf *ComputedKeyFamily
+	if ckf, err = u.RequireComputedKeyFamily(); err != nil {
+		return
+	}
+	if _, err = ckf.FindActiveSibkey(GenericKeyToFOKID(pk.Key)); err != nil {
+		err = NoKeyError{fmt.Sprintf("That paper key isn't active for %s: %s", u.GetName(), err.Error())}
+	}
+	return
+}
+
+// ProvisionDevice makes new keys for this device, and signs them into
+// the user's sigchain with the paper key, for when there's no other
+// device to vouch for us. We need to be logged in to post them.
+func (pk *PaperKey) ProvisionDevice(me *User, deviceName string, lui LogUI) (err error) {
+	G.Log.Debug("+ PaperKey.ProvisionDevice")
+	defer func() {
+		G.Log.Debug("- PaperKey.ProvisionDevice -> %s", ErrToOk(err))
+	}()
+
+	if err = pk.CheckActive(me); err != nil {
+		return
+	}
+	var primary GenericKey
+	if primary, err = me.GetEldestKey(); err != nil {
+		return
+	}
+	var device *Device
+	if device, err = NewLocalDevice(); err != nil {
+		return
+	}
+	if len(deviceName) > 0 {
+		device.Name = deviceName
+	}
+
+	lui.Info("Generating NaCl EdDSA key (255 bits on Curve25519) for device %q", device.Name)
+	sib := NewNaclKeyGen(NaclKeyGenArg{
+		Signer:    pk.Key,
+		Primary:   primary,
+		Generator: GenerateNaclSigningKeyPair,
+		Type:      "sibkey",
+		Me:        me,
+		ExpireIn:  NACL_EDDSA_EXPIRE_IN,
+		Device:    device,
+		LogUI:     lui,
+	})
+	if err = sib.Run(); err != nil {
+		return
+	}
+
+	lui.Info("Generating NaCl DH-key (255 bits on Curve25519)")
+	dh := NewNaclKeyGen(NaclKeyGenArg{
+		Signer:    sib.GetKeyPair(),
+		Primary:   primary,
+		Generator: GenerateNaclDHKeyPair,
+		Type:      "subkey",
+		Me:        me,
+		ExpireIn:  NACL_DH_EXPIRE_IN,
+		LogUI:     lui,
+	})
+	if err = dh.Run(); err != nil {
+		return
+	}
+
+	return WriteDeviceConfig(device, sib.GetKeyPair().GetKid())
+}
+
+//=============================================================================
+
+type PaperKeyGenArg struct {
+	SecretUI SecretUI
+	LogUI    LogUI
+}
+
+// PaperKeyGenEngine makes a new paper key, and signs it into our sigchain
+// with one of our current keys. It shows the phrase once, and doesn't
+// keep it anywhere.
+type PaperKeyGenEngine struct {
+	arg *PaperKeyGenArg
+	pk  *PaperKey
+}
+
+func NewPaperKeyGenEngine(arg *PaperKeyGenArg) *PaperKeyGenEngine {
+	return &PaperKeyGenEngine{arg: arg}
+}
+
+func (e *PaperKeyGenEngine) Run() (err error) {
+	G.Log.Debug("+ PaperKeyGenEngine.Run")
+	defer func() {
+		G.Log.Debug("- PaperKeyGenEngine.Run -> %s", ErrToOk(err))
+	}()
+
+	if e.arg.LogUI == nil {
+		e.arg.LogUI = G.UI.GetLogUI()
+	}
+	if e.arg.SecretUI == nil {
+		e.arg.SecretUI = G.UI.GetSecretUI()
+	}
+
+	if err = G.Session.Load(); err != nil {
+		return
+	}
+	var me *User
+	if me, err = LoadMe(LoadUserArg{}); err != nil {
+		return
+	}
+	var primary, signer GenericKey
+	if primary, err = me.GetEldestKey(); err != nil {
+		return
+	}
+	if signer, err = G.Keyrings.GetSecretKey("paper key delegation", e.arg.SecretUI); err != nil {
+		return
+	} else if signer == nil {
+		return NoSecretKeyError{}
+	}
+
+	if e.pk, err = NewPaperKey(); err != nil {
+		return
+	}
+	pk := e.pk
+	gen := NewNaclKeyGen(NaclKeyGenArg{
+		Signer:    signer,
+		Primary:   primary,
+		Generator: func() (NaclKeyPair, error) { return pk.Key, nil },
+		Type:      "sibkey",
+		Me:        me,
+		ExpireIn:  NACL_EDDSA_EXPIRE_IN,
+		Device:    pk.Device,
+		LogUI:     e.arg.LogUI,
+	})
+	// Push, but don't Save: the paper key's secret half mustn't be kept
+	// on this device.
+	if err = gen.Generate(); err != nil {
+		return
+	}
+	if err = gen.Push(); err != nil {
+		return
+	}
+
+	e.arg.LogUI.Info("Signed in a new paper key, %s. Write down these words, and keep them safe:",
+		pk.Key.GetKid())
+	e.arg.LogUI.Info("")
+	e.arg.LogUI.Info("    %s", pk.Phrase)
+	e.arg.LogUI.Info("")
+	e.arg.LogUI.Info("They won't be shown again.")
+	return
+}
+
+func (e *PaperKeyGenEngine) GetPaperKey() *PaperKey { return e.pk }
@@ -0,0 +1,80 @@
+package libkb
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestPaperKeyFromPhrase(t *testing.T) {
+	pk, err := NewPaperKey()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if n := len(strings.Fields(pk.Phrase)); n != PAPER_KEY_LEN {
+		t.Errorf("expected %d words, got %d", PAPER_KEY_LEN, n)
+	}
+
+	// The same phrase, however it's typed, gives the same key and device.
+	pk2, err := ParsePaperKey(strings.ToUpper(pk.Phrase))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !pk2.Key.GetKid().Eq(pk.Key.GetKid()) {
+		t.Errorf("paper key KID changed: %s != %s", pk2.Key.GetKid(), pk.Key.GetKid())
+	}
+	if pk2.Device.Id != pk.Device.Id {
+		t.Errorf("paper key device ID changed")
+	}
+	if _, err = ImportDeviceId(pk.Device.Id.String()); err != nil {
+		t.Errorf("bad paper key device ID: %s", err.Error())
+	}
+
+	msg := []byte("revoke my lost laptop")
+	sig, _, err := pk.Key.SignToString(msg)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err = pk2.Key.Verify(sig, msg); err != nil {
+		t.Errorf("rederived paper key didn't verify: %s", err.Error())
+	}
+
+	words := strings.Fields(pk.Phrase)
+	if _, err = ParsePaperKey(strings.Join(words[1:], " ")); err == nil {
+		t.Errorf("expected a short phrase to fail")
+	}
+}
+
+func TestFindBestReprovisionKeyNoKeyring(t *testing.T) {
+	old := G.Keyrings
+	G.Keyrings = &Keyrings{}
+	defer func() { G.Keyrings = old }()
+
+	me, _ := newTrackTestUser(t, "max", "9f9611a4b7920637b1c2a839b2a0e100")
+	sp := &SelfProvisioner{me: me}
+	if _, err := sp.FindBestReprovisionKey(); err == nil {
+		t.Errorf("found a key with no keyring and no paper key")
+	} else if _, ok := err.(NoKeyringsError); !ok {
+		t.Errorf("expected a NoKeyringsError; got %s", err)
+	}
+
+	// Every device is lost, but we have the paper key.
+	pk, err := NewPaperKey()
+	if err != nil {
+		t.Fatal(err)
+	}
+	sp.paperKey = pk
+	if _, err := sp.FindBestReprovisionKey(); err == nil {
+		t.Errorf("used a paper key that isn't one of ours")
+	}
+
+	kid := pk.Key.GetKid().String()
+	me.keyFamily.Sibkeys[kid] = &ServerKeyRecord{Kid: kid, key: pk.Key}
+	me.sigChain.localCki.Infos[kid] = &ComputedKeyInfo{Status: KEY_LIVE, Sibkey: true}
+	key, err := sp.FindBestReprovisionKey()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !key.GetKid().Eq(pk.Key.GetKid()) {
+		t.Errorf("expected the paper key; got %s", key.GetKid())
+	}
+}
@@ -0,0 +1,212 @@
+package libkb
+
+import (
+	"encoding/hex"
+	"encoding/json"
+	"github.com/keybase/go-triplesec"
+	"github.com/keybase/protocol/go"
+)
+
+type PassphraseChangeArg struct {
+	OldPassphrase string // prompted for if empty
+	NewPassphrase string // prompted for if empty
+
+	LoginUI  LoginUI
+	SecretUI SecretUI
+	LogUI    LogUI
+}
+
+// PassphraseChangeEngine moves us to a new passphrase. Every secret
+// key locked with the old one, in the local keyring and on the server,
+// is unlocked and locked up again with the new one, and the server gets
+// a new salt and password hash along with the new key bundles, all in
+// one request. The local keyring only changes once the server has
+// taken the change.
+type PassphraseChangeEngine struct {
+	arg  *PassphraseChangeArg
+	me   *User
+	tsec *triplesec.Cipher
+}
+
+func NewPassphraseChangeEngine(arg *PassphraseChangeArg) *PassphraseChangeEngine {
+	return &PassphraseChangeEngine{arg: arg}
+}
+
+func (e *PassphraseChangeEngine) Run() (err error) {
+	G.Log.Debug("+ PassphraseChangeEngine.Run")
+	defer func() {
+		G.Log.Debug("- PassphraseChangeEngine.Run -> %s", ErrToOk(err))
+	}()
+
+	if e.arg.LogUI == nil {
+		e.arg.LogUI = G.UI.GetLogUI()
+	}
+	if e.arg.SecretUI == nil {
+		e.arg.SecretUI = G.UI.GetSecretUI()
+	}
+	if e.arg.LoginUI == nil {
+		e.arg.LoginUI = G.UI.GetLoginUI()
+	}
+
+	if err = e.getPassphrases(); err != nil {
+		return
+	}
+	if err = G.LoginState.Login(LoginArg{
+		Ui:         e.arg.LoginUI,
+		SecretUI:   e.arg.SecretUI,
+		Passphrase: e.arg.OldPassphrase,
+	}); err != nil {
+		return
+	}
+	if e.me, err = LoadMe(LoadUserArg{}); err != nil {
+		return
+	}
+
+	// Prove the old passphrase before we touch anything.
+	var hmacPwh []byte
+	var loginSession string
+	if hmacPwh, loginSession, err = G.LoginState.PassphraseProof(e.me.GetName(), e.arg.OldPassphrase); err != nil {
+		return
+	}
+
+	oldSalt := G.LoginState.salt
+	if err = G.LoginState.GenerateNewSalt(); err != nil {
+		return
+	}
+	defer func() {
+		if err != nil {
+			G.LoginState.salt = oldSalt
+			if e.tsec != nil {
+				e.tsec.Scrub()
+			}
+		}
+	}()
+	if e.tsec, err = triplesec.NewCipher([]byte(e.arg.NewPassphrase), G.LoginState.salt); err != nil {
+		return
+	}
+	var pwh []byte
+	if _, pwh, err = e.tsec.DeriveKey(SharedSecretLen); err != nil {
+		return
+	}
+
+	var staged *P3SKBStagedKeyring
+	if staged, err = e.stageKeyring(); err != nil {
+		return
+	}
+	if staged != nil {
+		defer func() {
+			if err != nil {
+				staged.Abort()
+			}
+		}()
+	}
+
+	if err = G.SecretSyncer.Load(e.me.id); err != nil {
+		return
+	}
+	var keys ServerPrivateKeyMap
+	if keys, err = G.SecretSyncer.Reencrypt(e.arg.OldPassphrase, e.tsec); err != nil {
+		return
+	}
+
+	if err = e.post(pwh, hmacPwh, loginSession, keys); err != nil {
+		return
+	}
+
+	// The server has the new passphrase now, so from here on, we can only
+	// warn about what goes wrong locally.
+	if staged != nil {
+		if e2 := staged.Commit(); e2 != nil {
+			e.arg.LogUI.Warning("Failed to update the local keyring: %s", e2.Error())
+		}
+	}
+	if e2 := G.SecretSyncer.Replace(keys); e2 != nil {
+		e.arg.LogUI.Warning("Failed to store the new server key bundles: %s", e2.Error())
+	}
+	if e2 := G.LoginState.SetNewPassphrase(e.tsec); e2 != nil {
+		e.arg.LogUI.Warning("Failed to save the new salt: %s", e2.Error())
+	}
+	e.arg.LogUI.Info("Passphrase changed")
+	return
+}
+
+func (e *PassphraseChangeEngine) getPassphrases() (err error) {
+	if len(e.arg.OldPassphrase) == 0 {
+		e.arg.OldPassphrase, err = e.arg.SecretUI.GetKeybasePassphrase(keybase_1.GetKeybasePassphraseArg{
+			Username: G.Env.GetUsername(),
+		})
+		if err != nil {
+			return
+		}
+	}
+	if len(e.arg.NewPassphrase) == 0 {
+		e.arg.NewPassphrase, err = e.arg.SecretUI.GetNewPassphrase(keybase_1.GetNewPassphraseArg{
+			TerminalPrompt: "Your new passphrase",
+			PinentryDesc:   "Please pick a new passphrase for Keybase (12+ characters)",
+			PinentryPrompt: "New passphrase",
+		})
+		if err != nil {
+			return
+		}
+	}
+	if !CheckPassphraseNew.F(e.arg.NewPassphrase) {
+		err = PassphraseError{CheckPassphraseNew.Hint}
+	}
+	return
+}
+
+// stageKeyring re-encrypts our local secret keyring, and writes it out
+// beside the current one, ready to swap in. Keys locked with some other
+// passphrase are carried over as they are.
+func (e *PassphraseChangeEngine) stageKeyring() (ret *P3SKBStagedKeyring, err error) {
+	ring := G.Keyrings.P3SKB
+	if ring == nil || len(ring.Blocks) == 0 {
+		return
+	}
+	blocks := make([]*P3SKB, 0, len(ring.Blocks))
+	for _, b := range ring.Blocks {
+		var nb *P3SKB
+		if nb, err = b.Reencrypt(e.arg.OldPassphrase, e.tsec); err == nil {
+			blocks = append(blocks, nb)
+		} else if _, ok := err.(PassphraseError); ok {
+			e.arg.LogUI.Warning("Skipping a key locked with another passphrase")
+			blocks = append(blocks, b)
+			err = nil
+		} else {
+			return
+		}
+	}
+	return ring.Stage(blocks)
+}
+
+func (e *PassphraseChangeEngine) post(pwh, hmacPwh []byte, loginSession string,
+	keys ServerPrivateKeyMap) (err error) {
+
+	bundles := make(map[string]string)
+	for kid, key := range keys {
+		bundles[kid] = key.Bundle
+	}
+	var tmp []byte
+	if tmp, err = json.Marshal(bundles); err != nil {
+		return
+	}
+
+	var res *ApiRes
+	res, err = G.API.Post(ApiArg{
+		Endpoint:    "passphrase/change",
+		NeedSession: true,
+		Args: HttpArgs{
+			"salt":          S{hex.EncodeToString(G.LoginState.salt)},
+			"pwh":           S{hex.EncodeToString(pwh)},
+			"pwh_version":   I{int(triplesec.Version)},
+			"hmac_pwh":      S{hex.EncodeToString(hmacPwh)},
+			"login_session": S{loginSession},
+			"private_keys":  S{string(tmp)},
+		},
+		AppStatus: []string{"OK", "BAD_LOGIN_PASSWORD"},
+	})
+	if err == nil && res.AppStatus == "BAD_LOGIN_PASSWORD" {
+		err = PassphraseError{"server rejected the old passphrase"}
+	}
+	return
+}
@@ -0,0 +1,146 @@
+package libkb
+
+import (
+	"fmt"
+	"github.com/keybase/protocol/go"
+	"io"
+)
+
+type PgpExportArg struct {
+	Username string // whose public key to export; defaults to me
+	Secret   bool   // export my secret key too
+
+	// Encrypt protects an exported secret key with a new passphrase.
+	Encrypt bool
+
+	Sink io.Writer // nil to skip writing out the key
+	Gpg  bool      // also import the key into the local GnuPG keyring
+
+	SecretUI SecretUI
+	LogUI    LogUI
+}
+
+// PgpExportEngine gets PGP keys back out of the Keybase keyring, in a
+// format that other tools understand.
+type PgpExportEngine struct {
+	arg *PgpExportArg
+	key *PgpKeyBundle
+}
+
+func NewPgpExportEngine(arg *PgpExportArg) *PgpExportEngine {
+	return &PgpExportEngine{arg: arg}
+}
+
+func (e *PgpExportEngine) loadPublic() (err error) {
+	var u *User
+	if len(e.arg.Username) > 0 {
+		u, err = LoadUser(LoadUserArg{Name: e.arg.Username})
+	} else {
+		u, err = LoadMe(LoadUserArg{})
+	}
+	if err != nil {
+		return
+	}
+
+	keys := u.GetActivePgpKeys(true)
+	if len(keys) == 0 {
+		return NoKeyError{fmt.Sprintf("%s has no active PGP keys", u.GetName())}
+	}
+	e.key = keys[0]
+
+	// If we've selected one of our keys by fingerprint, use that one.
+	if fp := G.Env.GetPgpFingerprint(); fp != nil && len(e.arg.Username) == 0 {
+		for _, k := range keys {
+			if k.GetFingerprint().Eq(*fp) {
+				e.key = k
+			}
+		}
+	}
+	return
+}
+
+func (e *PgpExportEngine) loadSecret() (err error) {
+	var p3skb *P3SKB
+	var which string
+	var key GenericKey
+	var ok bool
+
+	if p3skb, which, err = G.Keyrings.GetSecretKeyLocked(); err != nil {
+		return
+	}
+	if key, err = p3skb.PromptAndUnlock("export of your secret key", which, e.arg.SecretUI); err != nil {
+		return
+	}
+	if e.key, ok = key.(*PgpKeyBundle); !ok {
+		err = BadKeyError{"Your secret key isn't a PGP key"}
+	}
+	return
+}
+
+func (e *PgpExportEngine) writeSecret() (err error) {
+	if !e.arg.Encrypt {
+		return e.key.EncodeSecretToStream(noOpCloser{e.arg.Sink})
+	}
+
+	var pp string
+	pp, err = e.arg.SecretUI.GetNewPassphrase(keybase_1.GetNewPassphraseArg{
+		TerminalPrompt: "A passphrase to protect the exported key",
+		PinentryDesc:   "Please pick a passphrase to protect the exported copy of your key (12+ characters)",
+		PinentryPrompt: "Export passphrase",
+	})
+	if err != nil {
+		return
+	}
+
+	return e.key.EncodeEncryptedSecretToStream(noOpCloser{e.arg.Sink}, []byte(pp))
+}
+
+func (e *PgpExportEngine) Run() (err error) {
+	G.Log.Debug("+ PgpExportEngine.Run")
+	defer func() {
+		G.Log.Debug("- PgpExportEngine.Run -> %s", ErrToOk(err))
+	}()
+
+	if e.arg.LogUI == nil {
+		e.arg.LogUI = G.UI.GetLogUI()
+	}
+	if e.arg.SecretUI == nil {
+		e.arg.SecretUI = G.UI.GetSecretUI()
+	}
+	if e.arg.Secret && len(e.arg.Username) > 0 {
+		return fmt.Errorf("Can only export your own secret key")
+	}
+
+	if e.arg.Secret {
+		err = e.loadSecret()
+	} else {
+		err = e.loadPublic()
+	}
+	if err != nil {
+		return
+	}
+	e.arg.LogUI.Info("Exporting key %s", e.key.GetFingerprint().ToQuads())
+
+	if e.arg.Sink != nil {
+		if e.arg.Secret {
+			err = e.writeSecret()
+		} else {
+			err = e.key.EncodeToStream(noOpCloser{e.arg.Sink})
+		}
+		if err != nil {
+			return
+		}
+	}
+
+	if e.arg.Gpg {
+		gpg := G.GetGpgClient()
+		if _, err = gpg.Configure(); err != nil {
+			return
+		}
+		if err = gpg.ExportKey(*e.key, e.arg.Secret); err != nil {
+			return
+		}
+		e.arg.LogUI.Info("Imported key into GnuPG")
+	}
+	return
+}
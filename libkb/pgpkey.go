@@ -2,6 +2,13 @@ package libkb
 
 import (
 	"bytes"
+	"crypto"
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/dsa"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/sha1"
 	"crypto/sha256"
 	"encoding/hex"
 	"fmt"
@@ -9,11 +16,15 @@ import (
 	"github.com/keybase/protocol/go"
 	"golang.org/x/crypto/openpgp"
 	"golang.org/x/crypto/openpgp/armor"
+	"golang.org/x/crypto/openpgp/elgamal"
 	"golang.org/x/crypto/openpgp/packet"
+	"golang.org/x/crypto/openpgp/s2k"
 	"golang.org/x/crypto/sha3"
 	"io"
+	"math/big"
 	"regexp"
 	"strings"
+	"time"
 )
 
 type PgpKeyBundle openpgp.Entity
@@ -157,6 +168,26 @@ func (k PgpKeyBundle) DecryptionKeys() []openpgp.Key {
 	return k.toList().DecryptionKeys()
 }
 
+// CanEncrypt returns true if this bundle has a live key that a message
+// can be encrypted for.  It follows the rules in openpgp's
+// (*Entity).encryptionKey, which isn't exported.
+func (k PgpKeyBundle) CanEncrypt() bool {
+	now := time.Now()
+	for _, subkey := range k.Subkeys {
+		if subkey.Sig.FlagsValid &&
+			subkey.Sig.FlagEncryptCommunications &&
+			subkey.PublicKey.PubKeyAlgo.CanEncrypt() &&
+			!subkey.Sig.KeyExpired(now) {
+			return true
+		}
+	}
+	i := getPrimaryIdentity((*openpgp.Entity)(&k))
+	return i != nil &&
+		(!i.SelfSignature.FlagsValid || i.SelfSignature.FlagEncryptCommunications) &&
+		k.PrimaryKey.PubKeyAlgo.CanEncrypt() &&
+		!i.SelfSignature.KeyExpired(now)
+}
+
 func (k PgpKeyBundle) MatchesKey(key *openpgp.Key) bool {
 	return FastByteArrayEq(k.PrimaryKey.Fingerprint[:],
 		key.Entity.PrimaryKey.Fingerprint[:])
@@ -217,6 +248,177 @@ func ReadOneKeyFromBytes(b []byte) (*PgpKeyBundle, error) {
 	return finishReadOne(el, err)
 }
 
+// EncodeSecretToStream writes out the key with its secret half, armored.
+// The secret key material must already be unlocked, and is written out
+// unprotected.
+func (k *PgpKeyBundle) EncodeSecretToStream(wc io.WriteCloser) (err error) {
+	if err = k.CheckSecretKey(); err != nil {
+		return
+	}
+	var writer io.WriteCloser
+	if writer, err = armor.Encode(wc, "PGP PRIVATE KEY BLOCK", make(map[string]string)); err != nil {
+		return
+	}
+	if err = ((*openpgp.Entity)(k)).SerializePrivate(writer, nil); err != nil {
+		return
+	}
+	return writer.Close()
+}
+
+// EncodeEncryptedSecretToStream is like EncodeSecretToStream, but locks
+// the secret key material with the given passphrase first, so the result
+// is an ordinary passphrase-protected key block, as gpg would export.
+func (k *PgpKeyBundle) EncodeEncryptedSecretToStream(wc io.WriteCloser, passphrase []byte) (err error) {
+	if err = k.CheckSecretKey(); err != nil {
+		return
+	}
+	var writer io.WriteCloser
+	if writer, err = armor.Encode(wc, "PGP PRIVATE KEY BLOCK", make(map[string]string)); err != nil {
+		return
+	}
+	if err = serializeEncryptedPrivateKey(writer, k.PrivateKey, passphrase); err != nil {
+		return
+	}
+	// The rest is as in openpgp.Entity.SerializePrivate, which re-signs
+	// the user IDs and subkeys with the (unlocked) primary key.
+	for _, ident := range k.Identities {
+		if err = ident.UserId.Serialize(writer); err != nil {
+			return
+		}
+		if err = ident.SelfSignature.SignUserId(ident.UserId.Id, k.PrimaryKey, k.PrivateKey, nil); err != nil {
+			return
+		}
+		if err = ident.SelfSignature.Serialize(writer); err != nil {
+			return
+		}
+	}
+	for _, subkey := range k.Subkeys {
+		if err = serializeEncryptedPrivateKey(writer, subkey.PrivateKey, passphrase); err != nil {
+			return
+		}
+		if err = subkey.Sig.SignKey(subkey.PublicKey, k.PrivateKey, nil); err != nil {
+			return
+		}
+		if err = subkey.Sig.Serialize(writer); err != nil {
+			return
+		}
+	}
+	return writer.Close()
+}
+
+// serializeEncryptedPrivateKey writes out a secret key packet, with its
+// secret MPIs encrypted under the passphrase, per RFC 4880, section 5.5.3:
+// S2K usage 254 (a SHA-1 checksum), AES-256, and an iterated and salted
+// SHA-256 S2K. Our openpgp library can read these, but only writes
+// unencrypted ones, so we start from one of those.
+func serializeEncryptedPrivateKey(w io.Writer, pk *packet.PrivateKey, passphrase []byte) (err error) {
+	var plain, pub bytes.Buffer
+	if err = pk.Serialize(&plain); err != nil {
+		return
+	}
+	if err = pk.PublicKey.Serialize(&pub); err != nil {
+		return
+	}
+
+	// The unencrypted packet is the public key packet, an S2K usage of 0,
+	// the secret MPIs, and a 2-byte checksum of them.
+	var body, pubBody []byte
+	if body, err = pgpPacketBody(plain.Bytes()); err != nil {
+		return
+	}
+	if pubBody, err = pgpPacketBody(pub.Bytes()); err != nil {
+		return
+	}
+	if len(body) < len(pubBody)+3 || body[len(pubBody)] != 0 {
+		return BadKeyError{"unexpected secret key packet"}
+	}
+	mpis := body[len(pubBody)+1 : len(body)-2]
+	sum := sha1.Sum(mpis)
+	secret := append(append([]byte{}, mpis...), sum[:]...)
+
+	cf := packet.CipherAES256
+	var out bytes.Buffer
+	out.Write(pubBody)
+	out.WriteByte(254)
+	out.WriteByte(byte(cf))
+	key := make([]byte, cf.KeySize())
+	if err = s2k.Serialize(&out, key, rand.Reader, passphrase, &s2k.Config{Hash: crypto.SHA256}); err != nil {
+		return
+	}
+	iv := make([]byte, aes.BlockSize)
+	if _, err = rand.Read(iv); err != nil {
+		return
+	}
+	out.Write(iv)
+	var block cipher.Block
+	if block, err = aes.NewCipher(key); err != nil {
+		return
+	}
+	cipher.NewCFBEncrypter(block, iv).XORKeyStream(secret, secret)
+	out.Write(secret)
+
+	tag := byte(5) // secret key
+	if pk.IsSubkey {
+		tag = 7 // secret subkey
+	}
+	if err = writePgpPacketHeader(w, tag, out.Len()); err != nil {
+		return
+	}
+	_, err = w.Write(out.Bytes())
+	return
+}
+
+// pgpPacketBody strips the new-format header, as written by our openpgp
+// library, off of a serialized packet.
+func pgpPacketBody(p []byte) ([]byte, error) {
+	n := 0
+	if len(p) >= 2 && p[0]&0xc0 == 0xc0 {
+		switch l := p[1]; {
+		case l < 192:
+			n = 2
+		case l < 224:
+			n = 3
+		case l == 255:
+			n = 6
+		}
+	}
+	if n == 0 || len(p) < n {
+		return nil, BadKeyError{"unexpected PGP packet header"}
+	}
+	return p[n:], nil
+}
+
+// writePgpPacketHeader writes a new-format packet header, per RFC 4880,
+// section 4.2.2.
+func writePgpPacketHeader(w io.Writer, tag byte, length int) (err error) {
+	hdr := []byte{0xc0 | tag}
+	switch {
+	case length < 192:
+		hdr = append(hdr, byte(length))
+	case length < 8384:
+		length -= 192
+		hdr = append(hdr, 192+byte(length>>8), byte(length))
+	default:
+		hdr = append(hdr, 255, byte(length>>24), byte(length>>16), byte(length>>8), byte(length))
+	}
+	_, err = w.Write(hdr)
+	return
+}
+
+// ReadOneSecretKey reads a single PGP key with its secret half, either
+// armored or binary, as when importing a key exported from elsewhere.
+func ReadOneSecretKey(b []byte) (ret *PgpKeyBundle, err error) {
+	if bytes.HasPrefix(bytes.TrimSpace(b), []byte("-----BEGIN")) {
+		ret, err = ReadOneKeyFromString(string(b))
+	} else {
+		ret, err = ReadOneKeyFromBytes(b)
+	}
+	if err == nil && ret.PrivateKey == nil {
+		ret, err = nil, NoSecretKeyError{}
+	}
+	return
+}
+
 func GetOneKey(jw *jsonw.Wrapper) (*PgpKeyBundle, error) {
 	s, err := jw.GetString()
 	if err != nil {
@@ -275,6 +477,36 @@ func (k *PgpKeyBundle) CheckSecretKey() (err error) {
 	return
 }
 
+// checkSecretKeysMatch checks that each of the bundle's unlocked secret
+// keys is the private half of the public key it came with, by deriving
+// the public key again from the secret one.
+func (k *PgpKeyBundle) checkSecretKeysMatch() (err error) {
+	if err = k.CheckSecretKey(); err != nil {
+		return
+	}
+	privs := []*packet.PrivateKey{k.PrivateKey}
+	for _, subkey := range k.Subkeys {
+		if subkey.PrivateKey != nil && !subkey.PrivateKey.Encrypted {
+			privs = append(privs, subkey.PrivateKey)
+		}
+	}
+	for _, priv := range privs {
+		var ok bool
+		switch sk := priv.PrivateKey.(type) {
+		case *rsa.PrivateKey:
+			ok = sk.Validate() == nil
+		case *dsa.PrivateKey:
+			ok = new(big.Int).Exp(sk.G, sk.X, sk.P).Cmp(sk.Y) == 0
+		case *elgamal.PrivateKey:
+			ok = new(big.Int).Exp(sk.G, sk.X, sk.P).Cmp(sk.Y) == 0
+		}
+		if !ok {
+			return BadKeyError{fmt.Sprintf("secret key doesn't match public key %X", priv.Fingerprint)}
+		}
+	}
+	return
+}
+
 func (k *PgpKeyBundle) GetKid() KID {
 
 	prefix := []byte{
@@ -353,7 +585,9 @@ func (k PgpKeyBundle) KeyDescription() string {
 	return desc
 }
 
-func (p *PgpKeyBundle) Unlock(reason string) error {
+// Unlock decrypts the key, and its subkeys, with a passphrase it asks
+// secretUI for, or the default SecretUI if that's nil.
+func (p *PgpKeyBundle) Unlock(reason string, secretUI SecretUI) error {
 	if !p.PrivateKey.Encrypted {
 		return nil
 	}
@@ -384,6 +618,7 @@ func (p *PgpKeyBundle) Unlock(reason string) error {
 		Reason:   reason,
 		KeyDesc:  p.VerboseDescription(),
 		Unlocker: unlocker,
+		Ui:       secretUI,
 	}.Run()
 	return err
 }
@@ -133,3 +133,28 @@ func CheckPosted(proofId string) (found bool, status int, err error) {
 	res.Body.AtPath("proof_res.status").GetIntVoid(&status, &err)
 	return
 }
+
+type PostSigArg struct {
+	Sig        string
+	Id         SigId
+	Type       string
+	SigningKey GenericKey
+}
+
+// PostSig posts a signature that only goes into our sigchain, like a
+// revocation or a cryptocurrency address, and isn't a remote proof.
+func PostSig(arg PostSigArg) (err error) {
+	_, err = G.API.Post(ApiArg{
+		Endpoint:    "sig/post",
+		NeedSession: true,
+		Args: HttpArgs{
+			"sig_id_base":     S{arg.Id.ToString(false)},
+			"sig_id_short":    S{arg.Id.ToShortId()},
+			"sig":             S{arg.Sig},
+			"is_remote_proof": B{false},
+			"signing_kid":     S{arg.SigningKey.GetKid().String()},
+			"type":            S{arg.Type},
+		},
+	})
+	return
+}
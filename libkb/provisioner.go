@@ -5,6 +5,14 @@ package libkb
 type SelfProvisioner struct {
 	me        *User
 	secretKey *P3SKB
+	paperKey  *PaperKey
+}
+
+// UsePaperKey lets FindBestReprovisionKey fall back to the paper key
+// with the given phrase, if none of our local keys will do.
+func (sp *SelfProvisioner) UsePaperKey(phrase string) (err error) {
+	sp.paperKey, err = ParsePaperKey(phrase)
+	return
 }
 
 func (sp *SelfProvisioner) LoadMe() (err error) {
@@ -29,6 +37,7 @@ func (sp *SelfProvisioner) CheckKeyProvisioned() (err error) {
 // FindBestReprovisionKey finds the best key to use for reprovisioning a device
 // if the user's config file was corrupted.  It will look at all active sibkeys,
 // and all locally stored and available secret keys, and pick one to use.
+// Failing that, it will use our paper key, if we were given one.
 func (sp *SelfProvisioner) FindBestReprovisionKey() (ret GenericKey, err error) {
 	if sp.me == nil {
 		err = InternalError{"no user loaded"}
@@ -42,20 +51,29 @@ func (sp *SelfProvisioner) FindBestReprovisionKey() (ret GenericKey, err error)
 	}
 
 	ring := G.Keyrings.P3SKB
-	if ring == nil {
+	if ring == nil && sp.paperKey == nil {
 		err = NoKeyringsError{}
 		return
 	}
 
-	for i := len(ring.Blocks) - 1; i >= 0; i-- {
-		if block := ring.Blocks[i]; block == nil {
-			continue
-		} else if key, e2 := block.GetPubKey(); key == nil || e2 != nil {
-			continue
-		} else if key2, e2 := ckf.FindActiveSibkey(GenericKeyToFOKID(key)); key2 != nil && e2 == nil {
-			ret = key
-			return
+	if ring != nil {
+		for i := len(ring.Blocks) - 1; i >= 0; i-- {
+			if block := ring.Blocks[i]; block == nil {
+				continue
+			} else if key, e2 := block.GetPubKey(); key == nil || e2 != nil {
+				continue
+			} else if key2, e2 := ckf.FindActiveSibkey(GenericKeyToFOKID(key)); key2 != nil && e2 == nil {
+				ret = key
+				return
+			}
+		}
+	}
+
+	if sp.paperKey != nil {
+		if err = sp.paperKey.CheckActive(sp.me); err == nil {
+			ret = sp.paperKey.Key
 		}
+		return
 	}
 
 	err = NoSecretKeyError{}
@@ -63,12 +81,16 @@ func (sp *SelfProvisioner) FindBestReprovisionKey() (ret GenericKey, err error)
 }
 
 // Reprovision fixes a corruption in the user's setup by reprovisioning this user's
-// stored private key
+// stored private key. If the best we have is the paper key, which we
+// don't keep on the device, it signs in new keys for this device instead.
 func (sp *SelfProvisioner) ReprovisionKey() (err error) {
 	var key GenericKey
 	if key, err = sp.FindBestReprovisionKey(); err != nil {
 		return
 	}
+	if sp.paperKey != nil && key.GetKid().Eq(sp.paperKey.Key.GetKid()) {
+		return sp.paperKey.ProvisionDevice(sp.me, "", G.Log)
+	}
 	kid := key.GetKid()
 	G.Log.Info("Setting per-device KID to %s", kid)
 	return G.Env.GetConfigWriter().SetPerDeviceKID(kid)
@@ -0,0 +1,151 @@
+package libkb
+
+import (
+	"fmt"
+	"github.com/keybase/go-jsonw"
+)
+
+type RevokeArg struct {
+	Kids []KID   // keys to revoke
+	Sigs []SigId // signatures to revoke, such as key delegations
+
+	// PaperKey, if given, signs the revocation, say if the devices that
+	// hold our other keys are lost.
+	PaperKey *PaperKey
+
+	SecretUI SecretUI
+	LogUI    LogUI
+}
+
+// RevokeEngine signs and posts a `revoke` link to our sigchain, which
+// retires the given keys or signatures, say if a device is lost.
+type RevokeEngine struct {
+	arg *RevokeArg
+	me  *User
+	ckf *ComputedKeyFamily
+}
+
+func NewRevokeEngine(arg *RevokeArg) *RevokeEngine {
+	return &RevokeEngine{arg: arg}
+}
+
+// isTarget says whether we're revoking the given key, either by its KID
+// or by one of the signatures that delegated it.
+func (e *RevokeEngine) isTarget(kid KID) bool {
+	for _, k := range e.arg.Kids {
+		if k.Eq(kid) {
+			return true
+		}
+	}
+	if info := e.ckf.cki.Infos[kid.String()]; info != nil {
+		for _, s := range e.arg.Sigs {
+			if e.ckf.cki.Sigs[s.ToString(true)] == info {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+func (e *RevokeEngine) checkTargets() (err error) {
+	for _, kid := range e.arg.Kids {
+		kid_s := kid.String()
+		if info := e.ckf.cki.Infos[kid_s]; info == nil {
+			return NoKeyError{fmt.Sprintf("The key '%s' isn't one of yours", kid_s)}
+		} else if info.Status != KEY_LIVE {
+			return KeyRevokedError{fmt.Sprintf("The key '%s' is already revoked", kid_s)}
+		}
+	}
+	return
+}
+
+// getSigningKey picks an active sibkey to sign the revocation: the paper
+// key if we were given one, then this device's key if we have one, and
+// otherwise our primary secret key. We can't sign with a key we're
+// revoking, or whose delegation we're revoking, since the revocation has
+// to verify against the key family that results from it.
+func (e *RevokeEngine) getSigningKey() (key GenericKey, err error) {
+	reason := "revocation of keys or signatures"
+	if e.arg.PaperKey != nil {
+		key = e.arg.PaperKey.Key
+	} else if kid := G.Env.GetPerDeviceKID(); kid != nil && !e.isTarget(kid) {
+		var dkey NaclSigningKeyPair
+		if dkey, err = GetDeviceSigningKey(reason, e.arg.SecretUI); err == nil {
+			key = dkey
+		} else {
+			G.Log.Debug("| Can't use device key: %s", err.Error())
+		}
+	}
+	if key == nil {
+		if key, err = G.Keyrings.GetSecretKey(reason, e.arg.SecretUI); err != nil {
+			return
+		} else if key == nil {
+			err = NoSecretKeyError{}
+			return
+		}
+	}
+	if e.isTarget(key.GetKid()) {
+		err = BadRevocationError{"Can't revoke the key that signs the revocation"}
+	} else {
+		_, err = e.ckf.FindActiveSibkey(GenericKeyToFOKID(key))
+	}
+	return
+}
+
+func (e *RevokeEngine) Run() (err error) {
+	G.Log.Debug("+ RevokeEngine.Run")
+	defer func() {
+		G.Log.Debug("- RevokeEngine.Run -> %s", ErrToOk(err))
+	}()
+
+	if e.arg.LogUI == nil {
+		e.arg.LogUI = G.UI.GetLogUI()
+	}
+	if len(e.arg.Kids) == 0 && len(e.arg.Sigs) == 0 {
+		return BadRevocationError{"Nothing to revoke"}
+	}
+
+	if err = G.Session.Load(); err != nil {
+		return
+	}
+	if e.me, err = LoadMe(LoadUserArg{}); err != nil {
+		return
+	}
+	if e.ckf = e.me.GetComputedKeyFamily(); e.ckf == nil {
+		return NoKeyError{"You have no active keys"}
+	}
+	if err = e.checkTargets(); err != nil {
+		return
+	}
+
+	var signingKey GenericKey
+	if signingKey, err = e.getSigningKey(); err != nil {
+		return
+	}
+
+	var jw *jsonw.Wrapper
+	if jw, err = e.me.RevokeKeysProof(signingKey, e.arg.Kids, e.arg.Sigs); err != nil {
+		return
+	}
+	var sig string
+	var id *SigId
+	var lid LinkId
+	if sig, id, lid, err = SignJson(jw, signingKey); err != nil {
+		return
+	}
+	if err = PostSig(PostSigArg{Sig: sig, Id: *id, Type: "revoke", SigningKey: signingKey}); err != nil {
+		return
+	}
+	e.me.sigChain.Bump(MerkleTriple{linkId: lid, sigId: id})
+
+	if err = e.ckf.LocalRevoke(e.arg.Kids, e.arg.Sigs); err != nil {
+		return
+	}
+	for _, kid := range e.arg.Kids {
+		e.arg.LogUI.Info("Revoked key %s", kid)
+	}
+	for _, sig := range e.arg.Sigs {
+		e.arg.LogUI.Info("Revoked signature %s", sig.ToString(true))
+	}
+	return
+}
@@ -0,0 +1,128 @@
+package libkb
+
+import (
+	"github.com/keybase/go-jsonw"
+	"testing"
+	"time"
+)
+
+func TestLocalRevoke(t *testing.T) {
+	kid := KID{byte(KID_NACL_EDDSA), 0x01, 0x02, 0x03}
+	other := KID{byte(KID_NACL_EDDSA), 0x04, 0x05, 0x06}
+	sigid := SigId{0x07}
+
+	info := &ComputedKeyInfo{Status: KEY_LIVE, Sibkey: true, Delegations: make(map[string]string)}
+	info.Delegations[sigid.ToString(true)] = other.String()
+	otherInfo := &ComputedKeyInfo{Status: KEY_LIVE, Sibkey: true, Delegations: make(map[string]string)}
+
+	ckf := ComputedKeyFamily{cki: &ComputedKeyInfos{
+		Infos: map[string]*ComputedKeyInfo{kid.String(): info, other.String(): otherInfo},
+		Sigs:  map[string]*ComputedKeyInfo{sigid.ToString(true): info},
+	}}
+
+	if err := ckf.LocalRevoke(nil, []SigId{sigid}); err != nil {
+		t.Fatal(err)
+	}
+	if info.Status != KEY_REVOKED || info.RevokedAt == nil {
+		t.Errorf("key delegated by a revoked sig is still live")
+	}
+	if otherInfo.Status != KEY_LIVE {
+		t.Errorf("revoked the wrong key")
+	}
+
+	if err := ckf.LocalRevoke([]KID{other}, nil); err != nil {
+		t.Fatal(err)
+	}
+	if otherInfo.Status != KEY_REVOKED {
+		t.Errorf("revoked key is still live")
+	}
+}
+
+func fakeReplayLink(seqno int, ctime int64, typ string, body *jsonw.Wrapper) *ChainLink {
+	body.SetKey("type", jsonw.NewString(typ))
+	payload := jsonw.NewDictionary()
+	payload.SetKey("body", body)
+	return &ChainLink{
+		payloadJson: payload,
+		unpacked:    &ChainLinkUnpacked{seqno: Seqno(seqno), ctime: ctime, typ: typ},
+	}
+}
+
+func TestReplayTo(t *testing.T) {
+	kid := KID{byte(KID_NACL_EDDSA), 0x01, 0x02, 0x03}
+	kf := &KeyFamily{eldest: &FOKID{Kid: kid}}
+	ckf := ComputedKeyFamily{kf: kf, cki: kf.NewComputedKeyInfos()}
+
+	announcement := jsonw.NewDictionary()
+	announcement.SetKey("message", jsonw.NewString("hello"))
+	body1 := jsonw.NewDictionary()
+	body1.SetKey("announcement", announcement)
+
+	revoke := jsonw.NewDictionary()
+	revoke.SetKey("kid", jsonw.NewString(kid.String()))
+	body2 := jsonw.NewDictionary()
+	body2.SetKey("revoke", revoke)
+
+	links := []*ChainLink{
+		fakeReplayLink(1, 1000, "announcement", body1),
+		fakeReplayLink(2, 2000, "revoke", body2),
+	}
+
+	tests := []struct {
+		q      KeyStateQuery
+		active bool
+	}{
+		{KeyStateQuery{Seqno: 1}, true},
+		{KeyStateQuery{Seqno: 2}, false},
+		{KeyStateQuery{Time: time.Unix(1500, 0)}, true},
+		{KeyStateQuery{Time: time.Unix(2000, 0)}, false},
+		{KeyStateQuery{}, false},
+	}
+	for _, test := range tests {
+		hist, err := ckf.ReplayTo(links, test.q)
+		if err != nil {
+			t.Fatalf("%s: %s", test.q, err)
+		}
+		if active := (hist.IsKidActive(kid) == DLG_SIBKEY); active != test.active {
+			t.Errorf("%s: expected active=%v, got %v", test.q, test.active, active)
+		}
+	}
+}
+
+func TestRevokeSigningKeyNotTarget(t *testing.T) {
+	paper := genCacheKey(t)
+	kid := paper.GetKid()
+	other := KID{byte(KID_NACL_EDDSA), 0x04, 0x05, 0x06}
+	sigid, otherSigid := SigId{0x07}, SigId{0x08}
+
+	info := &ComputedKeyInfo{Status: KEY_LIVE, Sibkey: true, Delegations: make(map[string]string)}
+	info.Delegations[sigid.ToString(true)] = other.String()
+	otherInfo := &ComputedKeyInfo{Status: KEY_LIVE, Sibkey: true, Delegations: make(map[string]string)}
+	otherInfo.Delegations[otherSigid.ToString(true)] = kid.String()
+	ckf := &ComputedKeyFamily{cki: &ComputedKeyInfos{
+		Infos: map[string]*ComputedKeyInfo{kid.String(): info, other.String(): otherInfo},
+		Sigs:  map[string]*ComputedKeyInfo{sigid.ToString(true): info, otherSigid.ToString(true): otherInfo},
+	}}
+
+	for _, arg := range []*RevokeArg{{Kids: []KID{kid}}, {Sigs: []SigId{sigid}}} {
+		arg.PaperKey = &PaperKey{Key: paper}
+		e := &RevokeEngine{arg: arg, ckf: ckf}
+		if !e.isTarget(kid) {
+			t.Errorf("%+v: paper key isn't a target", arg)
+		}
+		if e.isTarget(other) {
+			t.Errorf("%+v: other key is a target", arg)
+		}
+		if _, err := e.getSigningKey(); err == nil {
+			t.Errorf("%+v: signing the revocation with the key it revokes", arg)
+		} else if _, ok := err.(BadRevocationError); !ok {
+			t.Errorf("%+v: unexpected error: %s", arg, err)
+		}
+	}
+
+	// Revoking the other key's delegation is fine.
+	e := &RevokeEngine{arg: &RevokeArg{Sigs: []SigId{otherSigid}, PaperKey: &PaperKey{Key: paper}}, ckf: ckf}
+	if e.isTarget(kid) || !e.isTarget(other) {
+		t.Errorf("revoking the other key's delegation targets the wrong key")
+	}
+}
@@ -358,6 +358,7 @@ func ImportWarnings(v []string) Warnings {
 func (c CryptocurrencyChainLink) Export() (ret keybase_1.Cryptocurrency) {
 	ret.Pkhash = c.pkhash
 	ret.Address = c.address
+	ret.Type = c.typ
 	return
 }
 
@@ -0,0 +1,189 @@
+package libkb
+
+import (
+	"sync"
+	"time"
+)
+
+// SecretKeyCache is somewhere to keep unlocked secret keys between
+// uses, so that a burst of signatures only prompts for the passphrase
+// once. GetSecretKey returns nil if the key isn't there.
+type SecretKeyCache interface {
+	GetSecretKey(kid KID) (GenericKey, error)
+	PutSecretKey(key GenericKey) error
+}
+
+// SecretCache holds unlocked secret keys in the daemon, like gpg-agent
+// does. Keys are dropped, and their secrets zeroed, once they've gone
+// unused for the idle timeout, or once they've been held for the max
+// TTL, whichever comes first, or when the cache is locked.
+//
+// The cache keeps each key as an unencrypted P3SKB of its own, and
+// unpacks a fresh copy for every caller, so that dropping a key never
+// pulls it out from under an engine that's still using it.
+type SecretCache struct {
+	sync.Mutex
+	idle    time.Duration
+	maxTTL  time.Duration
+	entries map[string]*secretCacheEntry
+}
+
+type secretCacheEntry struct {
+	p3skb   *P3SKB
+	used    time.Time
+	expires time.Time // the hard deadline, from maxTTL
+	timer   *time.Timer
+}
+
+func NewSecretCache(idle, maxTTL time.Duration) *SecretCache {
+	return &SecretCache{
+		idle:    idle,
+		maxTTL:  maxTTL,
+		entries: make(map[string]*secretCacheEntry),
+	}
+}
+
+func (c *SecretCache) enabled() bool {
+	return c != nil && c.idle > 0 && c.maxTTL > 0
+}
+
+// left is how long the entry has until it times out.
+func (c *SecretCache) left(e *secretCacheEntry) time.Duration {
+	deadline := e.used.Add(c.idle)
+	if e.expires.Before(deadline) {
+		deadline = e.expires
+	}
+	return deadline.Sub(time.Now())
+}
+
+// copyP3SKB copies the block, secret data and all, without any keys
+// that have been unpacked from it.
+func copyP3SKB(p *P3SKB) *P3SKB {
+	ret := &P3SKB{Pub: p.Pub, Type: p.Type}
+	ret.Priv.Encryption = p.Priv.Encryption
+	ret.Priv.Data = make([]byte, len(p.Priv.Data))
+	copy(ret.Priv.Data, p.Priv.Data)
+	return ret
+}
+
+// GetP3SKB returns an unencrypted copy of the key with the given KID, if
+// we're holding it, and restarts its idle timer.
+func (c *SecretCache) GetP3SKB(kid KID) *P3SKB {
+	if !c.enabled() {
+		return nil
+	}
+	c.Lock()
+	defer c.Unlock()
+
+	k := kid.String()
+	e := c.entries[k]
+	if e == nil {
+		return nil
+	}
+	if c.left(e) <= 0 {
+		c.evict(k)
+		return nil
+	}
+	e.used = time.Now()
+	e.timer.Reset(c.left(e))
+	return copyP3SKB(e.p3skb)
+}
+
+// GetSecretKey returns a copy of the unlocked key with the given KID, if
+// we're holding it. The copy is the caller's; the cache won't scrub it.
+func (c *SecretCache) GetSecretKey(kid KID) (key GenericKey, err error) {
+	if p3skb := c.GetP3SKB(kid); p3skb != nil {
+		key, err = p3skb.UnlockSecretKey(nil)
+	}
+	return
+}
+
+// PutP3SKB holds onto an unencrypted P3SKB until it times out. The
+// cache takes the block over, and will zero its secret data. It won't
+// take a block whose secret key doesn't match its KID.
+func (c *SecretCache) PutP3SKB(p3skb *P3SKB) (err error) {
+	if !c.enabled() {
+		return
+	}
+	if p3skb.Priv.Encryption != 0 {
+		return BadKeyError{"can only cache unlocked keys"}
+	}
+	if err = p3skb.CheckUnlocked(); err != nil {
+		return
+	}
+	var pub GenericKey
+	if pub, err = p3skb.GetPubKey(); err != nil {
+		return
+	}
+
+	c.Lock()
+	defer c.Unlock()
+
+	k := pub.GetKid().String()
+	c.evict(k)
+	now := time.Now()
+	e := &secretCacheEntry{p3skb: p3skb, used: now, expires: now.Add(c.maxTTL)}
+	e.timer = time.AfterFunc(c.left(e), func() { c.expire(k, e) })
+	c.entries[k] = e
+	G.Log.Debug("| SecretCache holding %s", k)
+	return
+}
+
+// PutSecretKey holds onto a copy of an unlocked key, until it times out.
+func (c *SecretCache) PutSecretKey(key GenericKey) (err error) {
+	if !c.enabled() {
+		return
+	}
+	var p3skb *P3SKB
+	if p3skb, err = key.ToP3SKB(nil); err != nil {
+		return
+	}
+	// NaCl keys share their secret with the P3SKB, so take our own copy.
+	return c.PutP3SKB(copyP3SKB(p3skb))
+}
+
+func (c *SecretCache) expire(k string, e *secretCacheEntry) {
+	c.Lock()
+	defer c.Unlock()
+
+	// A Get might have raced the timer, and pushed out the deadline.
+	if c.entries[k] != e || c.left(e) > 0 {
+		return
+	}
+	c.evict(k)
+}
+
+func (c *SecretCache) evict(k string) {
+	if e := c.entries[k]; e != nil {
+		e.timer.Stop()
+		data := e.p3skb.Priv.Data
+		for i := range data {
+			data[i] = 0
+		}
+		delete(c.entries, k)
+		G.Log.Debug("| SecretCache dropped %s", k)
+	}
+}
+
+// Clear drops, and zeroes, every key we're holding.
+func (c *SecretCache) Clear() {
+	if c == nil {
+		return
+	}
+	c.Lock()
+	defer c.Unlock()
+
+	for k := range c.entries {
+		c.evict(k)
+	}
+}
+
+// Len is the number of keys we're holding.
+func (c *SecretCache) Len() int {
+	if c == nil {
+		return 0
+	}
+	c.Lock()
+	defer c.Unlock()
+	return len(c.entries)
+}
@@ -0,0 +1,187 @@
+package libkb
+
+import (
+	"github.com/agl/ed25519"
+	"testing"
+	"time"
+)
+
+func genCacheKey(t *testing.T) NaclSigningKeyPair {
+	key, err := GenerateNaclSigningKeyPair()
+	if err != nil {
+		t.Fatal(err)
+	}
+	return key.(NaclSigningKeyPair)
+}
+
+func getCachedKey(t *testing.T, c *SecretCache, kid KID) *NaclSigningKeyPair {
+	key, err := c.GetSecretKey(kid)
+	if err != nil {
+		t.Fatal(err)
+	} else if key == nil {
+		return nil
+	}
+	ret := key.(NaclSigningKeyPair)
+	return &ret
+}
+
+// cachedData is the cache's own copy of the secret, to check it gets zeroed.
+func cachedData(c *SecretCache, kid KID) []byte {
+	c.Lock()
+	defer c.Unlock()
+	return c.entries[kid.String()].p3skb.Priv.Data
+}
+
+func isZero(b []byte) bool {
+	for _, c := range b {
+		if c != 0 {
+			return false
+		}
+	}
+	return true
+}
+
+func TestSecretCacheClear(t *testing.T) {
+	c := NewSecretCache(time.Minute, time.Hour)
+	key := genCacheKey(t)
+	if err := c.PutSecretKey(key); err != nil {
+		t.Fatal(err)
+	}
+	got := getCachedKey(t, c, key.GetKid())
+	if got == nil {
+		t.Fatalf("key wasn't cached")
+	}
+	if *got.Private != *key.Private {
+		t.Fatalf("got a different key back")
+	}
+	if got.Private == key.Private {
+		t.Errorf("cache handed out its own copy of the key")
+	}
+	data := cachedData(c, key.GetKid())
+
+	c.Clear()
+	if c.Len() != 0 || getCachedKey(t, c, key.GetKid()) != nil {
+		t.Errorf("key still cached after Clear")
+	}
+	if !isZero(data) {
+		t.Errorf("cached secret wasn't zeroed")
+	}
+	if isZero(key.Private[:]) {
+		t.Errorf("Put's caller had its key zeroed")
+	}
+}
+
+func TestSecretCacheTimeouts(t *testing.T) {
+	idle := 50 * time.Millisecond
+	c := NewSecretCache(idle, 4*idle)
+	key := genCacheKey(t)
+	c.PutSecretKey(key)
+
+	// Using the key keeps it around past the idle timeout...
+	for i := 0; i < 3; i++ {
+		time.Sleep(idle / 2)
+		if getCachedKey(t, c, key.GetKid()) == nil {
+			t.Fatalf("key dropped while in use")
+		}
+	}
+	// ...but not past the max TTL.
+	for i := 0; i < 6; i++ {
+		time.Sleep(idle / 2)
+		c.GetSecretKey(key.GetKid())
+	}
+	if c.Len() != 0 {
+		t.Errorf("key held past its max TTL")
+	}
+
+	// A zero timeout turns the cache off.
+	off := NewSecretCache(0, time.Hour)
+	off.PutSecretKey(key)
+	if off.Len() != 0 {
+		t.Errorf("disabled cache held a key")
+	}
+}
+
+func TestSecretCacheExpireWhileHeld(t *testing.T) {
+	idle := 50 * time.Millisecond
+	c := NewSecretCache(idle, time.Hour)
+	key := genCacheKey(t)
+	c.PutSecretKey(key)
+
+	held := getCachedKey(t, c, key.GetKid())
+	if held == nil {
+		t.Fatalf("key wasn't cached")
+	}
+	data := cachedData(c, key.GetKid())
+
+	time.Sleep(2 * idle)
+	if c.Len() != 0 {
+		t.Fatalf("idle key wasn't dropped")
+	}
+	if !isZero(data) {
+		t.Errorf("expired secret wasn't zeroed")
+	}
+
+	// The caller's copy still works after the cache has dropped its own.
+	msg := []byte("signed after expiry")
+	sig, err := held.Sign(msg)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !ed25519.Verify(key.Public.ToNaclLibrary(), msg, &sig.Sig) {
+		t.Errorf("held key was scrubbed out from under its caller")
+	}
+}
+
+func TestSecretCacheMismatchedKey(t *testing.T) {
+	c := NewSecretCache(time.Minute, time.Hour)
+
+	// toP3SKBs makes unlocked blocks for two keys of the same type.
+	toP3SKBs := func(a, b GenericKey) (*P3SKB, *P3SKB) {
+		pa, err := a.ToP3SKB(nil)
+		if err != nil {
+			t.Fatal(err)
+		}
+		pb, err := b.ToP3SKB(nil)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return copyP3SKB(pa), copyP3SKB(pb)
+	}
+	dh := func() GenericKey {
+		key, err := GenerateNaclDHKeyPair()
+		if err != nil {
+			t.Fatal(err)
+		}
+		return key
+	}
+
+	pairs := map[string][2]GenericKey{
+		"EdDSA": {genCacheKey(t), genCacheKey(t)},
+		"DH":    {dh(), dh()},
+		"PGP":   {genSigningKey(t), genSigningKey(t)},
+	}
+	for name, pair := range pairs {
+		a, b := toP3SKBs(pair[0], pair[1])
+		// b's secret key, passed off as a's.
+		forged := copyP3SKB(a)
+		forged.Priv.Data = b.Priv.Data
+		if err := c.PutP3SKB(forged); err == nil {
+			t.Errorf("%s: cached a secret key under someone else's KID", name)
+		}
+		if err := c.PutP3SKB(a); err != nil {
+			t.Errorf("%s: didn't cache a good key: %s", name, err)
+		}
+	}
+	if c.Len() != len(pairs) {
+		t.Errorf("cached %d keys, wanted %d", c.Len(), len(pairs))
+	}
+
+	// An EdDSA secret key carries its public key in its second half,
+	// which is what it signs with, so that has to match too.
+	key, other := genCacheKey(t), genCacheKey(t)
+	p, _ := toP3SKBs(key, other)
+	copy(p.Priv.Data[32:], other.Public[:])
+	if err := p.CheckUnlocked(); err == nil {
+		t.Errorf("took an EdDSA secret key with the wrong public half")
+	}
+}
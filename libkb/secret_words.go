@@ -0,0 +1,75 @@
+package libkb
+
+import (
+	"fmt"
+	"strings"
+)
+
+// secretWords maps each byte value to a short, distinct English word, so
+// that secrets we show to the user can be written down or read out.
+var secretWords = [256]string{
+	"acid", "acorn", "actor", "agent", "alarm", "album", "alert", "alley",
+	"angle", "ankle", "apple", "apron", "arena", "armor", "aspen", "atlas",
+	"attic", "award", "bacon", "badge", "baker", "bamboo", "banjo", "barley",
+	"basin", "beach", "berry", "bison", "blade", "blank", "blaze", "bloom",
+	"bonus", "boost", "brain", "brass", "bread", "brick", "brush", "cabin",
+	"cable", "camel", "canal", "candy", "cargo", "carol", "cedar", "chalk",
+	"charm", "chess", "chili", "cider", "cigar", "civic", "clamp", "cliff",
+	"clock", "cloud", "coach", "cobra", "cocoa", "comet", "couch", "crane",
+	"crate", "creek", "crown", "cubic", "cycle", "daisy", "dance", "delta",
+	"denim", "depot", "dingo", "disco", "ditch", "diver", "dough", "draft",
+	"dream", "drill", "drum", "eagle", "easel", "elbow", "ember", "empty",
+	"epoch", "equal", "error", "event", "fairy", "fancy", "feast", "fence",
+	"ferry", "fiber", "flame", "flask", "fleet", "flint", "flute", "focus",
+	"fossil", "frost", "fruit", "fudge", "gamma", "garden", "ghost", "giant",
+	"ginger", "glass", "globe", "glove", "grape", "gravy", "guild", "guitar",
+	"habit", "hammer", "hazel", "heart", "hedge", "heron", "hinge", "hobby",
+	"hotel", "human", "igloo", "index", "ivory", "jelly", "jewel", "joker",
+	"judge", "juice", "kayak", "kiosk", "kitten", "knife", "koala", "label",
+	"ladder", "level", "lilac", "linen", "llama", "lobby", "lotus", "lyric",
+	"magic", "mango", "maple", "marble", "meadow", "melon", "metal", "mimic",
+	"minor", "mocha", "motor", "nacho", "naval", "nectar", "noble", "north",
+	"novel", "oasis", "ocean", "olive", "omega", "onion", "opera", "otter",
+	"oxide", "paddle", "panda", "paper", "pasta", "pearl", "pepper", "piano",
+	"pilot", "pixel", "plaza", "pony", "poppy", "prism", "pulse", "quail",
+	"quartz", "quiet", "radar", "radio", "raven", "relay", "rhino", "river",
+	"robin", "rocket", "rodeo", "royal", "ruby", "salad", "salmon", "satin",
+	"scarf", "scout", "shark", "shrub", "silk", "siren", "skate", "slate",
+	"sloth", "sonic", "spice", "spoon", "squid", "stamp", "steam", "storm",
+	"sugar", "swamp", "table", "tango", "thorn", "toast", "topaz", "torch",
+	"tower", "trail", "tulip", "ultra", "umbra", "union", "urban", "valve",
+	"vapor", "venom", "video", "vigor", "viola", "vivid", "waffle", "walrus",
+	"whale", "wheat", "willow", "winter", "wizard", "yodel", "zebra", "zesty",
+}
+
+var secretWordIndex map[string]byte
+
+func init() {
+	secretWordIndex = make(map[string]byte, len(secretWords))
+	for i, w := range secretWords {
+		secretWordIndex[w] = byte(i)
+	}
+}
+
+// BytesToWords encodes b as a space-separated phrase, one word per byte.
+func BytesToWords(b []byte) string {
+	words := make([]string, len(b))
+	for i, c := range b {
+		words[i] = secretWords[c]
+	}
+	return strings.Join(words, " ")
+}
+
+// WordsToBytes decodes a phrase made by BytesToWords. It's forgiving
+// about case and extra whitespace, since the user will have typed it.
+func WordsToBytes(s string) (ret []byte, err error) {
+	for _, w := range strings.Fields(strings.ToLower(s)) {
+		c, found := secretWordIndex[w]
+		if !found {
+			err = fmt.Errorf("Unknown word in secret phrase: %q", w)
+			return nil, err
+		}
+		ret = append(ret, c)
+	}
+	return
+}
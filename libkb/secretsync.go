@@ -4,6 +4,7 @@ package libkb
 
 import (
 	"fmt"
+	"github.com/keybase/go-triplesec"
 	"sync"
 )
 
@@ -121,6 +122,45 @@ func (ss *SecretSyncer) store() (err error) {
 	return
 }
 
+// Reencrypt re-encrypts all of our synced keys from the old passphrase
+// to tsec, for a passphrase change. It returns the new bundles, to push
+// to the server, but doesn't keep them until we Replace with them.
+func (ss *SecretSyncer) Reencrypt(oldpp string, tsec *triplesec.Cipher) (ret ServerPrivateKeyMap, err error) {
+	ss.Lock()
+	defer ss.Unlock()
+
+	ret = make(ServerPrivateKeyMap)
+	for kid, key := range ss.keys.PrivateKeys {
+		var packet *KeybasePacket
+		var p3skb *P3SKB
+		if packet, err = DecodeArmoredPacket(key.Bundle); err != nil {
+			return
+		}
+		if p3skb, err = packet.ToP3SKB(); err != nil {
+			return
+		}
+		if p3skb, err = p3skb.Reencrypt(oldpp, tsec); err != nil {
+			return
+		}
+		if key.Bundle, err = p3skb.ArmoredEncode(); err != nil {
+			return
+		}
+		ret[kid] = key
+	}
+	return
+}
+
+// Replace swaps in the keys we pushed to the server. We'll pick up the
+// server's new version the next time we Load.
+func (ss *SecretSyncer) Replace(keys ServerPrivateKeyMap) (err error) {
+	ss.Lock()
+	defer ss.Unlock()
+
+	ss.keys.PrivateKeys = keys
+	ss.dirty = true
+	return ss.store()
+}
+
 // FindActiveKey examines the synced keys, looking for one that's currently active.
 func (ss *SecretSyncer) FindActiveKey(ckf *ComputedKeyFamily) (ret *P3SKB, err error) {
 	for _, key := range ss.keys.PrivateKeys {
@@ -147,3 +187,27 @@ func (k *ServerPrivateKey) FindActiveKey(ckf *ComputedKeyFamily) (ret *P3SKB, er
 	}
 	return packet.ToP3SKB()
 }
+
+// FindPgpKeyById looks through the synced keys for the PGP key that has
+// a primary key or subkey with the given 64-bit key ID.
+func (ss *SecretSyncer) FindPgpKeyById(id uint64) (ret *P3SKB, err error) {
+	ss.Lock()
+	defer ss.Unlock()
+
+	for _, key := range ss.keys.PrivateKeys {
+		var packet *KeybasePacket
+		var p3skb *P3SKB
+		if packet, err = DecodeArmoredPacket(key.Bundle); err != nil {
+			return
+		}
+		if p3skb, err = packet.ToP3SKB(); err != nil {
+			return
+		}
+		if p3skb.HasPgpKeyId(id) {
+			ret = p3skb
+			return
+		}
+	}
+	err = NoSecretKeyError{}
+	return
+}
@@ -0,0 +1,165 @@
+package libkb
+
+import (
+	"fmt"
+)
+
+// LinkAudit explains why one link in a sigchain does or doesn't check
+// out. Problems with a link are kept in Err, rather than stopping the
+// audit, so that a single bad link doesn't hide the rest of the chain.
+type LinkAudit struct {
+	Seqno Seqno
+	Link  *ChainLink // nil if the link couldn't be parsed
+	Type  string
+
+	PrevOk      bool
+	SigningKey  FOKID
+	MerkleSeqno int
+
+	// Replayed is false for links from before the user's current eldest
+	// key, say before an account reset; we can't check their keys.
+	Replayed bool
+
+	// ActiveSibkey is whether the signing key was an active sibkey just
+	// before this link, as computed by replaying the chain up to it.
+	ActiveSibkey bool
+
+	// RevokedBy is the later link that revoked this one, if any.
+	RevokedBy *LinkAudit
+
+	Err error
+}
+
+func (a *LinkAudit) fail(err error) {
+	if a.Err == nil && err != nil {
+		a.Err = err
+	}
+}
+
+// AuditSigChain fetches the given user's whole sigchain from the server,
+// and replays it link by link.
+func AuditSigChain(name string) (u *User, ret []*LinkAudit, err error) {
+	G.Log.Debug("+ AuditSigChain(%s)", name)
+	defer func() {
+		G.Log.Debug("- AuditSigChain(%s) -> %s", name, ErrToOk(err))
+	}()
+
+	rres := ResolveUid(name)
+	if err = rres.err; err != nil {
+		return
+	} else if rres.uid == nil {
+		err = fmt.Errorf("No resolution for name=%s", name)
+		return
+	}
+	if u, err = LoadUserFromServer(LoadUserArg{Uid: rres.uid}, rres.body); err != nil {
+		return
+	}
+
+	sc := &SigChain{uid: u.GetUid(), username: u.GetName()}
+	if ret, err = sc.importAudits(); err != nil {
+		return
+	}
+	if kf := u.GetKeyFamily(); kf != nil {
+		sc.replayAudits(kf, ret)
+	}
+	findRevokers(ret)
+	return
+}
+
+// importAudits loads all of the raw links from the server, and checks
+// each one by itself, and against the one before it.
+func (sc *SigChain) importAudits() (ret []*LinkAudit, err error) {
+	v, n, err := getServerLinks(sc.uid, 0)
+	if err != nil {
+		return
+	}
+
+	var prev *ChainLink
+	for i := 0; i < n; i++ {
+		a := &LinkAudit{Seqno: Seqno(i + 1)}
+		ret = append(ret, a)
+
+		var link *ChainLink
+		if link, a.Err = ImportLinkFromServer(sc, v.AtIndex(i)); a.Err != nil {
+			prev = nil
+			continue
+		}
+		a.Link = link
+		a.SigningKey = link.ToFOKID()
+		a.MerkleSeqno = link.GetMerkleSeqno()
+		tcl, _ := NewTypedChainLink(link)
+		a.Type = tcl.Type()
+
+		if link.GetSeqno() != a.Seqno {
+			a.fail(fmt.Errorf("Expected seqno %d, got %d", int(a.Seqno), int(link.GetSeqno())))
+		}
+		if i == 0 {
+			a.PrevOk = (link.GetPrev() == nil)
+		} else if prev != nil {
+			a.PrevOk = prev.id.Eq(link.GetPrev())
+		}
+		if !a.PrevOk {
+			a.fail(fmt.Errorf("Chain mismatch: prev doesn't match the link before"))
+		}
+		a.fail(link.VerifyLink())
+		a.fail(link.CheckNameAndId(sc.username, sc.uid))
+
+		sc.chainLinks = append(sc.chainLinks, link)
+		prev = link
+	}
+	return
+}
+
+// replayAudits plays the links of the current key family forward, as
+// verifySubchain does, noting which keys were active at each step. Links
+// that fail don't get to delegate or revoke anything.
+func (sc *SigChain) replayAudits(kf *KeyFamily, audits []*LinkAudit) {
+	replay := make(map[*ChainLink]bool)
+	for _, link := range sc.LimitToKeyFamily(kf) {
+		replay[link] = true
+	}
+
+	ckf := ComputedKeyFamily{kf, kf.NewComputedKeyInfos()}
+	for _, a := range audits {
+		if a.Link == nil || !replay[a.Link] {
+			continue
+		}
+		a.Replayed = true
+
+		_, e := ckf.FindActiveSibkey(a.SigningKey)
+		a.ActiveSibkey = (e == nil)
+		a.fail(e)
+		if a.Err != nil {
+			continue
+		}
+		_, e = a.Link.VerifySigWithKeyFamily(ckf)
+		a.fail(e)
+		if a.Err != nil {
+			continue
+		}
+
+		tcl, _ := NewTypedChainLink(a.Link)
+		if tcl.IsDelegation() != DLG_NONE {
+			a.fail(ckf.Delegate(tcl))
+		}
+		a.fail(ckf.Revoke(tcl))
+	}
+}
+
+// findRevokers points each link at the later, good link that revoked it.
+func findRevokers(audits []*LinkAudit) {
+	bySig := make(map[string]*LinkAudit)
+	for _, a := range audits {
+		if a.Link == nil {
+			continue
+		}
+		if a.Err == nil {
+			for _, s := range a.Link.GetRevocations() {
+				if t := bySig[s.ToString(true)]; t != nil && t.RevokedBy == nil {
+					t.RevokedBy = a
+				}
+			}
+		}
+		bySig[a.Link.GetSigId().ToString(true)] = a
+	}
+}
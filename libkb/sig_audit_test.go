@@ -0,0 +1,41 @@
+package libkb
+
+import (
+	"fmt"
+	"github.com/keybase/go-jsonw"
+	"testing"
+)
+
+func fakeAudit(seqno int, sigid SigId, revokes *SigId) *LinkAudit {
+	body := jsonw.NewDictionary()
+	if revokes != nil {
+		revoke := jsonw.NewDictionary()
+		revoke.SetKey("sig_id", jsonw.NewString(revokes.ToString(true)))
+		body.SetKey("revoke", revoke)
+	}
+	payload := jsonw.NewDictionary()
+	payload.SetKey("body", body)
+	link := &ChainLink{
+		payloadJson: payload,
+		unpacked:    &ChainLinkUnpacked{seqno: Seqno(seqno), sigId: sigid},
+	}
+	return &LinkAudit{Seqno: Seqno(seqno), Link: link}
+}
+
+func TestFindRevokers(t *testing.T) {
+	s1, s2, s3 := SigId{0x01}, SigId{0x02}, SigId{0x03}
+	a1 := fakeAudit(1, s1, nil)
+	a2 := fakeAudit(2, s2, &s1)
+	a3 := fakeAudit(3, s3, &s1)
+
+	// A link that failed its checks can't revoke anything.
+	a2.Err = fmt.Errorf("bad signature")
+	findRevokers([]*LinkAudit{a1, a2, a3})
+
+	if a1.RevokedBy != a3 {
+		t.Errorf("expected #1 to be revoked by #3, got %v", a1.RevokedBy)
+	}
+	if a2.RevokedBy != nil || a3.RevokedBy != nil {
+		t.Errorf("only #1 should have been revoked")
+	}
+}
@@ -0,0 +1,227 @@
+package libkb
+
+import (
+	"fmt"
+	"github.com/keybase/go-jsonw"
+)
+
+const SIG_BUNDLE_VERSION = 1
+
+// A sig bundle is a self-contained snapshot of a user's public sigchain:
+// the raw chain links, the key bundles they refer to, the user's sig
+// hints, and the Merkle path that pins down the chain tail, along with
+// the key that signed the Merkle root. It can be verified later without
+// talking to the server.
+
+// ExportSigBundle loads the given user and packs up their sigchain into
+// a bundle, checking it along the way.
+func ExportSigBundle(name string) (ret *jsonw.Wrapper, err error) {
+	G.Log.Debug("+ ExportSigBundle(%s)", name)
+	defer func() {
+		G.Log.Debug("- ExportSigBundle(%s) -> %s", name, ErrToOk(err))
+	}()
+
+	var u *User
+	if u, err = LoadUser(LoadUserArg{Name: name, AllKeys: true}); err != nil {
+		return
+	}
+	uid := u.GetUid()
+
+	// Hints first, so they can't refer to links past the Merkle tail.
+	var hints *SigHints
+	if hints, err = LoadAndRefreshSigHints(uid); err != nil {
+		return
+	}
+
+	// Get the path next, and then only keep the links up to the tail
+	// it advertises, in case the chain grows in between.
+	var merkle *jsonw.Wrapper
+	var leaf *MerkleUserLeaf
+	if merkle, leaf, err = exportMerkleProof(uid); err != nil {
+		return
+	}
+
+	var sigs *jsonw.Wrapper
+	if sigs, err = exportLinks(u, leaf.public); err != nil {
+		return
+	}
+
+	ret = jsonw.NewDictionary()
+	ret.SetKey("version", jsonw.NewInt(SIG_BUNDLE_VERSION))
+	ret.SetKey("uid", jsonw.NewString(uid.String()))
+	ret.SetKey("username", jsonw.NewString(u.GetName()))
+	ret.SetKey("public_keys", u.publicKeys)
+	ret.SetKey("sigs", sigs)
+	ret.SetKey("sig_hints", hints.MarshalToJson())
+	ret.SetKey("merkle", merkle)
+	return
+}
+
+// exportLinks fetches the user's chain links from the server, as raw
+// JSON, up to and including the given Merkle tail.
+func exportLinks(u *User, tail *MerkleTriple) (ret *jsonw.Wrapper, err error) {
+	var links []*jsonw.Wrapper
+	if tail != nil {
+		var v *jsonw.Wrapper
+		var n int
+		if v, n, err = getServerLinks(u.GetUid(), 0); err != nil {
+			return
+		}
+		found := false
+		for i := 0; i < n && !found; i++ {
+			var link *ChainLink
+			if link, err = ImportLinkFromServer(nil, v.AtIndex(i)); err != nil {
+				return
+			}
+			if found, err = link.checkAgainstMerkleTree(tail); err != nil {
+				return
+			}
+			links = append(links, v.AtIndex(i))
+		}
+		if !found {
+			err = NewServerChainError("Failed to reach (%s, %d) in server response",
+				tail.linkId.String(), int(tail.seqno))
+			return
+		}
+	}
+
+	ret = jsonw.NewArray(len(links))
+	for i, l := range links {
+		ret.SetIndex(i, l)
+	}
+	return
+}
+
+//=============================================================================
+
+// SigBundleSummary describes a bundle that passed VerifySigBundle.
+type SigBundleSummary struct {
+	Username    string
+	Uid         UID
+	Links       int
+	Tail        Seqno
+	MerkleSeqno Seqno
+	MerkleCTime int64
+	Hints       int
+}
+
+// VerifySigBundle checks a bundle written by ExportSigBundle, using only
+// what's in the bundle and the Merkle key fingerprints in our config.
+// It never touches the network.
+func VerifySigBundle(jw *jsonw.Wrapper) (ret *SigBundleSummary, err error) {
+	G.Log.Debug("+ VerifySigBundle")
+	defer func() {
+		G.Log.Debug("- VerifySigBundle -> %s", ErrToOk(err))
+	}()
+
+	var version int
+	if version, err = jw.AtKey("version").GetInt(); err != nil {
+		return
+	} else if version != SIG_BUNDLE_VERSION {
+		err = SigBundleError{fmt.Sprintf("unknown version %d", version)}
+		return
+	}
+
+	var uid UID
+	var username string
+	GetUidVoid(jw.AtKey("uid"), &uid, &err)
+	jw.AtKey("username").GetStringVoid(&username, &err)
+	if err != nil {
+		return
+	}
+
+	var kf *KeyFamily
+	if kf, err = ParseKeyFamily(jw.AtKey("public_keys")); err != nil {
+		return
+	}
+
+	var vp *VerificationPath
+	var leaf *MerkleUserLeaf
+	if vp, leaf, err = verifyMerkleProof(jw.AtKey("merkle")); err != nil {
+		return
+	}
+	if !vp.uid.Eq(uid) {
+		err = SigBundleError{fmt.Sprintf("Merkle path is for %s, not %s", vp.uid, uid)}
+		return
+	}
+
+	sc := &SigChain{uid: uid, username: username}
+	if err = importBundleLinks(sc, jw.AtKey("sigs"), leaf.public); err != nil {
+		return
+	}
+	if err = sc.VerifyChain(); err != nil {
+		return
+	}
+	if links := sc.LimitToKeyFamily(kf); len(links) > 0 {
+		if _, _, err = verifySubchain(*kf, links); err != nil {
+			return
+		}
+	}
+
+	var hints *SigHints
+	if hints, err = NewSigHints(jw.AtKey("sig_hints"), uid, false); err != nil {
+		return
+	}
+	for id := range hints.hints {
+		if !sc.hasSigId(id) {
+			err = SigBundleError{fmt.Sprintf("sig hint for unknown signature %s", id.ToString(true))}
+			return
+		}
+	}
+
+	ret = &SigBundleSummary{
+		Username:    username,
+		Uid:         uid,
+		Links:       sc.Len(),
+		Tail:        sc.GetLastLoadedSeqno(),
+		MerkleSeqno: vp.root.seqno,
+		MerkleCTime: vp.root.ctime,
+		Hints:       len(hints.hints),
+	}
+	return
+}
+
+// importBundleLinks loads the raw links from a bundle into sc, and
+// checks that the last of them is the tail advertised in the Merkle tree.
+func importBundleLinks(sc *SigChain, jw *jsonw.Wrapper, tail *MerkleTriple) (err error) {
+	var n int
+	if n, err = jw.Len(); err != nil {
+		return
+	}
+	for i := 0; i < n; i++ {
+		var link *ChainLink
+		if link, err = ImportLinkFromServer(sc, jw.AtIndex(i)); err != nil {
+			return
+		}
+		if want := Seqno(i + 1); link.GetSeqno() != want {
+			return SigBundleError{fmt.Sprintf("expected seqno %d, got %d", int(want), int(link.GetSeqno()))}
+		}
+		sc.chainLinks = append(sc.chainLinks, link)
+	}
+
+	last := sc.GetLastLink()
+	if tail == nil {
+		if last != nil {
+			err = SigBundleError{"Merkle tree has no chain tail, but the bundle has links"}
+		}
+		return
+	}
+	if last == nil {
+		return SigBundleError{"Merkle tree has a chain tail, but the bundle has no links"}
+	}
+	var found bool
+	if found, err = last.checkAgainstMerkleTree(tail); err == nil && !found {
+		err = SigBundleError{fmt.Sprintf("last link is %d, but the Merkle tail is %d",
+			int(last.GetSeqno()), int(tail.seqno))}
+	}
+	return
+}
+
+func (sc SigChain) hasSigId(id SigId) bool {
+	for _, link := range sc.chainLinks {
+		if *link.GetSigId() == id {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,273 @@
+package libkb
+
+import (
+	"crypto/sha256"
+	"crypto/sha512"
+	"encoding/hex"
+	"github.com/keybase/go-jsonw"
+	"golang.org/x/crypto/openpgp"
+	"io/ioutil"
+	"os"
+	"strconv"
+	"strings"
+	"testing"
+)
+
+// fakeVerificationPath makes the JSON for a one-level Merkle path, in
+// which the root node is the leaf for the given user.
+func fakeVerificationPath(uid string, linkId string) *jsonw.Wrapper {
+	return fakeVerificationPathAt(uid, 3, linkId, strings.Repeat("ab", 20))
+}
+
+// fakeVerificationPathAt is fakeVerificationPath for a chain tail at the
+// given seqno, under a root claiming to be signed by the key with the
+// given fingerprint.
+func fakeVerificationPathAt(uid string, seqno int, linkId string, fp string) *jsonw.Wrapper {
+	leaf := `{"type":2,"tab":{"` + uid + `":[2,[` + strconv.Itoa(seqno) + `,"` + linkId + `"]]}}`
+	h := sha512.Sum512([]byte(leaf))
+
+	payload := `{"body":{"key":{"fingerprint":"` + fp +
+		`"},"seqno":42,"root":"` + hex.EncodeToString(h[:]) + `"},"ctime":1420000000}`
+	root := jsonw.NewDictionary()
+	root.SetKey("sig", jsonw.NewString("unsigned"))
+	root.SetKey("payload_json", jsonw.NewString(payload))
+
+	node := jsonw.NewDictionary()
+	node.SetKey("val", jsonw.NewString(leaf))
+	step := jsonw.NewDictionary()
+	step.SetKey("prefix", jsonw.NewString(""))
+	step.SetKey("node", node)
+	path := jsonw.NewArray(1)
+	path.SetIndex(0, step)
+
+	ret := jsonw.NewDictionary()
+	ret.SetKey("root", root)
+	ret.SetKey("path", path)
+	ret.SetKey("uid", jsonw.NewString(uid))
+	ret.SetKey("id_version", jsonw.NewInt(7))
+	return ret
+}
+
+func TestVerificationPathJsonRoundTrip(t *testing.T) {
+	G.Init()
+	uid := strings.Repeat("1", 30) + "00"
+	linkId := strings.Repeat("c", 64)
+
+	vp, err := NewVerificationPathFromJson(fakeVerificationPath(uid, linkId))
+	if err != nil {
+		t.Fatalf("parse error: %s", err)
+	}
+	vp2, err := NewVerificationPathFromJson(vp.ToJson())
+	if err != nil {
+		t.Fatalf("reparse error: %s", err)
+	}
+	if !vp2.uid.Eq(vp.uid) || vp2.idVersion != 7 || vp2.root.seqno != 42 {
+		t.Errorf("path didn't survive a round trip: %+v", vp2)
+	}
+	if vp2.root.payloadJsonString != vp.root.payloadJsonString {
+		t.Errorf("root payload changed in a round trip")
+	}
+
+	leaf, err := vp2.Verify()
+	if err != nil {
+		t.Fatalf("verify error: %s", err)
+	}
+	if leaf.public == nil || leaf.public.seqno != 3 || leaf.public.linkId.String() != linkId {
+		t.Errorf("bad leaf: %+v", leaf.public)
+	}
+}
+
+func TestVerifySigBundleBadVersion(t *testing.T) {
+	G.Init()
+	jw := jsonw.NewDictionary()
+	jw.SetKey("version", jsonw.NewInt(SIG_BUNDLE_VERSION+1))
+	if _, err := VerifySigBundle(jw); err == nil {
+		t.Fatal("expected an error for an unknown bundle version")
+	} else if _, ok := err.(SigBundleError); !ok {
+		t.Errorf("expected a SigBundleError; got %T", err)
+	}
+}
+
+// sigBundleTest builds small bundles for a two-link chain, signed by a
+// fresh PGP key that also signs the Merkle root.
+type sigBundleTest struct {
+	t     *testing.T
+	key   *PgpKeyBundle
+	uid   string
+	links []*jsonw.Wrapper
+}
+
+func newSigBundleTest(t *testing.T) *sigBundleTest {
+	b := &sigBundleTest{t: t, key: genSigningKey(t), uid: strings.Repeat("1", 30) + "00"}
+	// Sign the new key's user IDs and subkeys, so it can be exported.
+	if err := (*openpgp.Entity)(b.key).SerializePrivate(ioutil.Discard, nil); err != nil {
+		t.Fatal(err)
+	}
+	var prev string
+	for seqno := 1; seqno <= 2; seqno++ {
+		link := b.link(seqno, prev, "hello")
+		prev, _ = link.AtKey("payload_hash").GetString()
+		b.links = append(b.links, link)
+	}
+	return b
+}
+
+// link makes a signed announcement at the given seqno, following the
+// link with the given hash.
+func (b *sigBundleTest) link(seqno int, prev string, msg string) *jsonw.Wrapper {
+	key := jsonw.NewDictionary()
+	key.SetKey("kid", jsonw.NewString(b.key.GetKid().String()))
+	key.SetKey("fingerprint", jsonw.NewString(b.key.GetFingerprint().String()))
+	key.SetKey("uid", jsonw.NewString(b.uid))
+	key.SetKey("username", jsonw.NewString("max"))
+	ann := jsonw.NewDictionary()
+	ann.SetKey("message", jsonw.NewString(msg))
+	body := jsonw.NewDictionary()
+	body.SetKey("type", jsonw.NewString("announcement"))
+	body.SetKey("key", key)
+	body.SetKey("announcement", ann)
+	payload := jsonw.NewDictionary()
+	payload.SetKey("body", body)
+	payload.SetKey("seqno", jsonw.NewInt(seqno))
+	payload.SetKey("ctime", jsonw.NewInt64(1420000000))
+	payload.SetKey("expire_in", jsonw.NewInt(10000000))
+	if prev == "" {
+		payload.SetKey("prev", jsonw.NewNil())
+	} else {
+		payload.SetKey("prev", jsonw.NewString(prev))
+	}
+	js, err := payload.Marshal()
+	if err != nil {
+		b.t.Fatal(err)
+	}
+	return b.signLink(string(js))
+}
+
+func (b *sigBundleTest) signLink(payload string) *jsonw.Wrapper {
+	sig, id, err := b.key.SignToString([]byte(payload))
+	if err != nil {
+		b.t.Fatal(err)
+	}
+	h := sha256.Sum256([]byte(payload))
+	ret := jsonw.NewDictionary()
+	ret.SetKey("sig", jsonw.NewString(sig))
+	ret.SetKey("sig_id", jsonw.NewString(id.ToString(true)))
+	ret.SetKey("payload_json", jsonw.NewString(payload))
+	ret.SetKey("payload_hash", jsonw.NewString(hex.EncodeToString(h[:])))
+	return ret
+}
+
+// bundle packs up the given links, under a Merkle root that advertises
+// the given tail and is signed by b.key, along with a hint for each of
+// the given sig IDs.
+func (b *sigBundleTest) bundle(links []*jsonw.Wrapper, seqno int, linkId string, hints []string) *jsonw.Wrapper {
+	fp := b.key.GetFingerprint().String()
+	merkle := fakeVerificationPathAt(b.uid, seqno, linkId, fp)
+	payload, err := merkle.AtPath("root.payload_json").GetString()
+	if err != nil {
+		b.t.Fatal(err)
+	}
+	sig, _, err := b.key.SignToString([]byte(payload))
+	if err != nil {
+		b.t.Fatal(err)
+	}
+	merkle.AtKey("root").SetKey("sig", jsonw.NewString(sig))
+	armored, err := b.key.Encode()
+	if err != nil {
+		b.t.Fatal(err)
+	}
+	merkle.SetKey("key", jsonw.NewString(armored))
+
+	rec := jsonw.NewDictionary()
+	rec.SetKey("kid", jsonw.NewString(b.key.GetKid().String()))
+	rec.SetKey("bundle", jsonw.NewString(armored))
+	rec.SetKey("key_fingerprint", jsonw.NewString(fp))
+	rec.SetKey("key_algo", jsonw.NewInt(b.key.GetAlgoType()))
+	sibkeys := jsonw.NewDictionary()
+	sibkeys.SetKey(b.key.GetKid().String(), rec)
+	keys := jsonw.NewDictionary()
+	keys.SetKey("sibkeys", sibkeys)
+
+	sigs := jsonw.NewArray(len(links))
+	for i, l := range links {
+		sigs.SetIndex(i, l)
+	}
+
+	h := jsonw.NewArray(len(hints))
+	for i, id := range hints {
+		hint := jsonw.NewDictionary()
+		hint.SetKey("sig_id", jsonw.NewString(id))
+		h.SetIndex(i, hint)
+	}
+	sh := jsonw.NewDictionary()
+	sh.SetKey("version", jsonw.NewInt(1))
+	sh.SetKey("hints", h)
+
+	ret := jsonw.NewDictionary()
+	ret.SetKey("version", jsonw.NewInt(SIG_BUNDLE_VERSION))
+	ret.SetKey("uid", jsonw.NewString(b.uid))
+	ret.SetKey("username", jsonw.NewString("max"))
+	ret.SetKey("public_keys", keys)
+	ret.SetKey("sigs", sigs)
+	ret.SetKey("sig_hints", sh)
+	ret.SetKey("merkle", merkle)
+	return ret
+}
+
+func (b *sigBundleTest) get(link *jsonw.Wrapper, k string) string {
+	s, err := link.AtKey(k).GetString()
+	if err != nil {
+		b.t.Fatal(err)
+	}
+	return s
+}
+
+// trustKey makes b.key the only Merkle key we trust, and returns a func
+// to undo that.
+func (b *sigBundleTest) trustKey() func() {
+	const v = "KEYBASE_MERKLE_KEY_FINGERPRINTS"
+	old := os.Getenv(v)
+	os.Setenv(v, b.key.GetFingerprint().String())
+	return func() { os.Setenv(v, old) }
+}
+
+func TestVerifySigBundleTampered(t *testing.T) {
+	b := newSigBundleTest(t)
+	defer b.trustKey()()
+	l1, l2 := b.links[0], b.links[1]
+	tail := b.get(l2, "payload_hash")
+	hint := []string{b.get(l1, "sig_id")}
+
+	sum, err := VerifySigBundle(b.bundle(b.links, 2, tail, hint))
+	if err != nil {
+		t.Fatalf("good bundle didn't verify: %s", err)
+	}
+	if sum.Links != 2 || sum.Tail != 2 || sum.Hints != 1 {
+		t.Errorf("bad summary: %+v", sum)
+	}
+
+	// A second link that isn't the one the Merkle tree has.
+	other := b.link(2, b.get(l1, "payload_hash"), "goodbye")
+	// A link after a gap in the chain.
+	gap := b.link(3, b.get(l1, "payload_hash"), "hi")
+	// Link 1 with its payload changed, but its hash and sig left alone.
+	payload := strings.Replace(b.get(l1, "payload_json"), "hello", "hijacked", 1)
+	edited := b.signLink(b.get(l1, "payload_json"))
+	edited.SetKey("payload_json", jsonw.NewString(payload))
+	// Link 1 re-signed with a changed payload, which link 2 doesn't follow.
+	resigned := b.signLink(payload)
+
+	bad := map[string]*jsonw.Wrapper{
+		"tail doesn't match the leaf": b.bundle([]*jsonw.Wrapper{l1, other}, 2, tail, hint),
+		"tail is past the last link":  b.bundle(b.links, 3, strings.Repeat("c", 64), hint),
+		"seqno gap":                   b.bundle([]*jsonw.Wrapper{l1, gap}, 3, b.get(gap, "payload_hash"), hint),
+		"tampered link":               b.bundle([]*jsonw.Wrapper{edited, l2}, 2, tail, hint),
+		"re-signed link":              b.bundle([]*jsonw.Wrapper{resigned, l2}, 2, tail, hint),
+		"hint for an unknown sig":     b.bundle(b.links, 2, tail, []string{b.get(other, "sig_id")}),
+	}
+	for name, jw := range bad {
+		if _, err := VerifySigBundle(jw); err == nil {
+			t.Errorf("%s: bundle verified", name)
+		}
+	}
+}
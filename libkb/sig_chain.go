@@ -105,30 +105,35 @@ func (sc *SigChain) Bump(mt MerkleTriple) {
 	sc.localChainUpdateTime = time.Now()
 }
 
-func (sc *SigChain) LoadFromServer(t *MerkleTriple) (dirtyTail *LinkSummary, err error) {
-
-	low := sc.GetLastLoadedSeqno()
-	uid_s := sc.uid.String()
-
-	G.Log.Debug("+ Load SigChain from server (uid=%s, low=%d)", uid_s, low)
-	defer func() { G.Log.Debug("- Loaded SigChain -> %s", ErrToOk(err)) }()
-
-	res, err := G.API.Get(ApiArg{
+// getServerLinks fetches the raw links in uid's sigchain after seqno low.
+func getServerLinks(uid UID, low Seqno) (v *jsonw.Wrapper, n int, err error) {
+	var res *ApiRes
+	res, err = G.API.Get(ApiArg{
 		Endpoint:    "sig/get",
 		NeedSession: false,
 		Args: HttpArgs{
-			"uid": S{uid_s},
+			"uid": S{uid.String()},
 			"low": I{int(low)},
 		},
 	})
-
 	if err != nil {
 		return
 	}
+	v = res.Body.AtKey("sigs")
+	n, err = v.Len()
+	return
+}
+
+func (sc *SigChain) LoadFromServer(t *MerkleTriple) (dirtyTail *LinkSummary, err error) {
+
+	low := sc.GetLastLoadedSeqno()
+	uid_s := sc.uid.String()
 
-	v := res.Body.AtKey("sigs")
-	var lim int
-	if lim, err = v.Len(); err != nil {
+	G.Log.Debug("+ Load SigChain from server (uid=%s, low=%d)", uid_s, low)
+	defer func() { G.Log.Debug("- Loaded SigChain -> %s", ErrToOk(err)) }()
+
+	v, lim, err := getServerLinks(sc.uid, low)
+	if err != nil {
 		return
 	}
 
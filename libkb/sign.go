@@ -6,10 +6,12 @@ import (
 	"crypto/sha256"
 	"golang.org/x/crypto/openpgp"
 	"golang.org/x/crypto/openpgp/armor"
+	"golang.org/x/crypto/openpgp/clearsign"
 	"golang.org/x/crypto/openpgp/errors"
 	"golang.org/x/crypto/openpgp/packet"
 	"hash"
 	"io"
+	"io/ioutil"
 	"time"
 )
 
@@ -133,6 +135,20 @@ func SimpleSign(payload []byte, key PgpKeyBundle) (out string, id *SigId, err er
 	return
 }
 
+// SIGN_HASH is the hash we use for all PGP signatures we make.
+var SIGN_HASH = crypto.SHA512
+
+// getSigner finds the private signing key in the given entity, and checks
+// that it's been unlocked.
+func getSigner(signed openpgp.Entity, config *packet.Config) (signer *packet.PrivateKey, err error) {
+	if signKey, ok := getSigningKey(&signed, config.Now()); !ok {
+		err = errors.InvalidArgumentError("no valid signing keys")
+	} else if signer = signKey.PrivateKey; signer.Encrypted {
+		err = errors.InvalidArgumentError("signing key must be decrypted")
+	}
+	return
+}
+
 // AttachedSign is like openpgp.Encrypt (as in p.crypto/openpgp/write.go), but
 // don't encrypt at all, just sign the literal unencrypted data.
 // Unfortunately we need to duplicate some code here that's already
@@ -149,16 +165,11 @@ func AttachedSign(out io.WriteCloser, signed openpgp.Entity, hints *openpgp.File
 	}
 
 	var signer *packet.PrivateKey
-
-	if signKey, ok := getSigningKey(&signed, config.Now()); !ok {
-		err = errors.InvalidArgumentError("no valid signing keys")
-		return
-	} else if signer = signKey.PrivateKey; signer.Encrypted {
-		err = errors.InvalidArgumentError("signing key must be decrypted")
+	if signer, err = getSigner(signed, config); err != nil {
 		return
 	}
 
-	hasher := crypto.SHA512
+	hasher := SIGN_HASH
 
 	ops := &packet.OnePassSignature{
 		SigType:    packet.SigTypeBinary,
@@ -218,7 +229,7 @@ func ArmoredAttachedSign(out io.WriteCloser, signed openpgp.Entity, hints *openp
 	config *packet.Config) (in io.WriteCloser, err error, h HashSummer) {
 
 	var aout io.WriteCloser
-	aout, err = armor.Encode(out, "PGP MESSAGE", PgpArmorHeaders())
+	aout, err = armor.Encode(out, "PGP MESSAGE", PgpArmorHeadersWithHash(SIGN_HASH))
 	if err != nil {
 		return
 	}
@@ -240,6 +251,64 @@ func AttachedSignWrapper(out io.WriteCloser, key PgpKeyBundle, armored bool) (
 	return
 }
 
+// DetachedSign hashes everything written to in, and when in is closed,
+// writes a signature packet over that data to out. Unlike openpgp.DetachSign,
+// the caller pushes data through as it comes, so large inputs stream.
+func DetachedSign(out io.WriteCloser, signed openpgp.Entity, config *packet.Config) (
+	in io.WriteCloser, err error) {
+
+	if config == nil {
+		config = &packet.Config{}
+	}
+
+	var signer *packet.PrivateKey
+	if signer, err = getSigner(signed, config); err != nil {
+		return
+	}
+
+	hasher := SIGN_HASH
+
+	// No literal data goes out with a detached signature, just the
+	// signature packet once we're done hashing.
+	in = signatureWriter{out, noOpCloser{ioutil.Discard}, hasher, hasher.New(), signer, config}
+	return
+}
+
+func ArmoredDetachedSign(out io.WriteCloser, signed openpgp.Entity, config *packet.Config) (
+	in io.WriteCloser, err error) {
+
+	var aout io.WriteCloser
+	aout, err = armor.Encode(out, "PGP SIGNATURE", PgpArmorHeadersWithHash(SIGN_HASH))
+	if err != nil {
+		return
+	}
+	in, err = DetachedSign(aout, signed, config)
+	return
+}
+
+func DetachedSignWrapper(out io.WriteCloser, key PgpKeyBundle, armored bool) (
+	in io.WriteCloser, err error) {
+
+	if armored {
+		in, err = ArmoredDetachedSign(out, openpgp.Entity(key), nil)
+	} else {
+		in, err = DetachedSign(out, openpgp.Entity(key), nil)
+	}
+	return
+}
+
+// ClearSignWrapper clearsigns everything written to in, and writes the
+// result to out. The hash algorithm goes into the "Hash:" armor header,
+// as the clearsign format requires.
+func ClearSignWrapper(out io.WriteCloser, key PgpKeyBundle) (in io.WriteCloser, err error) {
+	config := &packet.Config{DefaultHash: SIGN_HASH}
+	var signer *packet.PrivateKey
+	if signer, err = getSigner(openpgp.Entity(key), config); err != nil {
+		return
+	}
+	return clearsign.Encode(out, signer, config)
+}
+
 // From here:
 //   https://code.google.com/p/go/source/browse/openpgp/write.go?repo=crypto&r=1e7a3e301825bf9cb32e0535f3761d62d2d369d1#326
 //
@@ -0,0 +1,93 @@
+package libkb
+
+import (
+	"bytes"
+	"golang.org/x/crypto/openpgp"
+	"golang.org/x/crypto/openpgp/armor"
+	"golang.org/x/crypto/openpgp/clearsign"
+	"strings"
+	"testing"
+)
+
+func genSigningKey(t *testing.T) *PgpKeyBundle {
+	G.Init()
+	arg := KeyGenArg{
+		PrimaryBits: 1024,
+		SubkeyBits:  1024,
+		Ids:         Identities{{Username: "signer", Email: "signer@keybase.io"}},
+	}
+	if err := arg.Init(); err != nil {
+		t.Fatalf("arg init error: %s", err)
+	}
+	bundle, err := NewPgpKeyBundle(arg)
+	if err != nil {
+		t.Fatalf("bundle error: %s", err)
+	}
+	return bundle
+}
+
+var signMsg = "Ship it!\n- the release team\n"
+
+func TestDetachedSign(t *testing.T) {
+	key := genSigningKey(t)
+	for _, armored := range []bool{true, false} {
+		var out bytes.Buffer
+		in, err := DetachedSignWrapper(noOpCloser{&out}, *key, armored)
+		if err != nil {
+			t.Fatalf("armored=%v: sign error: %s", armored, err)
+		}
+		for _, s := range strings.SplitAfter(signMsg, "\n") {
+			in.Write([]byte(s))
+		}
+		if err = in.Close(); err != nil {
+			t.Fatalf("armored=%v: close error: %s", armored, err)
+		}
+
+		kr := openpgp.EntityList{(*openpgp.Entity)(key)}
+		if armored {
+			block, err := armor.Decode(bytes.NewReader(out.Bytes()))
+			if err != nil {
+				t.Fatalf("armor decode error: %s", err)
+			}
+			if block.Type != "PGP SIGNATURE" {
+				t.Errorf("bad armor type: %s", block.Type)
+			}
+			if h := block.Header["Hash"]; h != "SHA512" {
+				t.Errorf("bad Hash header: %q", h)
+			}
+			_, err = openpgp.CheckDetachedSignature(kr, strings.NewReader(signMsg), block.Body)
+		} else {
+			_, err = openpgp.CheckDetachedSignature(kr, strings.NewReader(signMsg), &out)
+		}
+		if err != nil {
+			t.Errorf("armored=%v: verify error: %s", armored, err)
+		}
+	}
+}
+
+func TestClearSign(t *testing.T) {
+	key := genSigningKey(t)
+	var out bytes.Buffer
+	in, err := ClearSignWrapper(noOpCloser{&out}, *key)
+	if err != nil {
+		t.Fatalf("sign error: %s", err)
+	}
+	in.Write([]byte(signMsg))
+	if err = in.Close(); err != nil {
+		t.Fatalf("close error: %s", err)
+	}
+	if !strings.Contains(out.String(), "Hash: SHA512\n") {
+		t.Errorf("missing Hash header in clearsigned message")
+	}
+	block, _ := clearsign.Decode(out.Bytes())
+	if block == nil {
+		t.Fatalf("failed to decode clearsigned message")
+	}
+	kr := openpgp.EntityList{(*openpgp.Entity)(key)}
+	if _, err = openpgp.CheckDetachedSignature(kr, bytes.NewReader(block.Bytes), block.ArmoredSignature.Body); err != nil {
+		t.Errorf("verify error: %s", err)
+	}
+	if string(block.Plaintext) != signMsg {
+		t.Errorf("bad plaintext: %q", block.Plaintext)
+	}
+}
@@ -346,6 +346,12 @@ type TrackEngine struct {
 	StrictProofs bool
 	MeRequired   bool
 
+	// LocalOnly keeps the tracking statement on this machine, and never
+	// posts it. If ExpireIn is set, the local statement lapses after that
+	// long, and the next identify of them starts from scratch.
+	LocalOnly bool
+	ExpireIn  time.Duration
+
 	trackStatementBytes []byte
 	trackStatement      *jsonw.Wrapper
 	signingKeyPriv      GenericKey
@@ -444,6 +450,9 @@ func (e *TrackEngine) Run() (err error) {
 	if err != nil {
 		return
 	}
+	if e.LocalOnly || e.ExpireIn > 0 {
+		ti.Local, ti.Remote = true, false
+	}
 
 	if err = e.GetSigningKeyPub(); err != nil {
 		return
@@ -452,6 +461,9 @@ func (e *TrackEngine) Run() (err error) {
 	if e.trackStatement, err = e.Me.TrackingProofFor(e.signingKeyPub, e.Them); err != nil {
 		return
 	}
+	if e.ExpireIn > 0 {
+		e.trackStatement.SetKey("expire_in", jsonw.NewInt64(int64(e.ExpireIn.Seconds())))
+	}
 
 	if e.trackStatementBytes, err = e.trackStatement.Marshal(); err != nil {
 		return
@@ -509,6 +521,10 @@ func GetLocalTrack(i UID) (ret *TrackChainLink, err error) {
 		return
 	}
 	base := GenericChainLink{cl}
+	if etime := base.GetETime(); time.Now().After(etime) {
+		G.Log.Debug("| Local track expired at %s", FormatTime(etime))
+		return
+	}
 	ret, err = ParseTrackChainLink(base)
 	if ret != nil && err == nil {
 		ret.local = true
@@ -530,6 +546,11 @@ func StoreLocalTrack(id UID, statement *jsonw.Wrapper) error {
 	)
 }
 
+func RemoveLocalTrack(id UID) error {
+	G.Log.Debug("| RemoveLocalTrack")
+	return G.LocalDb.Delete(DbKey{Typ: DB_LOCAL_TRACK, Key: id.String()})
+}
+
 func (e *TrackEngine) StoreRemoteTrack() (err error) {
 	G.Log.Debug("+ StoreRemoteTrack")
 	defer G.Log.Debug("- StoreRemoteTrack -> %s", ErrToOk(err))
@@ -0,0 +1,188 @@
+package libkb
+
+import (
+	"github.com/keybase/go-jsonw"
+	"testing"
+	"time"
+)
+
+// memLocalDb is a LocalDb that lives in memory, for tests that need
+// G.LocalDb but not a real database.
+type memLocalDb map[string][]byte
+
+func (m memLocalDb) Open() error  { return nil }
+func (m memLocalDb) Close() error { return nil }
+func (m memLocalDb) Nuke() error  { return nil }
+
+func (m memLocalDb) Put(id DbKey, aliases []DbKey, value []byte) error {
+	m[id.ToString("mem")] = value
+	return nil
+}
+
+func (m memLocalDb) Delete(id DbKey) error {
+	delete(m, id.ToString("mem"))
+	return nil
+}
+
+func (m memLocalDb) Get(id DbKey) ([]byte, bool, error) {
+	v, found := m[id.ToString("mem")]
+	return v, found, nil
+}
+
+func (m memLocalDb) Lookup(alias DbKey) ([]byte, bool, error) {
+	return nil, false, nil
+}
+
+// useMemLocalDb swaps in an empty memLocalDb for G.LocalDb, and returns
+// a func to put the old one back.
+func useMemLocalDb() func() {
+	old := G.LocalDb
+	G.LocalDb = NewJsonLocalDb(memLocalDb{})
+	return func() { G.LocalDb = old }
+}
+
+// newTrackTestUser makes a user with an empty sigchain and a single live
+// NaCl sibkey, which it returns for signing.
+func newTrackTestUser(t *testing.T, name, uid string) (*User, GenericKey) {
+	key, err := GenerateNaclSigningKeyPair()
+	if err != nil {
+		t.Fatal(err)
+	}
+	id, err := UidFromHex(uid)
+	if err != nil {
+		t.Fatal(err)
+	}
+	kid := key.GetKid()
+	kf := &KeyFamily{
+		eldest:  &FOKID{Kid: kid},
+		Sibkeys: KeyMap{kid.String(): &ServerKeyRecord{Kid: kid.String()}},
+	}
+	cki := &ComputedKeyInfos{
+		Infos: map[string]*ComputedKeyInfo{
+			kid.String(): {Status: KEY_LIVE, Eldest: true, Sibkey: true},
+		},
+	}
+	u := &User{
+		basics:    jsonw.NewDictionary(),
+		sigs:      jsonw.NewDictionary(),
+		id:        *id,
+		name:      name,
+		sigChain:  &SigChain{localCki: cki},
+		IdTable:   &IdentityTable{},
+		keyFamily: kf,
+	}
+	return u, key
+}
+
+func TestUntrackingProofFor(t *testing.T) {
+	me, key := newTrackTestUser(t, "max", "9f9611a4b7920637b1c2a839b2a0e100")
+	them, theirKey := newTrackTestUser(t, "chris", "23260c2ce19420f97b58d7d95b68ca00")
+
+	stmt, err := me.UntrackingProofFor(key, them)
+	if err != nil {
+		t.Fatal(err)
+	}
+	body := stmt.AtKey("body")
+	checks := map[string]string{
+		"type":                    "untrack",
+		"key.uid":                 me.id.String(),
+		"key.kid":                 key.GetKid().String(),
+		"untrack.basics.username": "chris",
+		"untrack.id":              them.id.String(),
+		"untrack.key.kid":         theirKey.GetKid().String(),
+	}
+	for path, want := range checks {
+		if got, err := body.AtPath(path).GetString(); err != nil {
+			t.Errorf("body.%s: %s", path, err)
+		} else if got != want {
+			t.Errorf("body.%s: got %q, wanted %q", path, got, want)
+		}
+	}
+	if v, err := body.AtKey("version").GetInt(); err != nil || v != KEYBASE_SIGNATURE_V1 {
+		t.Errorf("bad version: %d, %v", v, err)
+	}
+	for _, k := range []string{"track", "untrack.seq_tail", "untrack.remote_proofs"} {
+		if !body.AtPath(k).IsNil() {
+			t.Errorf("untrack statement has a body.%s", k)
+		}
+	}
+
+	them.keyFamily = nil
+	if _, err := me.UntrackingProofFor(key, them); err == nil {
+		t.Errorf("untracked a user with no active key")
+	}
+}
+
+func TestRemoveLocalTrack(t *testing.T) {
+	defer useMemLocalDb()()
+	me, key := newTrackTestUser(t, "max", "9f9611a4b7920637b1c2a839b2a0e100")
+	them, _ := newTrackTestUser(t, "chris", "23260c2ce19420f97b58d7d95b68ca00")
+
+	stmt, err := me.TrackingProofFor(key, them)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := StoreLocalTrack(them.id, stmt); err != nil {
+		t.Fatal(err)
+	}
+	link, err := GetLocalTrack(them.id)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if link == nil || !link.local || link.whom != "chris" {
+		t.Fatalf("didn't get the local track back: %+v", link)
+	}
+
+	if err := RemoveLocalTrack(them.id); err != nil {
+		t.Fatal(err)
+	}
+	if link, err := GetLocalTrack(them.id); err != nil || link != nil {
+		t.Errorf("local track still there after RemoveLocalTrack: %+v, %v", link, err)
+	}
+	// Removing it again is harmless.
+	if err := RemoveLocalTrack(them.id); err != nil {
+		t.Errorf("second RemoveLocalTrack: %s", err)
+	}
+}
+
+func TestGetLocalTrackExpiry(t *testing.T) {
+	defer useMemLocalDb()()
+	me, key := newTrackTestUser(t, "max", "9f9611a4b7920637b1c2a839b2a0e100")
+	them, _ := newTrackTestUser(t, "chris", "23260c2ce19420f97b58d7d95b68ca00")
+
+	// store puts a local track made two hours ago, which lapses after
+	// expireIn seconds.
+	store := func(expireIn int64) (ctime int64) {
+		stmt, err := me.TrackingProofFor(key, them)
+		if err != nil {
+			t.Fatal(err)
+		}
+		ctime = time.Now().Add(-2 * time.Hour).Unix()
+		stmt.SetKey("ctime", jsonw.NewInt64(ctime))
+		stmt.SetKey("expire_in", jsonw.NewInt64(expireIn))
+		if err := StoreLocalTrack(them.id, stmt); err != nil {
+			t.Fatal(err)
+		}
+		return
+	}
+
+	store(3600)
+	if link, err := GetLocalTrack(them.id); err != nil || link != nil {
+		t.Errorf("got back an expired local track: %+v, %v", link, err)
+	}
+
+	ctime := store(3 * 3600)
+	link, err := GetLocalTrack(them.id)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if link == nil {
+		t.Fatalf("lost a local track that hasn't expired")
+	}
+	if etime := link.GetETime().Unix(); etime != ctime+3*3600 {
+		t.Errorf("etime is %d, wanted ctime + expire_in = %d", etime, ctime+3*3600)
+	}
+	if NewTrackLookup(link).IsRemote() {
+		t.Errorf("local track looks remote")
+	}
+}
@@ -0,0 +1,127 @@
+package libkb
+
+import (
+	"github.com/keybase/go-jsonw"
+)
+
+type UntrackEngine struct {
+	TheirName string
+	Them      *User
+	Me        *User
+
+	untrackStatement *jsonw.Wrapper
+	signingKeyPriv   GenericKey
+	secretUI         SecretUI
+	logUI            LogUI
+}
+
+// NewUntrackEngine creates an UntrackEngine to stop tracking theirName.
+func NewUntrackEngine(theirName string, sui SecretUI, lui LogUI) *UntrackEngine {
+	return &UntrackEngine{
+		TheirName: theirName,
+		secretUI:  sui,
+		logUI:     lui,
+	}
+}
+
+func (e *UntrackEngine) SecretUI() SecretUI {
+	if e.secretUI == nil {
+		e.secretUI = G.UI.GetSecretUI()
+	}
+	return e.secretUI
+}
+
+func (e *UntrackEngine) LogUI() LogUI {
+	if e.logUI == nil {
+		e.logUI = G.UI.GetLogUI()
+	}
+	return e.logUI
+}
+
+func (e *UntrackEngine) Run() (err error) {
+	G.Log.Debug("+ UntrackEngine.Run(%s)", e.TheirName)
+	defer func() {
+		G.Log.Debug("- UntrackEngine.Run -> %s", ErrToOk(err))
+	}()
+
+	if e.Them == nil {
+		if e.Them, err = LoadUser(LoadUserArg{Name: e.TheirName}); err != nil {
+			return
+		}
+	}
+	if e.Me == nil {
+		if e.Me, err = LoadMe(LoadUserArg{}); err != nil {
+			return
+		}
+	}
+	if e.Me.Equal(*e.Them) {
+		return SelfTrackError{}
+	}
+
+	uid := e.Them.GetUid()
+	var remote, local *TrackChainLink
+	if remote, err = e.Me.GetRemoteTrackingStatementFor(e.Them.GetName(), uid); err != nil {
+		return
+	}
+	if local, err = GetLocalTrack(uid); err != nil {
+		return
+	}
+	if remote == nil && local == nil {
+		return NotTrackingError{e.Them.GetName()}
+	}
+
+	if remote != nil {
+		if err = e.StoreRemoteUntrack(); err != nil {
+			return
+		}
+	}
+	if local != nil {
+		if err = RemoveLocalTrack(uid); err != nil {
+			return
+		}
+	}
+	e.LogUI().Info("Untracked %s", e.Them.GetName())
+	return
+}
+
+// StoreRemoteUntrack signs an untrack statement and posts it to our
+// sigchain, which retires our remote tracking statement for them.
+func (e *UntrackEngine) StoreRemoteUntrack() (err error) {
+	G.Log.Debug("+ StoreRemoteUntrack")
+	defer G.Log.Debug("- StoreRemoteUntrack -> %s", ErrToOk(err))
+
+	if e.signingKeyPriv, err = G.Keyrings.GetSecretKey("untracking signature", e.SecretUI()); err != nil {
+		return
+	} else if e.signingKeyPriv == nil {
+		err = NoSecretKeyError{}
+		return
+	}
+
+	if e.untrackStatement, err = e.Me.UntrackingProofFor(e.signingKeyPriv, e.Them); err != nil {
+		return
+	}
+	G.Log.Debug("| Untracking statement: %s", e.untrackStatement.MarshalToDebug())
+
+	var sig string
+	var sigid *SigId
+	var lid LinkId
+	if sig, sigid, lid, err = SignJson(e.untrackStatement, e.signingKeyPriv); err != nil {
+		return
+	}
+
+	_, err = G.API.Post(ApiArg{
+		Endpoint:    "follow",
+		NeedSession: true,
+		Args: HttpArgs{
+			"sig_id_base":  S{sigid.ToString(false)},
+			"sig_id_short": S{sigid.ToShortId()},
+			"sig":          S{sig},
+			"uid":          S{e.Them.GetUid().String()},
+			"type":         S{"untrack"},
+		},
+	})
+	if err == nil {
+		e.Me.sigChain.Bump(MerkleTriple{linkId: lid, sigId: sigid})
+	}
+	return
+}
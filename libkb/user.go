@@ -280,6 +280,25 @@ func (u *User) GetComputedKeyFamily() (ret *ComputedKeyFamily) {
 	return
 }
 
+// RequireComputedKeyFamily is like GetComputedKeyFamily, but fails with
+// a NoKeyError if the user has no keys.
+func (u *User) RequireComputedKeyFamily() (ret *ComputedKeyFamily, err error) {
+	if ret = u.GetComputedKeyFamily(); ret == nil {
+		err = NoKeyError{fmt.Sprintf("%s has no active keys", u.GetName())}
+	}
+	return
+}
+
+// GetComputedKeyFamilyAt replays the user's sigchain up to the given
+// point, to find which keys were active then.
+func (u *User) GetComputedKeyFamilyAt(q KeyStateQuery) (ret *ComputedKeyFamily, err error) {
+	var ckf *ComputedKeyFamily
+	if ckf, err = u.RequireComputedKeyFamily(); err != nil {
+		return
+	}
+	return ckf.ReplayTo(u.sigChain.LimitToKeyFamily(u.keyFamily), q)
+}
+
 // GetActivePgpKeys looks into the user's ComputedKeyFamily and
 // returns only the active PGP keys.  If you want only sibkeys, then
 // specify sibkey=true.
@@ -290,6 +309,15 @@ func (u User) GetActivePgpKeys(sibkey bool) (ret []*PgpKeyBundle) {
 	return
 }
 
+// GetActiveNaclDHKeys looks into the user's ComputedKeyFamily and
+// returns the active NaCl DH keys, one per device.
+func (u User) GetActiveNaclDHKeys() (ret []NaclDHKeyPair) {
+	if ckf := u.GetComputedKeyFamily(); ckf != nil {
+		ret = ckf.GetActiveNaclDHKeys()
+	}
+	return
+}
+
 // GetActivePgpKeys looks into the user's ComputedKeyFamily and
 // returns only the fingerprint of the active PGP keys.
 // If you want only sibkeys, then // specify sibkey=true.
@@ -467,6 +495,19 @@ func (u *User) GetEldestFOKID() (ret *FOKID) {
 	return u.keyFamily.eldest
 }
 
+// GetEldestKey gets the user's eldest key, which is the "primary" key
+// we name when we post a new key.
+func (u *User) GetEldestKey() (key GenericKey, err error) {
+	if fokid := u.GetEldestFOKID(); fokid == nil {
+		err = NoKeyError{"Expected a key but didn't find one"}
+	} else if ckf, e2 := u.RequireComputedKeyFamily(); e2 != nil {
+		err = e2
+	} else {
+		key, err = ckf.FindActiveSibkey(*fokid)
+	}
+	return
+}
+
 func (u *User) MakeIdTable() (err error) {
 	if fokid := u.GetEldestFOKID(); fokid == nil {
 		err = NoKeyError{"Expected a key but didn't find one"}
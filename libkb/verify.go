@@ -0,0 +1,458 @@
+package libkb
+
+import (
+	"bufio"
+	"bytes"
+	"fmt"
+	"golang.org/x/crypto/openpgp"
+	"golang.org/x/crypto/openpgp/armor"
+	"golang.org/x/crypto/openpgp/clearsign"
+	"golang.org/x/crypto/openpgp/packet"
+	"io"
+	"io/ioutil"
+	"time"
+)
+
+//=============================================================================
+
+// SignerKeyRing is an openpgp.KeyRing that resolves the issuer key ID
+// of a signature to a Keybase user. It asks the server who owns the key,
+// loads that user, and only hands back the key to openpgp if it's among
+// the active PGP keys in the user's ComputedKeyFamily.
+type SignerKeyRing struct {
+	Owner *User
+	Key   *PgpKeyBundle
+	err   error
+
+	// If set, the key has to have been active at this point in the
+	// owner's sigchain, rather than now.  See CheckSigTime.
+	At *KeyStateQuery
+}
+
+// LookupPgpKeyOwner asks the server which user has the PGP key with the
+// given 64-bit key ID in their key family.
+func LookupPgpKeyOwner(id uint64) (uid *UID, err error) {
+	var res *ApiRes
+	res, err = G.API.Get(ApiArg{
+		Endpoint:    "key/fetch",
+		NeedSession: false,
+		Args: HttpArgs{
+			"pgp_key_ids": S{fmt.Sprintf("%016x", id)},
+			"ops":         I{PGP_OP_VERIFY},
+		},
+	})
+	if err != nil {
+		return
+	}
+	var n int
+	keys := res.Body.AtKey("keys")
+	if n, err = keys.Len(); err != nil {
+		return
+	} else if n == 0 {
+		err = NoKeyError{fmt.Sprintf("No Keybase user has PGP key ID %016X", id)}
+		return
+	}
+	return GetUid(keys.AtIndex(0).AtKey("uid"))
+}
+
+func (k *SignerKeyRing) load(id uint64) {
+	if k.Owner != nil {
+		return
+	}
+	var uid *UID
+	if uid, k.err = LookupPgpKeyOwner(id); k.err != nil {
+		return
+	}
+	if k.Owner, k.err = LoadUser(LoadUserArg{Uid: uid, AllKeys: (k.At != nil)}); k.err != nil {
+		return
+	}
+	var active []*PgpKeyBundle
+	if active, k.err = k.activePgpKeys(k.At); k.err != nil {
+		return
+	}
+	for _, pgp := range active {
+		if len(pgp.KeysById(id)) > 0 {
+			k.Key = pgp
+			return
+		}
+	}
+	k.err = k.revokedError(fmt.Sprintf("%016X", id), k.At)
+}
+
+// activePgpKeys returns the owner's PGP keys that were active at q, or
+// that are active now, if q is nil.
+func (k *SignerKeyRing) activePgpKeys(q *KeyStateQuery) (ret []*PgpKeyBundle, err error) {
+	if q == nil {
+		return k.Owner.GetActivePgpKeys(false), nil
+	}
+	var ckf *ComputedKeyFamily
+	if ckf, err = k.Owner.GetComputedKeyFamilyAt(*q); err == nil {
+		ret = ckf.GetActivePgpKeys(false)
+	}
+	return
+}
+
+func (k *SignerKeyRing) revokedError(id string, q *KeyStateQuery) error {
+	var when string
+	if q != nil {
+		when = " as of " + q.String()
+	}
+	return KeyRevokedError{fmt.Sprintf("PGP key ID %s isn't active for %s%s",
+		id, k.Owner.GetName(), when)}
+}
+
+// CheckSigTime checks the creation time of a good signature against At,
+// since the signer picks that time, not us.  A signature made after At
+// is rejected, as the key's state at At can't vouch for it.  If At only
+// gives a seqno, the key also has to have been active when the signature
+// was made, and At is narrowed down to that time.
+func (k *SignerKeyRing) CheckSigTime(created time.Time) (err error) {
+	if k.At == nil {
+		return
+	}
+	if !k.At.Time.IsZero() {
+		if created.After(k.At.Time) {
+			err = BadSigError{fmt.Sprintf("Signature was made at %s, after %s",
+				FormatTime(created), FormatTime(k.At.Time))}
+		}
+		return
+	}
+	if k.Owner == nil || k.Key == nil {
+		return NoKeyError{"No signer was found"}
+	}
+	q := &KeyStateQuery{Seqno: k.At.Seqno, Time: created}
+	var active []*PgpKeyBundle
+	if active, err = k.activePgpKeys(q); err != nil {
+		return
+	}
+	for _, pgp := range active {
+		if pgp.GetFingerprint().Eq(k.Key.GetFingerprint()) {
+			k.At = q
+			return
+		}
+	}
+	return k.revokedError(k.Key.PrimaryKey.KeyIdString(), q)
+}
+
+func (k *SignerKeyRing) KeysById(id uint64) []openpgp.Key {
+	if k.load(id); k.Key == nil {
+		return nil
+	}
+	return k.Key.KeysById(id)
+}
+
+func (k *SignerKeyRing) KeysByIdUsage(id uint64, usage byte) []openpgp.Key {
+	if k.load(id); k.Key == nil {
+		return nil
+	}
+	return k.Key.KeysByIdUsage(id, usage)
+}
+
+func (k *SignerKeyRing) DecryptionKeys() []openpgp.Key {
+	return nil
+}
+
+// Error returns why the signer couldn't be resolved, if it couldn't be.
+func (k *SignerKeyRing) Error() error {
+	return k.err
+}
+
+// IdentifySigner reports the owner of a key that made a good signature,
+// and runs an identify on them so the caller can see if their proofs
+// still hold.
+func (k *SignerKeyRing) IdentifySigner(ui IdentifyUI, lui LogUI) (res *VerifyRes, err error) {
+	if k.Owner == nil || k.Key == nil {
+		return nil, NoKeyError{"No signer was found"}
+	}
+	return identifySigner(k.Owner, k.Key, ui, lui)
+}
+
+func identifySigner(signer *User, key GenericKey, ui IdentifyUI, lui LogUI) (res *VerifyRes, err error) {
+	var desc string
+	if fp := key.GetFingerprintP(); fp != nil {
+		desc = "PGP key " + fp.ToQuads()
+	} else {
+		desc = "key " + key.GetKid().ToShortIdString()
+	}
+	lui.Info("Good signature from keybase user %s (%s)", signer.GetName(), desc)
+
+	if ui == nil {
+		ui = G.UI.GetIdentifyUI(signer.GetName())
+	}
+	ui.SetUsername(signer.GetName())
+
+	res = &VerifyRes{Signer: signer, Key: key}
+	res.Outcome, err = signer.IdentifySimple(nil, ui)
+	return
+}
+
+//=============================================================================
+
+type VerifyArg struct {
+	// Message is either an attached (armored or binary) or clearsigned
+	// PGP message, or a signed NaCl stream, or, if Signature is set, the
+	// data that the detached signature is over.
+	Message io.Reader
+
+	// Signature is a detached PGP signature, armored or binary, or an
+	// armored NaclSig packet. Leave it nil for attached and clearsigned
+	// messages.
+	Signature io.Reader
+
+	// Out gets the signed payload of an attached or clearsigned message.
+	// It may be nil.  Attached messages and signed NaCl streams are
+	// written out as they're read, before their signatures are checked,
+	// so if Run fails, Out may have part of the payload, which the caller
+	// must throw away.
+	Out io.Writer
+
+	// At, if set, checks that the signing key was active at that point
+	// in the signer's sigchain, rather than now, say for an old release
+	// signed with a key that's since been revoked.  PGP signatures must
+	// have been made by At, too; NaCl ones don't say when they were made,
+	// so for them, At is taken on trust.
+	At *KeyStateQuery
+
+	IdentifyUI IdentifyUI
+	LogUI      LogUI
+}
+
+type VerifyRes struct {
+	Signer  *User
+	Key     GenericKey
+	Outcome *IdentifyOutcome
+}
+
+// VerifyEngine checks a PGP or NaCl signature, finds the Keybase user who
+// made it, and identifies them.
+type VerifyEngine struct {
+	arg  *VerifyArg
+	ring SignerKeyRing
+
+	// Set instead of ring for NaCl signatures
+	naclOwner *User
+	naclKey   GenericKey
+}
+
+func NewVerifyEngine(arg *VerifyArg) *VerifyEngine {
+	return &VerifyEngine{arg: arg, ring: SignerKeyRing{At: arg.At}}
+}
+
+func (e *VerifyEngine) Run() (res *VerifyRes, err error) {
+	G.Log.Debug("+ VerifyEngine.Run")
+	defer func() {
+		G.Log.Debug("- VerifyEngine.Run -> %s", ErrToOk(err))
+	}()
+
+	if e.arg.LogUI == nil {
+		e.arg.LogUI = G.UI.GetLogUI()
+	}
+
+	if e.arg.Signature != nil {
+		err = e.checkDetached()
+	} else {
+		err = e.checkAttached()
+	}
+	if err != nil {
+		return
+	}
+	if e.ring.At != nil {
+		e.arg.LogUI.Info("The signing key was active as of %s", e.ring.At)
+	}
+
+	if e.naclOwner != nil {
+		return identifySigner(e.naclOwner, e.naclKey, e.arg.IdentifyUI, e.arg.LogUI)
+	}
+	return e.ring.IdentifySigner(e.arg.IdentifyUI, e.arg.LogUI)
+}
+
+// sigError picks the most useful error to report when openpgp
+// failed to find a signer.
+func (e *VerifyEngine) sigError(err error) error {
+	if e.ring.Key == nil && e.ring.Error() != nil {
+		return e.ring.Error()
+	}
+	return err
+}
+
+func (e *VerifyEngine) checkDetached() (err error) {
+	sig := bufio.NewReader(e.arg.Signature)
+	if isNaclArmored(sig) {
+		return e.checkNaclDetached(sig)
+	}
+	var r io.Reader = sig
+	if isArmored(sig) {
+		var block *armor.Block
+		if block, err = armor.Decode(sig); err != nil {
+			return
+		}
+		r = block.Body
+	}
+	var raw []byte
+	if raw, err = ioutil.ReadAll(r); err != nil {
+		return
+	}
+	return e.checkPgpDetached(e.arg.Message, raw)
+}
+
+// checkPgpDetached checks a binary, detached PGP signature over the data
+// read from msg, and then its creation time.
+func (e *VerifyEngine) checkPgpDetached(msg io.Reader, sig []byte) (err error) {
+	if _, err = openpgp.CheckDetachedSignature(&e.ring, msg, bytes.NewReader(sig)); err != nil {
+		return e.sigError(err)
+	}
+	var created time.Time
+	if created, err = pgpSigCreationTime(sig); err != nil {
+		return
+	}
+	return e.ring.CheckSigTime(created)
+}
+
+// pgpSigCreationTime reads the creation time out of the signature packet
+// at the start of sig.
+func pgpSigCreationTime(sig []byte) (ret time.Time, err error) {
+	var p packet.Packet
+	if p, err = packet.Read(bytes.NewReader(sig)); err != nil {
+		return
+	}
+	switch s := p.(type) {
+	case *packet.Signature:
+		ret = s.CreationTime
+	case *packet.SignatureV3:
+		ret = s.CreationTime
+	default:
+		err = BadSigError{"expected a signature packet"}
+	}
+	return
+}
+
+func (e *VerifyEngine) checkAttached() (err error) {
+	in := bufio.NewReader(e.arg.Message)
+	var peek []byte
+	if peek, err = in.Peek(len(clearsignHeader)); err == nil && bytes.Equal(peek, clearsignHeader) {
+		return e.checkClearsigned(in)
+	}
+	if isNaclStream(in) {
+		return e.checkNaclStream(in)
+	}
+
+	var body io.Reader = in
+	if isArmored(in) {
+		var block *armor.Block
+		if block, err = armor.Decode(in); err != nil {
+			return
+		}
+		if block.Type == NACL_SIGNED_ARMOR {
+			return e.checkNaclStream(block.Body)
+		}
+		body = block.Body
+	}
+
+	var md *openpgp.MessageDetails
+	if md, err = openpgp.ReadMessage(body, &e.ring, nil, nil); err != nil {
+		return e.sigError(err)
+	}
+	if !md.IsSigned {
+		return NotSignedError{}
+	}
+	if md.SignedBy == nil {
+		return e.sigError(NoKeyError{fmt.Sprintf("No key found for signer ID %016X", md.SignedByKeyId)})
+	}
+
+	// The signature is only checked once the literal data has been
+	// read through to EOF, by which point the payload has gone out, so
+	// as with a NaCl stream, the caller must discard Out on error.
+	if err = e.writeStream(md.UnverifiedBody); err != nil {
+		return
+	}
+	if md.SignatureError != nil {
+		return md.SignatureError
+	}
+	return e.ring.CheckSigTime(md.Signature.CreationTime)
+}
+
+func (e *VerifyEngine) checkClearsigned(in io.Reader) (err error) {
+	var data []byte
+	if data, err = ioutil.ReadAll(in); err != nil {
+		return
+	}
+	if !hasClearsignSig(data) {
+		return fmt.Errorf("Clearsigned message has no signature")
+	}
+	block, _ := clearsign.Decode(data)
+	if block == nil {
+		return fmt.Errorf("Failed to decode clearsigned message")
+	}
+	var sig []byte
+	if sig, err = ioutil.ReadAll(block.ArmoredSignature.Body); err != nil {
+		return
+	}
+	if err = e.checkPgpDetached(bytes.NewReader(block.Bytes), sig); err != nil {
+		return
+	}
+	if e.arg.Out != nil {
+		_, err = e.arg.Out.Write(block.Plaintext)
+	}
+	return
+}
+
+func (e *VerifyEngine) checkNaclDetached(sig io.Reader) (err error) {
+	var armored []byte
+	var kid KID
+	if armored, err = ioutil.ReadAll(sig); err != nil {
+		return
+	}
+	if kid, err = VerifyNaclDetached(e.arg.Message, string(armored)); err != nil {
+		return
+	}
+	e.naclOwner, e.naclKey, err = LoadSibkeyOwnerAt(kid, e.arg.At)
+	return
+}
+
+// checkNaclStream finds the stream's signer before reading the payload,
+// so a signature by an unknown or revoked key fails early.  The payload
+// goes out a chunk at a time, as each one verifies, so that streams too
+// big for memory can be checked; a tampered or truncated stream is only
+// caught when we get to the bad chunk, though, so as with decryption, the
+// caller must discard the output on error.
+func (e *VerifyEngine) checkNaclStream(in io.Reader) (err error) {
+	var r io.Reader
+	var hdr *NaclStreamHeader
+	if r, hdr, err = NewNaclVerifyStream(in); err != nil {
+		return
+	}
+	if e.naclOwner, e.naclKey, err = LoadSibkeyOwnerAt(hdr.Kid, e.arg.At); err != nil {
+		return
+	}
+	return e.writeStream(r)
+}
+
+// writeStream copies the payload from r to Out, if there is one, without
+// holding it in memory.
+func (e *VerifyEngine) writeStream(r io.Reader) (err error) {
+	out := e.arg.Out
+	if out == nil {
+		out = ioutil.Discard
+	}
+	_, err = io.Copy(out, r)
+	return
+}
+
+var clearsignHeader = []byte("-----BEGIN PGP SIGNED MESSAGE-----")
+var clearsignSigHeader = []byte("-----BEGIN PGP SIGNATURE-----")
+
+// hasClearsignSig checks for the line that ends the text of a clearsigned
+// message, since our clearsign.Decode loops forever without one.
+func hasClearsignSig(data []byte) bool {
+	for _, line := range bytes.Split(data, []byte("\n")) {
+		if bytes.Equal(bytes.TrimSuffix(line, []byte("\r")), clearsignSigHeader) {
+			return true
+		}
+	}
+	return false
+}
+
+func isArmored(r *bufio.Reader) bool {
+	peek, _ := r.Peek(len("-----BEGIN"))
+	return bytes.Equal(peek, []byte("-----BEGIN"))
+}
@@ -0,0 +1,202 @@
+package libkb
+
+import (
+	"bytes"
+	"fmt"
+	"golang.org/x/crypto/openpgp"
+	"golang.org/x/crypto/openpgp/armor"
+	"golang.org/x/crypto/openpgp/packet"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestCheckSigTime(t *testing.T) {
+	at := time.Date(2015, 6, 1, 0, 0, 0, 0, time.UTC)
+	ring := SignerKeyRing{At: &KeyStateQuery{Time: at}}
+	if err := ring.CheckSigTime(at.Add(-time.Hour)); err != nil {
+		t.Errorf("signature made before --at rejected: %s", err)
+	}
+	if err := ring.CheckSigTime(at.Add(time.Hour)); err == nil {
+		t.Errorf("signature made after --at accepted")
+	} else if _, ok := err.(BadSigError); !ok {
+		t.Errorf("expected a BadSigError; got %s", err)
+	}
+
+	// Without --at, the key has to be active now, whenever the
+	// signature says it was made.
+	ring.At = nil
+	if err := ring.CheckSigTime(at.Add(time.Hour)); err != nil {
+		t.Errorf("signature time checked without --at: %s", err)
+	}
+}
+
+func TestPgpSigCreationTime(t *testing.T) {
+	key := genSigningKey(t)
+	created := time.Unix(1430000000, 0)
+	var sig bytes.Buffer
+	config := &packet.Config{Time: func() time.Time { return created }}
+	if err := openpgp.DetachSign(&sig, (*openpgp.Entity)(key), strings.NewReader("msg"), config); err != nil {
+		t.Fatalf("sign error: %s", err)
+	}
+	tm, err := pgpSigCreationTime(sig.Bytes())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !tm.Equal(created) {
+		t.Errorf("expected creation time %s; got %s", created, tm)
+	}
+
+	if _, err = pgpSigCreationTime([]byte("not a signature")); err == nil {
+		t.Errorf("read a creation time out of garbage")
+	}
+}
+
+func TestVerifyUnsignedMessage(t *testing.T) {
+	var bin bytes.Buffer
+	w, err := packet.SerializeLiteral(noOpCloser{&bin}, true, "", 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	w.Write([]byte("nobody signed this"))
+	w.Close()
+
+	var arm bytes.Buffer
+	aw, err := armor.Encode(&arm, "PGP MESSAGE", nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	aw.Write(bin.Bytes())
+	aw.Close()
+
+	for _, msg := range [][]byte{bin.Bytes(), arm.Bytes()} {
+		var out bytes.Buffer
+		e := NewVerifyEngine(&VerifyArg{Message: bytes.NewReader(msg), Out: &out})
+		if err = e.checkAttached(); err == nil {
+			t.Errorf("unsigned message verified")
+		} else if _, ok := err.(NotSignedError); !ok {
+			t.Errorf("expected a NotSignedError; got %s", err)
+		}
+		if out.Len() != 0 {
+			t.Errorf("unsigned payload was written out")
+		}
+	}
+
+	for _, sig := range []string{"", "-----BEGIN PGP SIGNATURE----- not quite\n"} {
+		bad := "-----BEGIN PGP SIGNED MESSAGE-----\nHash: SHA256\n\nno signature here\n" + sig
+		e := NewVerifyEngine(&VerifyArg{Message: strings.NewReader(bad)})
+		if err = e.checkAttached(); err == nil {
+			t.Errorf("clearsigned message without a signature verified")
+		}
+	}
+}
+
+func TestVerifySigError(t *testing.T) {
+	e := NewVerifyEngine(&VerifyArg{})
+	generic := fmt.Errorf("openpgp: signature made by unknown entity")
+	if err := e.sigError(generic); err != generic {
+		t.Errorf("expected openpgp's error when there's nothing better; got %s", err)
+	}
+
+	// If we couldn't resolve the signer, say why, rather than openpgp's
+	// "unknown entity".
+	revoked := KeyRevokedError{"PGP key ID 0123456789ABCDEF isn't active for max"}
+	e.ring.err = revoked
+	if err := e.sigError(generic); err != revoked {
+		t.Errorf("expected the key ring's error; got %s", err)
+	}
+}
+
+// countingWriter counts the bytes written to it, and throws them away.
+type countingWriter struct {
+	n int64
+}
+
+func (w *countingWriter) Write(p []byte) (int, error) {
+	w.n += int64(len(p))
+	return len(p), nil
+}
+
+func TestVerifyNaclStreamStreams(t *testing.T) {
+	key := genCacheKey(t)
+	var signed bytes.Buffer
+	w, err := NewNaclSignStream(&signed, key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	// More than we'd be willing to hold in memory.
+	chunk := bytes.Repeat([]byte{'x'}, NACL_STREAM_CHUNK_SIZE)
+	nchunks := 65
+	for i := 0; i < nchunks; i++ {
+		if _, err = w.Write(chunk); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err = w.Close(); err != nil {
+		t.Fatal(err)
+	}
+
+	r, _, err := NewNaclVerifyStream(bytes.NewReader(signed.Bytes()))
+	if err != nil {
+		t.Fatal(err)
+	}
+	out := &countingWriter{}
+	if err = NewVerifyEngine(&VerifyArg{Out: out}).writeStream(r); err != nil {
+		t.Fatalf("big stream failed to verify: %s", err)
+	}
+	if out.n != int64(nchunks*NACL_STREAM_CHUNK_SIZE) {
+		t.Errorf("wrote %d bytes of a %d byte payload", out.n, nchunks*NACL_STREAM_CHUNK_SIZE)
+	}
+
+	// Missing the final chunk.
+	short := signed.Bytes()[:signed.Len()-100]
+	if r, _, err = NewNaclVerifyStream(bytes.NewReader(short)); err != nil {
+		t.Fatal(err)
+	}
+	if err = NewVerifyEngine(&VerifyArg{Out: &countingWriter{}}).writeStream(r); err == nil {
+		t.Errorf("truncated stream verified")
+	}
+}
+
+func TestVerifyAttachedStreams(t *testing.T) {
+	key := genSigningKey(t)
+	var signed bytes.Buffer
+	w, err := AttachedSignWrapper(noOpCloser{&signed}, *key, false)
+	if err != nil {
+		t.Fatal(err)
+	}
+	// More than we'd be willing to hold in memory.
+	chunk := bytes.Repeat([]byte{'x'}, 1024*1024)
+	nchunks := 65
+	for i := 0; i < nchunks; i++ {
+		if _, err = w.Write(chunk); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err = w.Close(); err != nil {
+		t.Fatal(err)
+	}
+
+	// verify checks msg against a ring that already has the signer.
+	verify := func(msg []byte) error {
+		e := NewVerifyEngine(&VerifyArg{Message: bytes.NewReader(msg), Out: &countingWriter{}})
+		e.ring.Owner = &User{name: "signer"}
+		e.ring.Key = key
+		if err := e.checkAttached(); err != nil {
+			return err
+		}
+		if n := e.arg.Out.(*countingWriter).n; n != int64(nchunks*len(chunk)) {
+			t.Errorf("wrote %d bytes of a %d byte payload", n, nchunks*len(chunk))
+		}
+		return nil
+	}
+	if err = verify(signed.Bytes()); err != nil {
+		t.Fatalf("big message failed to verify: %s", err)
+	}
+
+	tampered := append([]byte{}, signed.Bytes()...)
+	tampered[len(tampered)/2] ^= 1
+	if err = verify(tampered); err == nil {
+		t.Errorf("tampered message verified")
+	}
+}